@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Bus factories take the initialized bus config, which must be non-nil
+func useMemory(conf *config.BusConfig) bool {
+	return conf.Backend == config.BUS_MEMORY
+}
+
+func NewTxBus(conf *config.BusConfig, chainId uint64, txType msg.TxType) TxBus {
+	if useMemory(conf) {
+		b := NewMemoryTxBus(Memory(), chainId, txType)
+		b.lanes = newLanes(conf.PriorityWeight)
+		return b
+	}
+	if conf.Backend == config.BUS_STREAM {
+		b := NewRedisStreamTxBus(New(conf), chainId, txType, conf.Lease())
+		b.lanes = newLanes(conf.PriorityWeight)
+		return b
+	}
+	if conf.Reliable {
+		b := NewRedisReliableTxBus(New(conf), chainId, txType, conf.Lease())
+		b.lanes = newLanes(conf.PriorityWeight)
+		return b
+	}
+	b := NewRedisTxBus(New(conf), chainId, txType)
+	b.lanes = newLanes(conf.PriorityWeight)
+	return b
+}
+
+// Patch buses are never reliable, patched txs are expected to be resent on loss
+func NewPatchTxBus(conf *config.BusConfig, chainId uint64) TxBus {
+	if useMemory(conf) {
+		return NewMemoryPatchTxBus(Memory(), chainId)
+	}
+	return NewRedisPatchTxBus(New(conf), chainId)
+}
+
+func NewSortedTxBus(conf *config.BusConfig, chainId uint64, txType msg.TxType) SortedTxBus {
+	if useMemory(conf) {
+		return NewMemorySortedTxBus(Memory(), chainId, txType)
+	}
+	if conf.Backend == config.BUS_STREAM {
+		return NewRedisStreamSortedTxBus(New(conf), chainId, txType, conf.Lease())
+	}
+	if conf.Reliable {
+		return NewRedisReliableSortedTxBus(New(conf), chainId, txType, conf.Lease())
+	}
+	return NewRedisSortedTxBus(New(conf), chainId, txType)
+}
+
+func NewDelayedTxBus(conf *config.BusConfig) DelayedTxBus {
+	if useMemory(conf) {
+		return NewMemoryDelayedTxBus(Memory())
+	}
+	return NewRedisDelayedTxBus(New(conf))
+}
+
+func NewChainStore(key Key, conf *config.BusConfig, interval uint64) ChainStore {
+	if useMemory(conf) {
+		return NewMemoryChainStore(key, Memory(), interval)
+	}
+	return NewRedisChainStore(key, New(conf), interval)
+}
+
+func NewSkipCheck(conf *config.BusConfig) SkipCheck {
+	if useMemory(conf) {
+		return NewMemorySkipCheck(Memory())
+	}
+	return NewRedisSkipCheck(New(conf))
+}
+
+func NewElection(conf *config.BusConfig, name string) Election {
+	if useMemory(conf) {
+		return NewMemoryElection(Memory(), name)
+	}
+	return NewRedisElection(New(conf), name)
+}
+
+func NewRegistry(conf *config.BusConfig) Registry {
+	if useMemory(conf) {
+		return NewMemoryRegistry(Memory())
+	}
+	return NewRedisRegistry(New(conf))
+}
+
+func NewDeadLetterBus(conf *config.BusConfig) DeadLetterBus {
+	if useMemory(conf) {
+		return NewMemoryDeadLetterBus(Memory())
+	}
+	return NewRedisDeadLetterBus(New(conf))
+}
+
+func NewControlStore(conf *config.BusConfig) ControlStore {
+	if useMemory(conf) {
+		return NewMemoryControlStore(Memory())
+	}
+	return NewRedisControlStore(New(conf))
+}
+
+func NewNonceStore(conf *config.BusConfig) NonceStore {
+	if useMemory(conf) {
+		return NewMemoryNonceStore(Memory())
+	}
+	return NewRedisNonceStore(New(conf))
+}
@@ -20,6 +20,8 @@ package bus
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -39,23 +41,32 @@ func (s String) Key() string {
 	return fmt.Sprintf("%s:relayer:%s", base.ENV, string(s))
 }
 
-func NewPatchKey(chainId uint64) String {
-	return String(fmt.Sprintf("patch:%d", chainId))
+// Key of a queue whose in flight keys are derived by suffix, in hash tag for cluster slots
+type QueueKey string
+
+func (k QueueKey) Key() string {
+	return fmt.Sprintf("%s:relayer:%s", base.ENV, hashTag(string(k)))
+}
+
+func NewPatchKey(chainId uint64) QueueKey {
+	return QueueKey(fmt.Sprintf("patch:%d", chainId))
 }
 
 type TxQueueKey struct {
-	ChainId uint64
-	TxType  msg.TxType
+	ChainId  uint64
+	TxType   msg.TxType
+	Priority int
 }
 
 func (k *TxQueueKey) Key() string {
-	return fmt.Sprintf("%s:relayer:bus:%v:%v", base.ENV, k.ChainId, k.TxType)
+	return fmt.Sprintf("%s:relayer:%s%s", base.ENV, hashTag(fmt.Sprintf("bus:%v:%v", k.ChainId, k.TxType)), laneSuffix(k.Priority))
 }
 
 func GetQueue(tx *msg.Tx) *TxQueueKey {
 	return &TxQueueKey{
-		ChainId: tx.DstChainId,
-		TxType:  tx.Type(),
+		ChainId:  tx.DstChainId,
+		TxType:   tx.Type(),
+		Priority: tx.Priority,
 	}
 }
 
@@ -75,23 +86,29 @@ type TxBus interface {
 	Len(context.Context) (uint64, error)
 	LenOf(context.Context, uint64, msg.TxType) (uint64, error)
 	Topic() string
+	Ack(context.Context, *msg.Tx) error               // Settle a popped tx
+	Nack(context.Context, *msg.Tx) error              // Return a popped tx to the queue
+	Entries(context.Context, int64) ([]*Entry, error) // Queued txs not popped yet, at most count, zero for all
+	Remove(context.Context, ...*Entry) (uint64, error)
 }
 
 type RedisTxBus struct {
 	Key
-	db *redis.Client
+	db    redis.UniversalClient
+	lanes *lanes
 }
 
-func NewRedisTxBus(db *redis.Client, chainId uint64, txType msg.TxType) *RedisTxBus {
+func NewRedisTxBus(db redis.UniversalClient, chainId uint64, txType msg.TxType) *RedisTxBus {
 	bus := &RedisTxBus{
-		db:  db,
-		Key: &TxQueueKey{ChainId: chainId, TxType: txType},
+		db:    db,
+		Key:   &TxQueueKey{ChainId: chainId, TxType: txType},
+		lanes: newLanes(0),
 	}
 	return bus
 }
 
-func NewRedisPatchTxBus(db *redis.Client, chainId uint64) *RedisTxBus {
-	return &RedisTxBus{NewPatchKey(chainId), db}
+func NewRedisPatchTxBus(db redis.UniversalClient, chainId uint64) *RedisTxBus {
+	return &RedisTxBus{Key: NewPatchKey(chainId), db: db}
 }
 
 func (b *RedisTxBus) Topic() (topic string) {
@@ -103,7 +120,7 @@ func (b *RedisTxBus) Pop(ctx context.Context) (*msg.Tx, error) {
 }
 
 func (b *RedisTxBus) PopTimed(ctx context.Context, duration time.Duration) (*msg.Tx, error) {
-	res, err := b.db.BLPop(ctx, duration, b.Key.Key()).Result()
+	res, err := b.db.BLPop(ctx, duration, b.lanes.keys(b.Key)...).Result()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to pop message %v", err)
 	}
@@ -117,7 +134,7 @@ func (b *RedisTxBus) PopTimed(ctx context.Context, duration time.Duration) (*msg
 }
 
 func (b *RedisTxBus) PushToChain(ctx context.Context, tx *msg.Tx) error {
-	_, err := b.db.RPush(ctx, GetQueue(tx).Key(), tx.Encode()).Result()
+	_, err := b.db.RPush(ctx, GetQueue(tx).Key(), encodeQueued(tx)).Result()
 	if err != nil {
 		return fmt.Errorf("Failed to push message %v", err)
 	}
@@ -129,7 +146,7 @@ func (b *RedisTxBus) Patch(ctx context.Context, tx *msg.Tx) (err error) {
 	if tx.Type() == msg.POLY {
 		chain = base.POLY
 	}
-	_, err = b.db.RPush(ctx, NewPatchKey(chain).Key(), tx.Encode()).Result()
+	_, err = b.db.RPush(ctx, NewPatchKey(chain).Key(), encodeQueued(tx)).Result()
 	if err != nil {
 		return fmt.Errorf("Failed to push message %v", err)
 	}
@@ -137,7 +154,7 @@ func (b *RedisTxBus) Patch(ctx context.Context, tx *msg.Tx) (err error) {
 }
 
 func (b *RedisTxBus) Push(ctx context.Context, tx *msg.Tx) error {
-	_, err := b.db.RPush(ctx, b.Key.Key(), tx.Encode()).Result()
+	_, err := b.db.RPush(ctx, LaneKey(b.Key, tx.Priority).Key(), encodeQueued(tx)).Result()
 	if err != nil {
 		return fmt.Errorf("Failed to push message %v", err)
 	}
@@ -145,30 +162,117 @@ func (b *RedisTxBus) Push(ctx context.Context, tx *msg.Tx) error {
 }
 
 func (b *RedisTxBus) PushBack(ctx context.Context, tx *msg.Tx) error {
-	_, err := b.db.LPush(ctx, GetQueue(tx).Key(), tx.Encode()).Result()
+	_, err := b.db.LPush(ctx, GetQueue(tx).Key(), encodeQueued(tx)).Result()
 	if err != nil {
 		return fmt.Errorf("Failed to push message %v", err)
 	}
 	return nil
 }
 
-func (b *RedisTxBus) Len(ctx context.Context) (uint64, error) {
-	v, err := b.db.LLen(ctx, b.Key.Key()).Result()
-	if err != nil {
-		return 0, fmt.Errorf("Get chain tx queue length error %v", err)
+func (b *RedisTxBus) Len(ctx context.Context) (total uint64, err error) {
+	for _, key := range laneKeys(b.Key, LANES) {
+		v, err := b.db.LLen(ctx, key).Result()
+		if err != nil {
+			return 0, fmt.Errorf("Get chain tx queue length error %v", err)
+		}
+		total += uint64(v)
 	}
-	return uint64(v), nil
+	return
 }
 
 func (b *RedisTxBus) LenOf(ctx context.Context, chain uint64, ty msg.TxType) (uint64, error) {
-	key := &TxQueueKey{chain, ty}
-	v, err := b.db.LLen(ctx, key.Key()).Result()
-	if err != nil {
-		return 0, fmt.Errorf("Get chain tx queue length error %v", err)
+	return (&RedisTxBus{Key: &TxQueueKey{ChainId: chain, TxType: ty}, db: b.db}).Len(ctx)
+}
+
+func (b *RedisTxBus) Ack(ctx context.Context, tx *msg.Tx) error {
+	return nil
+}
+
+func (b *RedisTxBus) Nack(ctx context.Context, tx *msg.Tx) error {
+	return b.Push(ctx, tx)
+}
+
+// Counts keyed by name, safe for concurrent use
+type Counter struct {
+	sync.Mutex
+	counts map[string]uint64
+}
+
+func NewCounter() *Counter {
+	return &Counter{counts: map[string]uint64{}}
+}
+
+func (c *Counter) Add(key string) {
+	c.AddN(key, 1)
+}
+
+func (c *Counter) AddN(key string, n uint64) {
+	c.Lock()
+	c.counts[key] += n
+	c.Unlock()
+}
+
+// Copy of the counts
+func (c *Counter) Counts() map[string]uint64 {
+	c.Lock()
+	defer c.Unlock()
+	counts := make(map[string]uint64, len(c.counts))
+	for k, v := range c.counts {
+		counts[k] = v
 	}
-	return uint64(v), nil
+	return counts
+}
+
+var (
+	drops    = NewCounter() // Txs dropped by filters per reason
+	outcomes = NewCounter() // Final dst tx outcomes per chain
+	gasUsed  = NewCounter() // Gas used by mined dst txs per chain
+)
+
+// Metric key segment of the chain name
+func chainName(chainId uint64) string {
+	return strings.NewReplacer("(", "", ")", "").Replace(base.GetChainName(chainId))
+}
+
+// Log and count the tx dropped by filter
+func Drop(tx *msg.Tx, reason string) {
+	log.Warn("Filter drops tx", "reason", reason, "src_chain", tx.SrcChainId, "dst_chain", tx.DstChainId, "src_hash", tx.SrcHash, "poly_hash", tx.PolyHash)
+	drops.Add(reason)
+}
+
+// Counts of dropped txs per reason since start
+func Drops() map[string]uint64 {
+	return drops.Counts()
+}
+
+// Log and count the final outcome of the dst tx
+func Outcome(tx *msg.Tx, outcome string) {
+	log.Info("Dst tx finished", "outcome", outcome, "chain", tx.DstChainId, "poly_hash", tx.PolyHash, "dst_hash", tx.DstHash)
+	outcomes.Add(fmt.Sprintf("%s.%s", chainName(tx.DstChainId), outcome))
+}
+
+// Alert and count the dst tx pending beyond the gas bump limits, it stays pending on chain
+func Stuck(tx *msg.Tx) {
+	log.Error("Dst tx stuck, held till its nonce is taken", "chain", tx.DstChainId, "poly_hash", tx.PolyHash, "dst_hash", tx.DstHash)
+	outcomes.Add(fmt.Sprintf("%s.%s", chainName(tx.DstChainId), OUTCOME_STUCK))
+}
+
+// Counts of dst tx outcomes keyed by chain name and outcome since start
+func Outcomes() map[string]uint64 {
+	return outcomes.Counts()
+}
+
+// Count the gas used by the mined dst tx, in the fee units of the chain
+func GasUsed(tx *msg.Tx, gas uint64) {
+	gasUsed.AddN(chainName(tx.DstChainId), gas)
+}
+
+// Gas used by mined dst txs keyed by chain name since start
+func GasUsages() map[string]uint64 {
+	return gasUsed.Counts()
 }
 
+// Src tx bus with filter, txs are evaluated as final since src txs are committed as is
 type TxBusWithFilter struct {
 	SortedTxBus
 	filter *config.FilterConfig
@@ -184,15 +288,17 @@ func (b *TxBusWithFilter) Pop(ctx context.Context) (*msg.Tx, uint64, error) {
 		if err != nil {
 			return nil, 0, err
 		}
-		if b.filter.Check(tx) {
+		if ok, reason := b.filter.Evaluate(tx, true); ok {
 			log.Debug("Filter passes tx", "chain", tx.DstChainId, "src_proxy", tx.SrcProxy, "dst_proxy", tx.DstProxy)
 			return tx, score, nil
 		} else {
-			log.Warn("Filter ignores tx", "tx", tx.Encode())
+			Drop(tx, reason)
+			SafeCall(ctx, tx, "ack filtered tx", func() error { return b.Ack(context.Background(), tx) })
 		}
 	}
 }
 
+// Poly tx bus with filter, rules depending on composed fields are left to the compose check
 type BusWithFilter struct {
 	TxBus
 	filter *config.FilterConfig
@@ -208,11 +314,12 @@ func (b *BusWithFilter) Pop(ctx context.Context) (*msg.Tx, error) {
 		if err != nil {
 			return nil, err
 		}
-		if b.filter.Check(tx) {
+		if ok, reason := b.filter.Evaluate(tx, false); ok {
 			log.Debug("Filter passes tx", "chain", tx.DstChainId, "src_proxy", tx.SrcProxy, "dst_proxy", tx.DstProxy)
 			return tx, nil
 		} else {
-			log.Warn("Filter ignores tx", "tx", tx.Encode())
+			Drop(tx, reason)
+			SafeCall(ctx, tx, "ack filtered tx", func() error { return b.Ack(context.Background(), tx) })
 		}
 	}
 }
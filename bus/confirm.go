@@ -0,0 +1,219 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/log"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+const (
+	CONFIRM_INTERVAL = 10 * time.Second
+	CONFIRM_TIMEOUT  = 10 * time.Minute // Submitted dst tx not found on chain for the duration is dropped
+
+	OUTCOME_CONFIRMED = "confirmed" // Mined successfully with enough confirmations
+	OUTCOME_REVERTED  = "reverted"  // Mined but failed
+	OUTCOME_REPLACED  = "replaced"  // Nonce taken by a tx not sent by the tracker
+	OUTCOME_REORGED   = "reorged"   // Mined then gone from the chain
+	OUTCOME_DROPPED   = "dropped"   // Never found on chain
+	OUTCOME_STUCK     = "stuck"     // Pending with max bumps or max price, held till replaced
+)
+
+// Execution result of a dst tx
+type Receipt struct {
+	Height  uint64
+	Block   string // Block hash if available, to detect the tx mined again in another block
+	Success bool
+	GasUsed uint64
+}
+
+// Dst chain access of the confirmer
+type ReceiptSource interface {
+	Receipt(hash string) (*Receipt, error) // Nil receipt if the tx is not found
+	Height() (uint64, error)
+}
+
+// Confirmations before a dst tx is final, positive config takes precedence, negative for none
+func Confirmations(chainId uint64, conf int) uint64 {
+	if conf > 0 {
+		return uint64(conf)
+	} else if conf < 0 {
+		return 0
+	}
+	switch chainId {
+	case base.APTOS:
+		return 0
+	}
+	n := base.BlocksToWait(chainId)
+	if n > 1000 {
+		// Unknown chains
+		return 12
+	}
+	return n
+}
+
+type confirmingTx struct {
+	tx      *msg.Tx
+	receipt *Receipt // Last seen
+	since   time.Time
+	missing bool // Mined tx not found in last check
+}
+
+// Confirmer watches the submitted dst txs till final, failed ones are fed back to the delayed queue with the retry policy
+type Confirmer struct {
+	sync.Mutex
+	chain    uint64
+	name     string
+	source   ReceiptSource
+	delay    DelayedTxBus
+	retry    RetryPolicy
+	confirms uint64
+	txs      map[string]*confirmingTx // Keyed by poly hash
+}
+
+func NewConfirmer(chainId uint64, source ReceiptSource, delay DelayedTxBus, retry RetryPolicy, confirms uint64) *Confirmer {
+	return &Confirmer{
+		chain: chainId, name: base.GetChainName(chainId), source: source, delay: delay, retry: retry, confirms: confirms,
+		txs: map[string]*confirmingTx{},
+	}
+}
+
+// Watch the submitted dst tx
+func (c *Confirmer) Watch(tx *msg.Tx) {
+	if c == nil || tx.DstHash == "" {
+		return
+	}
+	t := *tx
+	c.Lock()
+	defer c.Unlock()
+	c.txs[tx.PolyHash] = &confirmingTx{tx: &t, since: time.Now()}
+}
+
+// Check if a dst tx of the poly tx is not final yet
+func (c *Confirmer) Pending(hash string) bool {
+	if c == nil {
+		return false
+	}
+	c.Lock()
+	defer c.Unlock()
+	_, ok := c.txs[hash]
+	return ok
+}
+
+func (c *Confirmer) list() (txs []*confirmingTx) {
+	c.Lock()
+	defer c.Unlock()
+	for _, p := range c.txs {
+		txs = append(txs, p)
+	}
+	return
+}
+
+func (c *Confirmer) Start(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+	defer Recover(ctx)
+	ticker := time.NewTicker(CONFIRM_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Dst tx confirmer is exiting now", "chain", c.name, "pending", len(c.list()))
+			return
+		case <-ticker.C:
+			txs := c.list()
+			if len(txs) == 0 {
+				continue
+			}
+			var height uint64
+			if c.confirms > 0 {
+				h, err := c.source.Height()
+				if err != nil {
+					log.Warn("Failed to fetch dst chain height", "chain", c.name, "err", err)
+					continue
+				}
+				height = h
+			}
+			for _, p := range txs {
+				c.check(ctx, p, height)
+			}
+		}
+	}
+}
+
+func (c *Confirmer) check(ctx context.Context, p *confirmingTx, height uint64) {
+	hash := p.tx.DstHash
+	r, err := c.source.Receipt(hash)
+	if err != nil {
+		log.Warn("Failed to fetch dst tx receipt", "chain", c.name, "hash", hash, "err", err)
+		return
+	}
+	if r == nil {
+		if p.receipt != nil {
+			// Lagging nodes may miss the receipt, confirm in the next check
+			if !p.missing {
+				p.missing = true
+				return
+			}
+			c.finish(ctx, p, OUTCOME_REORGED, fmt.Errorf("Dst tx %s at height %v reorged out", hash, p.receipt.Height))
+		} else if time.Since(p.since) > CONFIRM_TIMEOUT {
+			c.finish(ctx, p, OUTCOME_DROPPED, fmt.Errorf("Dst tx %s not found on chain", hash))
+		}
+		return
+	}
+	if p.receipt != nil && p.receipt.Block != r.Block {
+		log.Warn("Dst tx mined again after reorg", "chain", c.name, "hash", hash, "height", r.Height, "last_height", p.receipt.Height)
+	}
+	p.receipt, p.missing = r, false
+	if c.confirms > 0 && r.Height+c.confirms > height {
+		return
+	}
+	p.tx.DstHeight = r.Height
+	p.tx.DstGasUsed = r.GasUsed
+	GasUsed(p.tx, r.GasUsed)
+	if r.Success {
+		c.finish(ctx, p, OUTCOME_CONFIRMED, nil)
+	} else {
+		c.finish(ctx, p, OUTCOME_REVERTED, fmt.Errorf("%w dst tx %s reverted at height %v", msg.ERR_TX_EXEC_FAILURE, hash, r.Height))
+	}
+}
+
+func (c *Confirmer) finish(ctx context.Context, p *confirmingTx, outcome string, err error) {
+	c.Lock()
+	delete(c.txs, p.tx.PolyHash)
+	c.Unlock()
+	Outcome(p.tx, outcome)
+	if err == nil {
+		return
+	}
+	// Process again, txs relayed already are skipped
+	p.tx.Fail(err)
+	p.tx.DstHash = ""
+	tsp := time.Now().Unix()
+	if outcome != OUTCOME_REORGED {
+		tsp = c.retry.Next(p.tx, err)
+	}
+	SafeCall(ctx, p.tx, "push to delay queue", func() error { return c.delay.Delay(context.Background(), p.tx, tsp) })
+}
@@ -0,0 +1,61 @@
+package bus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+type receipts map[string]*Receipt
+
+func (r receipts) Receipt(hash string) (*Receipt, error) {
+	return r[hash], nil
+}
+
+func (r receipts) Height() (uint64, error) {
+	return 0, nil
+}
+
+func TestConfirmer(t *testing.T) {
+	ctx := context.Background()
+	source := receipts{
+		"0x01": {Height: 100, Success: true, GasUsed: 21000},
+		"0x02": {Height: 100},
+		"0x03": {Height: 100, Success: true},
+	}
+	delay := NewMemoryDelayedTxBus(NewMemoryDB())
+	c := NewConfirmer(base.ETH, source, delay, NewRetryPolicy(), 6)
+	for _, hash := range []string{"0x01", "0x02", "0x03"} {
+		c.Watch(&msg.Tx{PolyHash: hash, DstHash: hash, DstChainId: base.ETH})
+	}
+	for _, p := range c.list() {
+		c.check(ctx, p, 105)
+	}
+	if !c.Pending("0x01") || !c.Pending("0x02") {
+		t.Fatal("Expect txs pending for confirmations")
+	}
+
+	// Reorged out, missing twice
+	delete(source, "0x03")
+	for i := 0; i < 2; i++ {
+		for _, p := range c.list() {
+			c.check(ctx, p, 106)
+		}
+	}
+	if len(c.list()) != 0 {
+		t.Fatalf("Expect all txs final, pending %v", len(c.list()))
+	}
+	entries, _ := delay.Entries(ctx, 0)
+	if size := len(entries); size != 2 {
+		t.Fatalf("Expect reverted and reorged txs delayed, got %v", size)
+	}
+	if GasUsages()[chainName(base.ETH)] < 21000 {
+		t.Fatal("Expect gas used counted")
+	}
+
+	if Confirmations(base.ETH, 0) != 12 || Confirmations(base.ETH, 3) != 3 || Confirmations(base.ETH, -1) != 0 {
+		t.Fatal("Unexpected confirmations")
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+// Runtime controls of the roles keyed by task
+var ROLE_CONTROLS = QueueKey("controls")
+
+// Runtime control of a role, overrides the role config till cleared
+type Control struct {
+	Paused  bool
+	Procs   int                  `json:",omitempty"`
+	Filter  *config.FilterConfig `json:",omitempty"`
+	Reason  string               `json:",omitempty"`
+	Updated int64
+}
+
+// Whether the controls apply the same settings to the role
+func (c *Control) Same(o *Control) bool {
+	a, b := Control{}, Control{}
+	if c != nil {
+		a = *c
+	}
+	if o != nil {
+		b = *o
+	}
+	if a.Paused != b.Paused || a.Procs != b.Procs {
+		return false
+	}
+	x, _ := json.Marshal(a.Filter)
+	y, _ := json.Marshal(b.Filter)
+	return string(x) == string(y)
+}
+
+type ControlStore interface {
+	Get(ctx context.Context, task string) (*Control, error) // Nil if no control
+	Set(ctx context.Context, task string, c *Control) error
+	Clear(ctx context.Context, task string) error
+	List(context.Context) (map[string]*Control, error)
+}
+
+func decodeControls(values map[string]string) (controls map[string]*Control, err error) {
+	controls = map[string]*Control{}
+	for task, v := range values {
+		c := new(Control)
+		err = json.Unmarshal([]byte(v), c)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode role control %v", err)
+		}
+		controls[task] = c
+	}
+	return
+}
+
+func encodeControl(c *Control) string {
+	c.Updated = time.Now().Unix()
+	data, _ := json.Marshal(c)
+	return string(data)
+}
+
+type RedisControlStore struct {
+	db redis.UniversalClient
+}
+
+func NewRedisControlStore(db redis.UniversalClient) *RedisControlStore {
+	return &RedisControlStore{db}
+}
+
+func (s *RedisControlStore) Get(ctx context.Context, task string) (*Control, error) {
+	v, err := s.db.HGet(ctx, ROLE_CONTROLS.Key(), task).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to get role control %v", err)
+	}
+	controls, err := decodeControls(map[string]string{task: v})
+	if err != nil {
+		return nil, err
+	}
+	return controls[task], nil
+}
+
+func (s *RedisControlStore) Set(ctx context.Context, task string, c *Control) error {
+	err := s.db.HSet(ctx, ROLE_CONTROLS.Key(), task, encodeControl(c)).Err()
+	if err != nil {
+		return fmt.Errorf("Failed to set role control %v", err)
+	}
+	return nil
+}
+
+func (s *RedisControlStore) Clear(ctx context.Context, task string) error {
+	err := s.db.HDel(ctx, ROLE_CONTROLS.Key(), task).Err()
+	if err != nil {
+		return fmt.Errorf("Failed to clear role control %v", err)
+	}
+	return nil
+}
+
+func (s *RedisControlStore) List(ctx context.Context) (map[string]*Control, error) {
+	values, err := s.db.HGetAll(ctx, ROLE_CONTROLS.Key()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list role controls %v", err)
+	}
+	return decodeControls(values)
+}
+
+type MemoryControlStore struct {
+	db *MemoryDB
+}
+
+func NewMemoryControlStore(db *MemoryDB) *MemoryControlStore {
+	return &MemoryControlStore{db}
+}
+
+func (s *MemoryControlStore) Get(ctx context.Context, task string) (*Control, error) {
+	v, ok := s.db.HGet(ROLE_CONTROLS.Key(), task)
+	if !ok {
+		return nil, nil
+	}
+	controls, err := decodeControls(map[string]string{task: v})
+	if err != nil {
+		return nil, err
+	}
+	return controls[task], nil
+}
+
+func (s *MemoryControlStore) Set(ctx context.Context, task string, c *Control) error {
+	s.db.HSet(ROLE_CONTROLS.Key(), task, encodeControl(c))
+	return nil
+}
+
+func (s *MemoryControlStore) Clear(ctx context.Context, task string) error {
+	s.db.HDel(ROLE_CONTROLS.Key(), task)
+	return nil
+}
+
+func (s *MemoryControlStore) List(ctx context.Context) (map[string]*Control, error) {
+	return decodeControls(s.db.HGetAll(ROLE_CONTROLS.Key()))
+}
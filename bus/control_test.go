@@ -0,0 +1,56 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+func TestControlStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryControlStore(NewMemoryDB())
+	c, err := store.Get(ctx, "2:PolyCommit")
+	if err != nil || c != nil {
+		t.Fatalf("Expect no control, got %v %v", c, err)
+	}
+	err = store.Set(ctx, "2:PolyCommit", &Control{Paused: true, Filter: &config.FilterConfig{AddressFilter: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	controls, err := store.List(ctx)
+	if err != nil || len(controls) != 1 {
+		t.Fatalf("Unexpected controls %v %v", controls, err)
+	}
+	c = controls["2:PolyCommit"]
+	if !c.Paused || c.Updated == 0 {
+		t.Fatalf("Unexpected control %+v", c)
+	}
+	if !c.Same(&Control{Paused: true, Filter: &config.FilterConfig{AddressFilter: true}}) {
+		t.Fatal("Expect same control ignoring update time")
+	}
+	if c.Same(&Control{Paused: true}) || (&Control{Procs: 2}).Same(nil) || !(&Control{}).Same(nil) {
+		t.Fatal("Unexpected control comparison")
+	}
+	store.Clear(ctx, "2:PolyCommit")
+	if c, _ = store.Get(ctx, "2:PolyCommit"); c != nil {
+		t.Fatal("Expect control cleared")
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Tx which exhausted retries
+type DeadLetter struct {
+	Tx      *msg.Tx
+	Reason  string // Reason moved into dead letter bus
+	Error   string // Last failure of the tx
+	Created int64  // Timestamp moved into dead letter bus
+}
+
+func (d *DeadLetter) Id() string {
+	return DeadLetterId(d.Tx.PolyHash, d.Tx.SrcHash)
+}
+
+func DeadLetterId(hashes ...string) string {
+	for _, hash := range formatHashes(hashes...) {
+		return hash
+	}
+	return ""
+}
+
+type DeadLetterBus interface {
+	Put(context.Context, *DeadLetter) error
+	Get(context.Context, string) (*DeadLetter, error)
+	List(context.Context, uint64) ([]*DeadLetter, error) // List by dst chain, zero for all chains
+	Remove(context.Context, string) (bool, error)
+}
+
+func decodeDeadLetters(values map[string]string, chain uint64) (list []*DeadLetter, err error) {
+	for _, v := range values {
+		d := new(DeadLetter)
+		err = json.Unmarshal([]byte(v), d)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode dead letter %v", err)
+		}
+		if d.Tx != nil && (chain == 0 || d.Tx.DstChainId == chain) {
+			list = append(list, d)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created < list[j].Created })
+	return
+}
+
+type RedisDeadLetterBus struct {
+	Key
+	db redis.UniversalClient
+}
+
+func NewRedisDeadLetterBus(db redis.UniversalClient) *RedisDeadLetterBus {
+	return &RedisDeadLetterBus{String("dead_letter"), db}
+}
+
+func (b *RedisDeadLetterBus) Put(ctx context.Context, d *DeadLetter) error {
+	data, _ := json.Marshal(d)
+	_, err := b.db.HSet(ctx, b.Key.Key(), d.Id(), string(data)).Result()
+	if err != nil {
+		return fmt.Errorf("Failed to put dead letter %v", err)
+	}
+	return nil
+}
+
+func (b *RedisDeadLetterBus) Get(ctx context.Context, id string) (*DeadLetter, error) {
+	v, err := b.db.HGet(ctx, b.Key.Key(), strings.ToLower(id)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to get dead letter %v", err)
+	}
+	d := new(DeadLetter)
+	err = json.Unmarshal([]byte(v), d)
+	return d, err
+}
+
+func (b *RedisDeadLetterBus) List(ctx context.Context, chain uint64) ([]*DeadLetter, error) {
+	values, err := b.db.HGetAll(ctx, b.Key.Key()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list dead letters %v", err)
+	}
+	return decodeDeadLetters(values, chain)
+}
+
+func (b *RedisDeadLetterBus) Remove(ctx context.Context, id string) (bool, error) {
+	n, err := b.db.HDel(ctx, b.Key.Key(), strings.ToLower(id)).Result()
+	if err != nil {
+		return false, fmt.Errorf("Failed to remove dead letter %v", err)
+	}
+	return n > 0, nil
+}
+
+type MemoryDeadLetterBus struct {
+	Key
+	db *MemoryDB
+}
+
+func NewMemoryDeadLetterBus(db *MemoryDB) *MemoryDeadLetterBus {
+	return &MemoryDeadLetterBus{String("dead_letter"), db}
+}
+
+func (b *MemoryDeadLetterBus) Put(ctx context.Context, d *DeadLetter) error {
+	data, _ := json.Marshal(d)
+	b.db.HSet(b.Key.Key(), d.Id(), string(data))
+	return nil
+}
+
+func (b *MemoryDeadLetterBus) Get(ctx context.Context, id string) (*DeadLetter, error) {
+	v, ok := b.db.HGet(b.Key.Key(), strings.ToLower(id))
+	if !ok {
+		return nil, nil
+	}
+	d := new(DeadLetter)
+	err := json.Unmarshal([]byte(v), d)
+	return d, err
+}
+
+func (b *MemoryDeadLetterBus) List(ctx context.Context, chain uint64) ([]*DeadLetter, error) {
+	return decodeDeadLetters(b.db.HGetAll(b.Key.Key()), chain)
+}
+
+func (b *MemoryDeadLetterBus) Remove(ctx context.Context, id string) (bool, error) {
+	return b.db.HDel(b.Key.Key(), strings.ToLower(id)), nil
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Dedup key of the tx, keyed on PolyHash for poly txs and SrcHash for src txs
+func DedupKey(tx *msg.Tx) (String, bool) {
+	hash := tx.SrcHash
+	if tx.Type() == msg.POLY {
+		hash = tx.PolyHash
+	}
+	hashes := formatHashes(hash)
+	if len(hashes) == 0 {
+		return "", false
+	}
+	return String(fmt.Sprintf("dedup:%v:%s", tx.Type(), hashes[0])), true
+}
+
+// Marks of txs already entered the bus pipeline
+type Dedup interface {
+	Mark(ctx context.Context, key Key, ttl time.Duration, force bool) (bool, error) // False if marked already, force to overwrite
+	Clear(ctx context.Context, key Key) error
+}
+
+func NewDedup(conf *config.BusConfig) Dedup {
+	if useMemory(conf) {
+		return &MemoryDedup{Memory()}
+	}
+	return &RedisDedup{New(conf)}
+}
+
+type RedisDedup struct {
+	db redis.UniversalClient
+}
+
+func (d *RedisDedup) Mark(ctx context.Context, key Key, ttl time.Duration, force bool) (ok bool, err error) {
+	if force {
+		err = d.db.Set(ctx, key.Key(), time.Now().Unix(), ttl).Err()
+		ok = err == nil
+	} else {
+		ok, err = d.db.SetNX(ctx, key.Key(), time.Now().Unix(), ttl).Result()
+	}
+	if err != nil {
+		err = fmt.Errorf("Failed to mark tx dedup %v", err)
+	}
+	return
+}
+
+func (d *RedisDedup) Clear(ctx context.Context, key Key) error {
+	_, err := d.db.Del(ctx, key.Key()).Result()
+	if err != nil {
+		return fmt.Errorf("Failed to clear tx dedup %v", err)
+	}
+	return nil
+}
+
+type MemoryDedup struct {
+	db *MemoryDB
+}
+
+func (d *MemoryDedup) Mark(ctx context.Context, key Key, ttl time.Duration, force bool) (bool, error) {
+	value := fmt.Sprint(time.Now().Unix())
+	if force {
+		d.db.Set(key.Key(), value, ttl)
+		return true, nil
+	}
+	return d.db.SetNX(key.Key(), value, ttl), nil
+}
+
+func (d *MemoryDedup) Clear(ctx context.Context, key Key) error {
+	d.db.Del(key.Key())
+	return nil
+}
+
+// Check whether to push the tx, forced txs always pass and the force flag is consumed
+func markTx(ctx context.Context, dedup Dedup, ttl time.Duration, tx *msg.Tx) (bool, error) {
+	key, ok := DedupKey(tx)
+	if !ok {
+		return true, nil
+	}
+	force := tx.Force
+	tx.Force = false
+	ok, err := dedup.Mark(ctx, key, ttl, force)
+	if err == nil && !ok {
+		log.Info("Dropping duplicate tx", "type", tx.Type(), "src_hash", tx.SrcHash, "poly_hash", tx.PolyHash)
+	}
+	return ok, err
+}
+
+func clearTx(ctx context.Context, dedup Dedup, tx *msg.Tx) error {
+	key, ok := DedupKey(tx)
+	if !ok {
+		return nil
+	}
+	return dedup.Clear(ctx, key)
+}
+
+// Tx bus dropping txs pushed again within the dedup window
+type BusWithDedup struct {
+	TxBus
+	dedup Dedup
+	ttl   time.Duration
+}
+
+// Wrap tx bus with dedup if enabled in bus config
+func WithTxDedup(bus TxBus, conf *config.BusConfig) TxBus {
+	if conf == nil || conf.Dedup() == 0 {
+		return bus
+	}
+	return &BusWithDedup{bus, NewDedup(conf), conf.Dedup()}
+}
+
+func (b *BusWithDedup) push(ctx context.Context, tx *msg.Tx, f func(context.Context, *msg.Tx) error) error {
+	ok, err := markTx(ctx, b.dedup, b.ttl, tx)
+	if err != nil || !ok {
+		return err
+	}
+	err = f(ctx, tx)
+	if err != nil {
+		clearTx(ctx, b.dedup, tx)
+	}
+	return err
+}
+
+func (b *BusWithDedup) Push(ctx context.Context, tx *msg.Tx) error {
+	return b.push(ctx, tx, b.TxBus.Push)
+}
+
+func (b *BusWithDedup) PushToChain(ctx context.Context, tx *msg.Tx) error {
+	return b.push(ctx, tx, b.TxBus.PushToChain)
+}
+
+func (b *BusWithDedup) PushBack(ctx context.Context, tx *msg.Tx) error {
+	return b.push(ctx, tx, b.TxBus.PushBack)
+}
+
+// Push back the retry of a tx deduped already, the dedup mark is refreshed instead of checked
+func (b *BusWithDedup) retry(ctx context.Context, tx *msg.Tx) error {
+	if key, ok := DedupKey(tx); ok {
+		_, err := b.dedup.Mark(ctx, key, b.ttl, true)
+		if err != nil {
+			return err
+		}
+	}
+	return b.TxBus.PushToChain(ctx, tx)
+}
+
+// Push the retry of a tx to its dst chain bypassing dedup, the force flag of patch requests is not carried over
+func PushRetry(ctx context.Context, bus TxBus, tx *msg.Tx) error {
+	tx.Force = false
+	if b, ok := bus.(*BusWithDedup); ok {
+		return b.retry(ctx, tx)
+	}
+	return bus.PushToChain(ctx, tx)
+}
+
+// Sorted tx bus dropping txs pushed again within the dedup window
+type SortedBusWithDedup struct {
+	SortedTxBus
+	dedup Dedup
+	ttl   time.Duration
+}
+
+// Wrap sorted tx bus with dedup if enabled in bus config
+func WithDedup(bus SortedTxBus, conf *config.BusConfig) SortedTxBus {
+	if conf == nil || conf.Dedup() == 0 {
+		return bus
+	}
+	return &SortedBusWithDedup{bus, NewDedup(conf), conf.Dedup()}
+}
+
+func (b *SortedBusWithDedup) Push(ctx context.Context, tx *msg.Tx, score uint64) error {
+	ok, err := markTx(ctx, b.dedup, b.ttl, tx)
+	if err != nil || !ok {
+		return err
+	}
+	err = b.SortedTxBus.Push(ctx, tx, score)
+	if err != nil {
+		clearTx(ctx, b.dedup, tx)
+	}
+	return err
+}
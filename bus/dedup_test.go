@@ -0,0 +1,59 @@
+package bus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestBusWithDedup(t *testing.T) {
+	conf := &config.BusConfig{Backend: config.BUS_MEMORY, DedupTime: 60}
+	inner := NewMemoryTxBus(NewMemoryDB(), 2, msg.POLY)
+	b := WithTxDedup(inner, conf)
+	ctx := context.Background()
+
+	b.PushToChain(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "0xABC", DstChainId: 2})
+	b.PushToChain(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "0xabc", DstChainId: 2})
+	if n, _ := inner.Len(ctx); n != 1 {
+		t.Fatalf("Expect duplicate tx dropped, got len %v", n)
+	}
+	tx := &msg.Tx{TxType: msg.POLY, PolyHash: "0xabc", DstChainId: 2, Force: true}
+	b.PushToChain(ctx, tx)
+	if n, _ := inner.Len(ctx); n != 2 || tx.Force {
+		t.Fatalf("Expect forced tx pushed once, got len %v force %v", n, tx.Force)
+	}
+
+	sorted := WithDedup(NewMemorySortedTxBus(NewMemoryDB(), 2, msg.SRC), conf)
+	sorted.Push(ctx, &msg.Tx{TxType: msg.SRC, SrcHash: "0x01"}, 1)
+	sorted.Push(ctx, &msg.Tx{TxType: msg.SRC, SrcHash: "0x01"}, 2)
+	if n, _ := sorted.Len(ctx); n != 1 {
+		t.Fatalf("Expect duplicate src tx dropped, got len %v", n)
+	}
+
+	if WithTxDedup(inner, &config.BusConfig{}) != TxBus(inner) {
+		t.Fatal("Expect dedup disabled without window")
+	}
+}
+
+func TestPushRetry(t *testing.T) {
+	conf := &config.BusConfig{Backend: config.BUS_MEMORY, DedupTime: 60}
+	inner := NewMemoryTxBus(NewMemoryDB(), 2, msg.POLY)
+	ctx := context.Background()
+
+	b := WithTxDedup(inner, conf)
+	b.PushToChain(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "0xdef", DstChainId: 2})
+	tx := &msg.Tx{TxType: msg.POLY, PolyHash: "0xdef", DstChainId: 2, Force: true}
+	PushRetry(ctx, b, tx)
+	if n, _ := inner.Len(ctx); n != 2 || tx.Force {
+		t.Fatalf("Expect retry bypass dedup without force, got len %v force %v", n, tx.Force)
+	}
+
+	tx = &msg.Tx{TxType: msg.POLY, PolyHash: "0xdef", DstChainId: 2, Force: true}
+	PushRetry(ctx, inner, tx)
+	popped, _ := inner.Pop(ctx)
+	if n, _ := inner.Len(ctx); n != 2 || tx.Force || popped.Force {
+		t.Fatalf("Expect retry pushed without force, got len %v force %v", n, popped.Force)
+	}
+}
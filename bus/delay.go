@@ -20,28 +20,90 @@ package bus
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/log"
 	"github.com/polynetwork/poly-relayer/msg"
 )
 
+// Max txs moved out of one delayed queue per release
+const DELAYED_RELEASE_BATCH = 500
+
 type DelayedTxBus interface {
 	Delay(context.Context, *msg.Tx, int64) error
-	Pop(context.Context) (*msg.Tx, int64, error)
-	Len(context.Context) (uint64, error)
+	// Remove and return at most count txs of the dst chain visible at the timestamp, zero chain for the legacy global queue
+	Release(ctx context.Context, chain uint64, now int64, count int) ([]*msg.Tx, error)
+	Chains(context.Context) ([]uint64, error)         // Dst chains with delayed queues
+	Len(context.Context) (uint64, error)              // Due txs of all the delayed queues
+	LenOf(context.Context, uint64) (uint64, error)    // Due txs of the dst chain
+	Entries(context.Context, int64) ([]*Entry, error) // Delayed txs of all chains, at most count, zero for all
+	Remove(context.Context, ...*Entry) (uint64, error)
+}
+
+// Key of the delayed queues, sharing one cluster slot to update the chain registry atomically
+type DelayedKey string
+
+func (k DelayedKey) Key() string {
+	return fmt.Sprintf("%s:relayer:%s%s", base.ENV, hashTag("delayed_tx"), string(k))
+}
+
+// Delayed tx queue of the dst chain, zero chain for the legacy global queue
+func NewDelayedKey(chainId uint64) DelayedKey {
+	if chainId == 0 {
+		return DelayedKey("")
+	}
+	return DelayedKey(fmt.Sprintf(":%d", chainId))
+}
+
+// Registry of dst chains with delayed queues
+var DelayedChainsKey = DelayedKey(":chains")
+
+// Decode released members, malformed ones are dropped
+func decodeDelayed(members []string) (txs []*msg.Tx) {
+	for _, member := range members {
+		tx := new(msg.Tx)
+		err := tx.Decode(member)
+		if err != nil {
+			log.Error("Dropping malformed delayed tx", "body", member, "err", err)
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	return
+}
+
+func parseChains(members []string) (chains []uint64) {
+	for _, member := range members {
+		chain, err := strconv.ParseUint(member, 10, 64)
+		if err == nil && chain > 0 {
+			chains = append(chains, chain)
+		}
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i] < chains[j] })
+	return
 }
 
+// Remove and return due members in one step
+var releaseScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #items > 0 then
+	redis.call('ZREM', KEYS[1], unpack(items))
+end
+return items`)
+
 type RedisDelayedTxBus struct {
 	Key
-	db *redis.Client
+	db redis.UniversalClient
 }
 
-func NewRedisDelayedTxBus(db *redis.Client) *RedisDelayedTxBus {
+func NewRedisDelayedTxBus(db redis.UniversalClient) *RedisDelayedTxBus {
 	bus := &RedisDelayedTxBus{
 		db:  db,
-		Key: String("delayed_tx"),
+		Key: DelayedChainsKey,
 	}
 	return bus
 }
@@ -50,8 +112,31 @@ func (b *RedisDelayedTxBus) Topic() (topic string) {
 	return b.Key.Key()
 }
 
-func (b *RedisDelayedTxBus) Len(ctx context.Context) (uint64, error) {
-	v, err := b.db.ZCount(ctx, b.Key.Key(), "0", strconv.Itoa(int(time.Now().Unix()))).Result()
+func (b *RedisDelayedTxBus) Chains(ctx context.Context) ([]uint64, error) {
+	members, err := b.db.SMembers(ctx, b.Key.Key()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Get delayed tx queue chains error %v", err)
+	}
+	return parseChains(members), nil
+}
+
+func (b *RedisDelayedTxBus) Len(ctx context.Context) (total uint64, err error) {
+	chains, err := b.Chains(ctx)
+	if err != nil {
+		return
+	}
+	for _, chain := range append(chains, 0) {
+		size, err := b.LenOf(ctx, chain)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return
+}
+
+func (b *RedisDelayedTxBus) LenOf(ctx context.Context, chain uint64) (uint64, error) {
+	v, err := b.db.ZCount(ctx, NewDelayedKey(chain).Key(), "0", strconv.FormatInt(time.Now().Unix(), 10)).Result()
 	if err != nil {
 		return 0, fmt.Errorf("Get chain delayed tx queue length error %v", err)
 	}
@@ -59,25 +144,27 @@ func (b *RedisDelayedTxBus) Len(ctx context.Context) (uint64, error) {
 }
 
 func (b *RedisDelayedTxBus) Delay(ctx context.Context, msg *msg.Tx, delay int64) (err error) {
-	_, err = b.db.ZAdd(ctx, b.Key.Key(),
-		&redis.Z{
-			Score:  float64(delay),
-			Member: msg.Encode(),
-		},
-	).Result()
+	_, err = b.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.ZAdd(ctx, NewDelayedKey(msg.DstChainId).Key(), &redis.Z{Score: float64(delay), Member: msg.Encode()})
+		if msg.DstChainId != 0 {
+			p.SAdd(ctx, b.Key.Key(), msg.DstChainId)
+		}
+		return nil
+	})
 	return
 }
 
-func (b *RedisDelayedTxBus) Pop(ctx context.Context) (tx *msg.Tx, score int64, err error) {
-	res, err := b.db.BZPopMin(ctx, 0, b.Key.Key()).Result()
-	if err != nil {
-		return
+func (b *RedisDelayedTxBus) Release(ctx context.Context, chain uint64, now int64, count int) ([]*msg.Tx, error) {
+	res, err := releaseScript.Run(ctx, b.db, []string{NewDelayedKey(chain).Key()}, now, count).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("Release delayed txs error %v", err)
 	}
-	if res == nil {
-		return
+	items, _ := res.([]interface{})
+	members := make([]string, 0, len(items))
+	for _, item := range items {
+		if member, ok := item.(string); ok {
+			members = append(members, member)
+		}
 	}
-	score = int64(res.Score)
-	tx = new(msg.Tx)
-	err = tx.Decode(res.Member.(string))
-	return
+	return decodeDelayed(members), nil
 }
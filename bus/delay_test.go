@@ -0,0 +1,52 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestRedisDelayedTxBus(t *testing.T) {
+	ctx := context.Background()
+	db := testRedis(t)
+	b := NewRedisDelayedTxBus(db)
+	now := time.Now().Unix()
+	b.Delay(ctx, &msg.Tx{PolyHash: "later", DstChainId: 2}, now+600)
+	b.Delay(ctx, &msg.Tx{PolyHash: "due1", DstChainId: 2}, now-2)
+	b.Delay(ctx, &msg.Tx{PolyHash: "due2", DstChainId: 2}, now-1)
+	b.Delay(ctx, &msg.Tx{PolyHash: "other", DstChainId: 6}, now-1)
+	db.ZAdd(ctx, NewDelayedKey(2).Key(), &redis.Z{Score: float64(now - 3), Member: "malformed"})
+	db.ZAdd(ctx, NewDelayedKey(0).Key(), &redis.Z{Score: float64(now - 1), Member: (&msg.Tx{PolyHash: "legacy"}).Encode()})
+
+	if chains, _ := b.Chains(ctx); len(chains) != 2 || chains[0] != 2 || chains[1] != 6 {
+		t.Fatalf("Unexpected chains %v", chains)
+	}
+	if n, _ := b.Len(ctx); n != 5 {
+		t.Fatalf("Expect 5 due delayed txs, got %v", n)
+	}
+	if n, _ := b.LenOf(ctx, 2); n != 3 {
+		t.Fatalf("Expect 3 due txs of chain, got %v", n)
+	}
+
+	// Malformed member is dropped without losing the rest of the batch
+	txs, err := b.Release(ctx, 2, now, 2)
+	if err != nil || len(txs) != 1 || txs[0].PolyHash != "due1" {
+		t.Fatalf("Unexpected release %+v %v", txs, err)
+	}
+	txs, _ = b.Release(ctx, 2, now, 10)
+	if len(txs) != 1 || txs[0].PolyHash != "due2" {
+		t.Fatalf("Unexpected release %+v", txs)
+	}
+	if n, _ := b.LenOf(ctx, 2); n != 0 {
+		t.Fatalf("Expect no due tx left, got %v", n)
+	}
+	if n, _ := db.ZCard(ctx, NewDelayedKey(2).Key()).Result(); n != 1 {
+		t.Fatalf("Expect not due tx kept, got %v", n)
+	}
+	if txs, _ = b.Release(ctx, 0, now, 10); len(txs) != 1 || txs[0].PolyHash != "legacy" {
+		t.Fatalf("Unexpected legacy release %+v", txs)
+	}
+}
@@ -0,0 +1,284 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+var ERR_NOT_LEADER = errors.New("Leadership lost")
+
+var (
+	// Take the lease if vacant with a new fencing token
+	acquireScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return false
+end
+local token = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], ARGV[1] .. '|' .. token, 'PX', ARGV[2])
+return token`)
+
+	// Extend the lease if still held by the term
+	renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`)
+
+	// Drop the lease if still held by the term
+	resignScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+// Lease based leader election, every new term gets an increasing fencing token
+type Election interface {
+	Acquire(ctx context.Context, holder string, ttl time.Duration) (token uint64, ok bool, err error)
+	Renew(ctx context.Context, holder string, token uint64, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, holder string, token uint64) error
+	Leader(context.Context) (holder string, token uint64, err error) // Current term, empty holder if vacant
+}
+
+func NewElectionKey(name string) QueueKey {
+	return QueueKey("leader:" + name)
+}
+
+func termValue(holder string, token uint64) string {
+	return fmt.Sprintf("%s|%d", holder, token)
+}
+
+func parseTerm(value string) (holder string, token uint64) {
+	i := strings.LastIndex(value, "|")
+	if i < 0 {
+		return value, 0
+	}
+	token, _ = strconv.ParseUint(value[i+1:], 10, 64)
+	return value[:i], token
+}
+
+type RedisElection struct {
+	Key
+	db redis.UniversalClient
+}
+
+func NewRedisElection(db redis.UniversalClient, name string) *RedisElection {
+	return &RedisElection{NewElectionKey(name), db}
+}
+
+func (e *RedisElection) keys() []string {
+	key := e.Key.Key()
+	return []string{key, key + ":token"}
+}
+
+func (e *RedisElection) Acquire(ctx context.Context, holder string, ttl time.Duration) (token uint64, ok bool, err error) {
+	v, err := acquireScript.Run(ctx, e.db, e.keys(), holder, ttl.Milliseconds()).Int64()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("Failed to acquire leader lease %v", err)
+	}
+	return uint64(v), true, nil
+}
+
+func (e *RedisElection) Renew(ctx context.Context, holder string, token uint64, ttl time.Duration) (bool, error) {
+	v, err := renewScript.Run(ctx, e.db, e.keys()[:1], termValue(holder, token), ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, fmt.Errorf("Failed to renew leader lease %v", err)
+	}
+	return v == 1, nil
+}
+
+func (e *RedisElection) Release(ctx context.Context, holder string, token uint64) error {
+	err := resignScript.Run(ctx, e.db, e.keys()[:1], termValue(holder, token)).Err()
+	if err != nil {
+		return fmt.Errorf("Failed to release leader lease %v", err)
+	}
+	return nil
+}
+
+func (e *RedisElection) Leader(ctx context.Context) (holder string, token uint64, err error) {
+	v, err := e.db.Get(ctx, e.Key.Key()).Result()
+	if err == redis.Nil {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to get leader %v", err)
+	}
+	holder, token = parseTerm(v)
+	return
+}
+
+type MemoryElection struct {
+	Key
+	db *MemoryDB
+}
+
+func NewMemoryElection(db *MemoryDB, name string) *MemoryElection {
+	return &MemoryElection{NewElectionKey(name), db}
+}
+
+func (e *MemoryElection) Acquire(ctx context.Context, holder string, ttl time.Duration) (token uint64, ok bool, err error) {
+	e.db.Lock()
+	defer e.db.Unlock()
+	key := e.Key.Key()
+	if _, ok := e.db.get(key); ok {
+		return 0, false, nil
+	}
+	if v, ok := e.db.get(key + ":token"); ok {
+		token, _ = strconv.ParseUint(v.value, 10, 64)
+	}
+	token++
+	e.db.set(key+":token", strconv.FormatUint(token, 10), 0)
+	e.db.set(key, termValue(holder, token), ttl)
+	return token, true, nil
+}
+
+func (e *MemoryElection) Renew(ctx context.Context, holder string, token uint64, ttl time.Duration) (bool, error) {
+	return e.db.CompareAndExpire(e.Key.Key(), termValue(holder, token), ttl), nil
+}
+
+func (e *MemoryElection) Release(ctx context.Context, holder string, token uint64) error {
+	e.db.CompareAndDel(e.Key.Key(), termValue(holder, token))
+	return nil
+}
+
+func (e *MemoryElection) Leader(ctx context.Context) (holder string, token uint64, err error) {
+	v, ok := e.db.Get(e.Key.Key())
+	if ok {
+		holder, token = parseTerm(v)
+	}
+	return
+}
+
+// Campaigns for the leadership and keeps the lease renewed while leading
+type Leader struct {
+	Election
+	name   string
+	holder string
+	ttl    time.Duration
+	token  uint64
+}
+
+func NewLeader(conf *config.BusConfig, name string) *Leader {
+	ttl := config.LEADER_TTL * time.Second
+	if conf != nil && conf.LeaderTTL > 0 {
+		ttl = conf.LeaderLease()
+	}
+	return &Leader{Election: NewElection(conf, name), name: name, holder: consumer, ttl: ttl}
+}
+
+// Fencing token of the current term, zero when not leading
+func (l *Leader) Token() uint64 {
+	return atomic.LoadUint64(&l.token)
+}
+
+// Block till elected, the returned context is canceled once the leadership is lost
+func (l *Leader) Campaign(ctx context.Context) (context.Context, error) {
+	interval := l.ttl / 3
+	standby := false
+	for {
+		token, ok, err := l.Acquire(ctx, l.holder, l.ttl)
+		if err != nil {
+			log.Error("Leader campaign failure", "name", l.name, "err", err)
+		} else if ok {
+			atomic.StoreUint64(&l.token, token)
+			log.Info("Elected as leader", "name", l.name, "holder", l.holder, "token", token)
+			term, cancel := context.WithCancel(ctx)
+			go l.keep(term, cancel, token)
+			return term, nil
+		} else if !standby {
+			standby = true
+			holder, token, _ := l.Leader(ctx)
+			log.Info("Standing by for leadership", "name", l.name, "leader", holder, "token", token)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Renew the lease till the term ends, the term ends if not renewed within the ttl
+func (l *Leader) keep(ctx context.Context, cancel context.CancelFunc, token uint64) {
+	defer cancel()
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := l.Renew(ctx, l.holder, token, l.ttl)
+		if err == nil && !ok {
+			log.Warn("Leadership taken over", "name", l.name, "token", token)
+			atomic.CompareAndSwapUint64(&l.token, token, 0)
+			return
+		}
+		if err == nil {
+			renewed = time.Now()
+		} else if time.Since(renewed) >= l.ttl {
+			log.Error("Leadership expired for lease renewal failure", "name", l.name, "token", token, "err", err)
+			atomic.CompareAndSwapUint64(&l.token, token, 0)
+			return
+		} else {
+			log.Error("Leader lease renewal failure", "name", l.name, "token", token, "err", err)
+		}
+	}
+}
+
+// Check the fencing token is still the current term before writes
+func (l *Leader) Fence(ctx context.Context) error {
+	token := l.Token()
+	if token == 0 {
+		return ERR_NOT_LEADER
+	}
+	holder, current, err := l.Leader(ctx)
+	if err != nil {
+		return err
+	}
+	if holder != l.holder || current != token {
+		return ERR_NOT_LEADER
+	}
+	return nil
+}
+
+// Step down and release the lease for standby replicas to take over immediately
+func (l *Leader) Resign(ctx context.Context) error {
+	token := atomic.SwapUint64(&l.token, 0)
+	if token == 0 {
+		return nil
+	}
+	log.Info("Resigning leadership", "name", l.name, "token", token)
+	return l.Release(ctx, l.holder, token)
+}
@@ -0,0 +1,72 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryElection(t *testing.T) {
+	e := NewMemoryElection(NewMemoryDB(), "test")
+	ctx := context.Background()
+	token, ok, _ := e.Acquire(ctx, "a", time.Minute)
+	if !ok || token != 1 {
+		t.Fatalf("Expect elected with token 1, got %v %v", ok, token)
+	}
+	if _, ok, _ = e.Acquire(ctx, "b", time.Minute); ok {
+		t.Fatal("Expect lease held by a")
+	}
+	if ok, _ = e.Renew(ctx, "b", token, time.Minute); ok {
+		t.Fatal("Expect renewal of other holders to fail")
+	}
+	e.Release(ctx, "a", token)
+	token, ok, _ = e.Acquire(ctx, "b", time.Minute)
+	if !ok || token != 2 {
+		t.Fatalf("Expect b elected with token 2, got %v %v", ok, token)
+	}
+	if holder, current, _ := e.Leader(ctx); holder != "b" || current != 2 {
+		t.Fatalf("Unexpected leader %v %v", holder, current)
+	}
+}
+
+func TestLeaderTakeover(t *testing.T) {
+	e := NewMemoryElection(NewMemoryDB(), "test")
+	a := &Leader{Election: e, name: "test", holder: "a", ttl: 60 * time.Millisecond}
+	b := &Leader{Election: e, name: "test", holder: "b", ttl: 60 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	term, err := a.Campaign(ctx)
+	if err != nil || a.Fence(ctx) != nil {
+		t.Fatalf("Expect a elected, got %v", err)
+	}
+	elected := make(chan context.Context)
+	go func() {
+		term, _ := b.Campaign(ctx)
+		elected <- term
+	}()
+	select {
+	case <-elected:
+		t.Fatal("Expect b standing by while a renews the lease")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Takeover without resigning, the stale term is fenced
+	e.db.Del(e.Key.Key())
+	<-elected
+	if b.Token() != 2 || b.Fence(ctx) != nil {
+		t.Fatalf("Expect b elected with token 2, got %v", b.Token())
+	}
+	if a.Fence(ctx) != ERR_NOT_LEADER {
+		t.Fatal("Expect a fenced")
+	}
+	select {
+	case <-term.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expect term of a to end")
+	}
+	b.Resign(ctx)
+	if holder, _, _ := e.Leader(ctx); holder != "" {
+		t.Fatalf("Expect lease released, got %v", holder)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// High priority pops served for every normal priority pop when both lanes are busy
+const PRIORITY_WEIGHT = 4
+
+// Lanes of a tx queue from the highest priority
+var LANES = []int{msg.PRIORITY_HIGH, msg.PRIORITY_NORMAL}
+
+// Key of the lane, keys of other types have a single lane
+func LaneKey(key Key, priority int) Key {
+	switch k := key.(type) {
+	case *TxQueueKey:
+		return &TxQueueKey{ChainId: k.ChainId, TxType: k.TxType, Priority: priority}
+	case *StreamKey:
+		return &StreamKey{ChainId: k.ChainId, TxType: k.TxType, Priority: priority}
+	}
+	return key
+}
+
+func laneSuffix(priority int) string {
+	if priority == msg.PRIORITY_NORMAL {
+		return ""
+	}
+	return ":p" + strconv.Itoa(priority)
+}
+
+// Weighted lane order of pops
+type lanes struct {
+	weight uint64
+	count  uint64
+}
+
+func newLanes(weight uint64) *lanes {
+	if weight == 0 {
+		weight = PRIORITY_WEIGHT
+	}
+	return &lanes{weight: weight}
+}
+
+// Lanes to try in order for the next pop, the lowest lane goes first once every weight+1 pops
+func (l *lanes) order() []int {
+	if l == nil || atomic.AddUint64(&l.count, 1)%(l.weight+1) != 0 {
+		return LANES
+	}
+	order := make([]int, len(LANES))
+	for i, p := range LANES {
+		order[len(LANES)-1-i] = p
+	}
+	return order
+}
+
+// Lane keys of the queue in pop order
+func (l *lanes) keys(key Key) []string {
+	return laneKeys(key, l.order())
+}
+
+// Keys of the lanes in order, single key if the queue has no lanes
+func laneKeys(key Key, order []int) []string {
+	switch key.(type) {
+	case *TxQueueKey, *StreamKey:
+	default:
+		return []string{key.Key()}
+	}
+	keys := []string{}
+	for _, p := range order {
+		keys = append(keys, LaneKey(key, p).Key())
+	}
+	return keys
+}
@@ -0,0 +1,679 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+var memory = NewMemoryDB()
+
+// Process wide in-memory store, shared by all the memory buses just like a single redis db
+func Memory() *MemoryDB {
+	return memory
+}
+
+type memoryValue struct {
+	value  string
+	expiry time.Time
+}
+
+// MemoryDB keeps lists, sorted sets, hashes and plain values in process, it mimics the subset of
+// redis commands used by the buses, including the blocking pops.
+type MemoryDB struct {
+	sync.Mutex
+	lists  map[string][]string
+	zsets  map[string]map[string]float64
+	hashes map[string]map[string]string
+	values map[string]*memoryValue
+	notify chan struct{}
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		lists:  map[string][]string{},
+		zsets:  map[string]map[string]float64{},
+		hashes: map[string]map[string]string{},
+		values: map[string]*memoryValue{},
+		notify: make(chan struct{}),
+	}
+}
+
+// Wake up all the blocking waiters, should be called with lock held
+func (m *MemoryDB) changed() {
+	close(m.notify)
+	m.notify = make(chan struct{})
+}
+
+// Block till f returns true or timeout(returns false), f is called with lock held
+func (m *MemoryDB) block(ctx context.Context, timeout time.Duration, f func() bool) (ok bool, err error) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		m.Lock()
+		ok = f()
+		ch := m.notify
+		m.Unlock()
+		if ok {
+			return
+		}
+		select {
+		case <-ch:
+		case <-deadline:
+			return false, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+func (m *MemoryDB) RPush(key string, values ...string) {
+	m.Lock()
+	defer m.Unlock()
+	m.lists[key] = append(m.lists[key], values...)
+	m.changed()
+}
+
+func (m *MemoryDB) LPush(key string, values ...string) {
+	m.Lock()
+	defer m.Unlock()
+	list := make([]string, 0, len(values)+len(m.lists[key]))
+	for i := len(values) - 1; i >= 0; i-- {
+		list = append(list, values[i])
+	}
+	m.lists[key] = append(list, m.lists[key]...)
+	m.changed()
+}
+
+func (m *MemoryDB) LLen(key string) uint64 {
+	m.Lock()
+	defer m.Unlock()
+	return uint64(len(m.lists[key]))
+}
+
+// Values from the list head, count <= 0 means no limit
+func (m *MemoryDB) LRange(key string, count int64) []string {
+	m.Lock()
+	defer m.Unlock()
+	list := m.lists[key]
+	if count > 0 && int64(len(list)) > count {
+		list = list[:count]
+	}
+	return append([]string{}, list...)
+}
+
+// Remove the first occurrence of the value
+func (m *MemoryDB) LRem(key, value string) bool {
+	m.Lock()
+	defer m.Unlock()
+	list := m.lists[key]
+	for i, v := range list {
+		if v == value {
+			m.lists[key] = append(list[:i:i], list[i+1:]...)
+			if len(m.lists[key]) == 0 {
+				delete(m.lists, key)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Pop from the head of the first non empty list, should be called with lock held
+func (m *MemoryDB) lpop(keys ...string) (key, value string, ok bool) {
+	for _, key = range keys {
+		list := m.lists[key]
+		if len(list) > 0 {
+			value = list[0]
+			if len(list) == 1 {
+				delete(m.lists, key)
+			} else {
+				m.lists[key] = list[1:]
+			}
+			return key, value, true
+		}
+	}
+	return "", "", false
+}
+
+// Blocking pop with timeout, zero timeout blocks forever, empty key is returned on timeout
+func (m *MemoryDB) BLPop(ctx context.Context, timeout time.Duration, keys ...string) (key, value string, err error) {
+	_, err = m.block(ctx, timeout, func() (ok bool) {
+		key, value, ok = m.lpop(keys...)
+		return
+	})
+	return
+}
+
+func (m *MemoryDB) ZAdd(key string, score float64, member string) {
+	m.Lock()
+	defer m.Unlock()
+	set, ok := m.zsets[key]
+	if !ok {
+		set = map[string]float64{}
+		m.zsets[key] = set
+	}
+	set[member] = score
+	m.changed()
+}
+
+func (m *MemoryDB) ZCount(key string, min, max float64) uint64 {
+	m.Lock()
+	defer m.Unlock()
+	count := uint64(0)
+	for _, score := range m.zsets[key] {
+		if score >= min && score <= max {
+			count++
+		}
+	}
+	return count
+}
+
+type memoryMember struct {
+	member string
+	score  float64
+}
+
+// Members sorted by score then member like redis does, should be called with lock held
+func (m *MemoryDB) zsorted(key string) []memoryMember {
+	set := m.zsets[key]
+	members := make([]memoryMember, 0, len(set))
+	for member, score := range set {
+		members = append(members, memoryMember{member, score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].score == members[j].score {
+			return members[i].member < members[j].member
+		}
+		return members[i].score < members[j].score
+	})
+	return members
+}
+
+// Members with score not higher than max, count <= 0 means no limit
+func (m *MemoryDB) ZRangeByScore(key string, max float64, count int64) (members []string) {
+	m.Lock()
+	defer m.Unlock()
+	for _, item := range m.zsorted(key) {
+		if item.score > max || (count > 0 && int64(len(members)) >= count) {
+			break
+		}
+		members = append(members, item.member)
+	}
+	return
+}
+
+// Remove and return members with score not higher than max, count <= 0 means no limit
+func (m *MemoryDB) ZPopByScore(key string, max float64, count int64) (members []string) {
+	m.Lock()
+	defer m.Unlock()
+	for _, item := range m.zsorted(key) {
+		if item.score > max || (count > 0 && int64(len(members)) >= count) {
+			break
+		}
+		members = append(members, item.member)
+		delete(m.zsets[key], item.member)
+	}
+	if len(m.zsets[key]) == 0 {
+		delete(m.zsets, key)
+	}
+	return
+}
+
+// Members with scores from the lowest score, count <= 0 means no limit
+func (m *MemoryDB) ZRange(key string, count int64) (members []string, scores []float64) {
+	m.Lock()
+	defer m.Unlock()
+	for _, item := range m.zsorted(key) {
+		if count > 0 && int64(len(members)) >= count {
+			break
+		}
+		members = append(members, item.member)
+		scores = append(scores, item.score)
+	}
+	return
+}
+
+func (m *MemoryDB) ZRem(key, member string) bool {
+	m.Lock()
+	defer m.Unlock()
+	set := m.zsets[key]
+	if _, ok := set[member]; !ok {
+		return false
+	}
+	delete(set, member)
+	if len(set) == 0 {
+		delete(m.zsets, key)
+	}
+	return true
+}
+
+func (m *MemoryDB) ZCard(key string) uint64 {
+	m.Lock()
+	defer m.Unlock()
+	return uint64(len(m.zsets[key]))
+}
+
+func (m *MemoryDB) zpopmin(keys ...string) (member string, score float64, ok bool) {
+	for _, key := range keys {
+		members := m.zsorted(key)
+		if len(members) > 0 {
+			member, score = members[0].member, members[0].score
+			delete(m.zsets[key], member)
+			if len(m.zsets[key]) == 0 {
+				delete(m.zsets, key)
+			}
+			return member, score, true
+		}
+	}
+	return
+}
+
+// Blocking pop of the member with the lowest score, zero timeout blocks forever
+func (m *MemoryDB) BZPopMin(ctx context.Context, timeout time.Duration, keys ...string) (member string, score float64, ok bool, err error) {
+	_, err = m.block(ctx, timeout, func() bool {
+		member, score, ok = m.zpopmin(keys...)
+		return ok
+	})
+	return
+}
+
+func (m *MemoryDB) HSet(key, field, value string) {
+	m.Lock()
+	defer m.Unlock()
+	hash, ok := m.hashes[key]
+	if !ok {
+		hash = map[string]string{}
+		m.hashes[key] = hash
+	}
+	hash[field] = value
+}
+
+func (m *MemoryDB) HGet(key, field string) (value string, ok bool) {
+	m.Lock()
+	defer m.Unlock()
+	value, ok = m.hashes[key][field]
+	return
+}
+
+func (m *MemoryDB) HGetAll(key string) map[string]string {
+	m.Lock()
+	defer m.Unlock()
+	hash := map[string]string{}
+	for field, value := range m.hashes[key] {
+		hash[field] = value
+	}
+	return hash
+}
+
+func (m *MemoryDB) HDel(key, field string) bool {
+	m.Lock()
+	defer m.Unlock()
+	_, ok := m.hashes[key][field]
+	delete(m.hashes[key], field)
+	return ok
+}
+
+// Get value of the key, should be called with lock held
+func (m *MemoryDB) get(key string) (*memoryValue, bool) {
+	v, ok := m.values[key]
+	if ok && !v.expiry.IsZero() && !time.Now().Before(v.expiry) {
+		delete(m.values, key)
+		return nil, false
+	}
+	return v, ok
+}
+
+func (m *MemoryDB) set(key, value string, ttl time.Duration) {
+	v := &memoryValue{value: value}
+	if ttl > 0 {
+		v.expiry = time.Now().Add(ttl)
+	}
+	m.values[key] = v
+}
+
+// Set value with ttl, zero ttl means no expiration
+func (m *MemoryDB) Set(key, value string, ttl time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.set(key, value, ttl)
+}
+
+func (m *MemoryDB) SetNX(key, value string, ttl time.Duration) bool {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.get(key); ok {
+		return false
+	}
+	m.set(key, value, ttl)
+	return true
+}
+
+func (m *MemoryDB) Get(key string) (value string, ok bool) {
+	m.Lock()
+	defer m.Unlock()
+	v, ok := m.get(key)
+	if ok {
+		value = v.value
+	}
+	return
+}
+
+func (m *MemoryDB) Del(keys ...string) {
+	m.Lock()
+	defer m.Unlock()
+	for _, key := range keys {
+		delete(m.values, key)
+		delete(m.lists, key)
+		delete(m.zsets, key)
+		delete(m.hashes, key)
+	}
+}
+
+func (m *MemoryDB) Incr(key string) int64 {
+	m.Lock()
+	defer m.Unlock()
+	var n int64
+	if v, ok := m.get(key); ok {
+		n, _ = strconv.ParseInt(v.value, 10, 64)
+	}
+	n++
+	m.set(key, strconv.FormatInt(n, 10), 0)
+	return n
+}
+
+// Reset the ttl if the key holds the value
+func (m *MemoryDB) CompareAndExpire(key, value string, ttl time.Duration) bool {
+	m.Lock()
+	defer m.Unlock()
+	if v, ok := m.get(key); !ok || v.value != value {
+		return false
+	}
+	m.set(key, value, ttl)
+	return true
+}
+
+// Delete the key if it holds the value
+func (m *MemoryDB) CompareAndDel(key, value string) bool {
+	m.Lock()
+	defer m.Unlock()
+	if v, ok := m.get(key); !ok || v.value != value {
+		return false
+	}
+	delete(m.values, key)
+	return true
+}
+
+type MemoryTxBus struct {
+	Key
+	db    *MemoryDB
+	lanes *lanes
+}
+
+func NewMemoryTxBus(db *MemoryDB, chainId uint64, txType msg.TxType) *MemoryTxBus {
+	return &MemoryTxBus{&TxQueueKey{ChainId: chainId, TxType: txType}, db, newLanes(0)}
+}
+
+func NewMemoryPatchTxBus(db *MemoryDB, chainId uint64) *MemoryTxBus {
+	return &MemoryTxBus{NewPatchKey(chainId), db, nil}
+}
+
+func (b *MemoryTxBus) Topic() string {
+	return b.Key.Key()
+}
+
+func (b *MemoryTxBus) Pop(ctx context.Context) (*msg.Tx, error) {
+	return b.PopTimed(ctx, 0)
+}
+
+func (b *MemoryTxBus) PopTimed(ctx context.Context, duration time.Duration) (*msg.Tx, error) {
+	_, res, err := b.db.BLPop(ctx, duration, b.lanes.keys(b.Key)...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to pop message %v", err)
+	}
+	if res == "" {
+		return nil, nil
+	}
+	tx := new(msg.Tx)
+	err = tx.Decode(res)
+	return tx, err
+}
+
+func (b *MemoryTxBus) PushToChain(ctx context.Context, tx *msg.Tx) error {
+	b.db.RPush(GetQueue(tx).Key(), encodeQueued(tx))
+	return nil
+}
+
+func (b *MemoryTxBus) Patch(ctx context.Context, tx *msg.Tx) error {
+	chain := tx.SrcChainId
+	if tx.Type() == msg.POLY {
+		chain = base.POLY
+	}
+	b.db.RPush(NewPatchKey(chain).Key(), encodeQueued(tx))
+	return nil
+}
+
+func (b *MemoryTxBus) Push(ctx context.Context, tx *msg.Tx) error {
+	b.db.RPush(LaneKey(b.Key, tx.Priority).Key(), encodeQueued(tx))
+	return nil
+}
+
+func (b *MemoryTxBus) PushBack(ctx context.Context, tx *msg.Tx) error {
+	b.db.LPush(GetQueue(tx).Key(), encodeQueued(tx))
+	return nil
+}
+
+func (b *MemoryTxBus) Len(ctx context.Context) (total uint64, err error) {
+	for _, key := range laneKeys(b.Key, LANES) {
+		total += b.db.LLen(key)
+	}
+	return
+}
+
+func (b *MemoryTxBus) LenOf(ctx context.Context, chain uint64, ty msg.TxType) (uint64, error) {
+	return (&MemoryTxBus{Key: &TxQueueKey{ChainId: chain, TxType: ty}, db: b.db}).Len(ctx)
+}
+
+func (b *MemoryTxBus) Ack(ctx context.Context, tx *msg.Tx) error {
+	return nil
+}
+
+func (b *MemoryTxBus) Nack(ctx context.Context, tx *msg.Tx) error {
+	return b.Push(ctx, tx)
+}
+
+type MemorySortedTxBus struct {
+	Key
+	db *MemoryDB
+}
+
+func NewMemorySortedTxBus(db *MemoryDB, chainId uint64, txType msg.TxType) *MemorySortedTxBus {
+	return &MemorySortedTxBus{&SortedTxQueueKey{ChainId: chainId, TxType: txType}, db}
+}
+
+func (b *MemorySortedTxBus) Topic() string {
+	return b.Key.Key()
+}
+
+func (b *MemorySortedTxBus) Len(ctx context.Context) (uint64, error) {
+	return b.db.ZCount(b.Key.Key(), 0, math.Inf(1)), nil
+}
+
+func (b *MemorySortedTxBus) Push(ctx context.Context, tx *msg.Tx, height uint64) error {
+	b.db.ZAdd(b.Key.Key(), float64(height), tx.Encode())
+	return nil
+}
+
+func (b *MemorySortedTxBus) Range(ctx context.Context, height uint64, count int64) (txs []*msg.Tx, err error) {
+	for _, item := range b.db.ZRangeByScore(b.Key.Key(), float64(height), count) {
+		tx := new(msg.Tx)
+		e := tx.Decode(item)
+		if e != nil {
+			err = e
+		}
+		txs = append(txs, tx)
+	}
+	return
+}
+
+func (b *MemorySortedTxBus) Pop(ctx context.Context) (tx *msg.Tx, score uint64, err error) {
+	member, value, ok, err := b.db.BZPopMin(ctx, 0, b.Key.Key())
+	if err != nil || !ok {
+		return
+	}
+	score = uint64(value)
+	tx = new(msg.Tx)
+	err = tx.Decode(member)
+	return
+}
+
+func (b *MemorySortedTxBus) Ack(ctx context.Context, tx *msg.Tx) error {
+	return nil
+}
+
+func (b *MemorySortedTxBus) Nack(ctx context.Context, tx *msg.Tx, score uint64) error {
+	return b.Push(ctx, tx, score)
+}
+
+type MemoryDelayedTxBus struct {
+	Key
+	db *MemoryDB
+}
+
+func NewMemoryDelayedTxBus(db *MemoryDB) *MemoryDelayedTxBus {
+	return &MemoryDelayedTxBus{DelayedChainsKey, db}
+}
+
+func (b *MemoryDelayedTxBus) Topic() string {
+	return b.Key.Key()
+}
+
+func (b *MemoryDelayedTxBus) Chains(ctx context.Context) ([]uint64, error) {
+	members := []string{}
+	for member := range b.db.HGetAll(b.Key.Key()) {
+		members = append(members, member)
+	}
+	return parseChains(members), nil
+}
+
+func (b *MemoryDelayedTxBus) Len(ctx context.Context) (total uint64, err error) {
+	chains, _ := b.Chains(ctx)
+	for _, chain := range append(chains, 0) {
+		size, _ := b.LenOf(ctx, chain)
+		total += size
+	}
+	return
+}
+
+func (b *MemoryDelayedTxBus) LenOf(ctx context.Context, chain uint64) (uint64, error) {
+	return b.db.ZCount(NewDelayedKey(chain).Key(), 0, float64(time.Now().Unix())), nil
+}
+
+func (b *MemoryDelayedTxBus) Delay(ctx context.Context, tx *msg.Tx, delay int64) error {
+	b.db.ZAdd(NewDelayedKey(tx.DstChainId).Key(), float64(delay), tx.Encode())
+	if tx.DstChainId != 0 {
+		b.db.HSet(b.Key.Key(), strconv.FormatUint(tx.DstChainId, 10), "1")
+	}
+	return nil
+}
+
+func (b *MemoryDelayedTxBus) Release(ctx context.Context, chain uint64, now int64, count int) ([]*msg.Tx, error) {
+	return decodeDelayed(b.db.ZPopByScore(NewDelayedKey(chain).Key(), float64(now), int64(count))), nil
+}
+
+type MemoryChainStore struct {
+	Key
+	db    *MemoryDB
+	timer *time.Ticker
+}
+
+func NewMemoryChainStore(key Key, db *MemoryDB, interval uint64) *MemoryChainStore {
+	if interval == 0 {
+		interval = 5
+	}
+	return &MemoryChainStore{
+		Key:   key,
+		db:    db,
+		timer: time.NewTicker(time.Duration(interval) * time.Second),
+	}
+}
+
+func (s *MemoryChainStore) UpdateHeight(ctx context.Context, height uint64) error {
+	s.db.Set(s.Key.Key(), strconv.FormatUint(height, 10), 0)
+	return nil
+}
+
+func (s *MemoryChainStore) HeightMark(height uint64) error {
+	select {
+	case <-s.timer.C:
+	default:
+		return nil
+	}
+	return s.UpdateHeight(context.Background(), height)
+}
+
+func (s *MemoryChainStore) GetHeight(ctx context.Context) (height uint64, err error) {
+	v, ok := s.db.Get(s.Key.Key())
+	if !ok {
+		return 0, fmt.Errorf("Get chain height error key %s not found", s.Key.Key())
+	}
+	h, _ := strconv.Atoi(v)
+	height = uint64(h)
+	return
+}
+
+type MemorySkipCheck struct {
+	Key
+	db *MemoryDB
+}
+
+func NewMemorySkipCheck(db *MemoryDB) *MemorySkipCheck {
+	return &MemorySkipCheck{String("skip_map"), db}
+}
+
+func (b *MemorySkipCheck) Skip(ctx context.Context, tx *msg.Tx) (err error) {
+	for _, hash := range formatHashes(tx.SrcHash, tx.PolyHash) {
+		b.db.HSet(b.Key.Key(), hash, "true")
+		log.Info("Tx marked to skip", "hash", hash)
+	}
+	return
+}
+
+func (b *MemorySkipCheck) CheckSkip(ctx context.Context, tx *msg.Tx) (skip bool, err error) {
+	for _, hash := range formatHashes(tx.SrcHash, tx.PolyHash) {
+		res, _ := b.db.HGet(b.Key.Key(), hash)
+		if res == "true" {
+			return true, nil
+		}
+	}
+	return
+}
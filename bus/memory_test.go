@@ -0,0 +1,251 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestMemoryTxBus(t *testing.T) {
+	db := NewMemoryDB()
+	b := NewMemoryTxBus(db, 2, msg.POLY)
+	ctx := context.Background()
+
+	tx, err := b.PopTimed(ctx, 10*time.Millisecond)
+	if err != nil || tx != nil {
+		t.Fatalf("Expect empty pop, got %v %v", tx, err)
+	}
+
+	b.Push(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "a", DstChainId: 2})
+	b.PushBack(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "b", DstChainId: 2})
+	if n, _ := b.Len(ctx); n != 2 {
+		t.Fatalf("Unexpected len %v", n)
+	}
+	for _, hash := range []string{"b", "a"} {
+		tx, err = b.Pop(ctx)
+		if err != nil || tx.PolyHash != hash {
+			t.Fatalf("Expect tx %s, got %+v %v", hash, tx, err)
+		}
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.PushToChain(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "c", DstChainId: 2})
+	}()
+	tx, err = b.Pop(ctx)
+	if err != nil || tx.PolyHash != "c" {
+		t.Fatalf("Expect blocking pop to receive tx, got %+v %v", tx, err)
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err = b.Pop(cctx); err == nil {
+		t.Fatal("Expect pop to return on context done")
+	}
+}
+
+func TestMemorySortedTxBus(t *testing.T) {
+	db := NewMemoryDB()
+	b := NewMemorySortedTxBus(db, 2, msg.SRC)
+	ctx := context.Background()
+	b.Push(ctx, &msg.Tx{SrcHash: "c"}, 30)
+	b.Push(ctx, &msg.Tx{SrcHash: "a"}, 10)
+	b.Push(ctx, &msg.Tx{SrcHash: "b"}, 20)
+
+	txs, err := b.Range(ctx, 20, 10)
+	if err != nil || len(txs) != 2 || txs[0].SrcHash != "a" || txs[1].SrcHash != "b" {
+		t.Fatalf("Unexpected range result %v %v", txs, err)
+	}
+	for i, hash := range []string{"a", "b", "c"} {
+		tx, score, err := b.Pop(ctx)
+		if err != nil || tx.SrcHash != hash || score != uint64(i+1)*10 {
+			t.Fatalf("Expect tx %s, got %+v %v %v", hash, tx, score, err)
+		}
+	}
+}
+
+func TestMemoryDelayedTxBus(t *testing.T) {
+	db := NewMemoryDB()
+	b := NewMemoryDelayedTxBus(db)
+	ctx := context.Background()
+	now := time.Now().Unix()
+	b.Delay(ctx, &msg.Tx{PolyHash: "later", DstChainId: 2}, now+600)
+	b.Delay(ctx, &msg.Tx{PolyHash: "due1", DstChainId: 2}, now-2)
+	b.Delay(ctx, &msg.Tx{PolyHash: "due2", DstChainId: 2}, now-1)
+	b.Delay(ctx, &msg.Tx{PolyHash: "other", DstChainId: 6}, now-1)
+	db.ZAdd(NewDelayedKey(0).Key(), float64(now-1), (&msg.Tx{PolyHash: "legacy"}).Encode())
+
+	if chains, _ := b.Chains(ctx); len(chains) != 2 || chains[0] != 2 || chains[1] != 6 {
+		t.Fatalf("Unexpected chains %v", chains)
+	}
+	if n, _ := b.Len(ctx); n != 4 {
+		t.Fatalf("Expect 4 due delayed txs, got %v", n)
+	}
+	txs, err := b.Release(ctx, 2, now, 1)
+	if err != nil || len(txs) != 1 || txs[0].PolyHash != "due1" {
+		t.Fatalf("Unexpected release %+v %v", txs, err)
+	}
+	txs, _ = b.Release(ctx, 2, now, 10)
+	if len(txs) != 1 || txs[0].PolyHash != "due2" {
+		t.Fatalf("Unexpected release %+v", txs)
+	}
+	if n, _ := b.LenOf(ctx, 2); n != 0 || db.ZCard(NewDelayedKey(2).Key()) != 1 {
+		t.Fatalf("Expect only not due tx left, got due %v", n)
+	}
+	if txs, _ = b.Release(ctx, 0, now, 10); len(txs) != 1 || txs[0].PolyHash != "legacy" {
+		t.Fatalf("Unexpected legacy release %+v", txs)
+	}
+}
+
+func TestMemoryStores(t *testing.T) {
+	db := NewMemoryDB()
+	ctx := context.Background()
+	store := NewMemoryChainStore(ChainHeightKey{ChainId: 2, Type: KEY_HEIGHT_TX}, db, 0)
+	if _, err := store.GetHeight(ctx); err == nil {
+		t.Fatal("Expect missing height error")
+	}
+	store.UpdateHeight(ctx, 100)
+	if h, err := store.GetHeight(ctx); err != nil || h != 100 {
+		t.Fatalf("Unexpected height %v %v", h, err)
+	}
+
+	skip := NewMemorySkipCheck(db)
+	skip.Skip(ctx, &msg.Tx{PolyHash: " ABC "})
+	if ok, _ := skip.CheckSkip(ctx, &msg.Tx{PolyHash: "abc"}); !ok {
+		t.Fatal("Expect tx to be skipped")
+	}
+
+	if !db.SetNX("lock", "1", 10*time.Millisecond) || db.SetNX("lock", "1", 0) {
+		t.Fatal("Unexpected setnx result")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !db.SetNX("lock", "1", 0) {
+		t.Fatal("Expect expired key to be released")
+	}
+
+	nonces := NewMemoryNonceStore(db)
+	if n, err := nonces.Get(ctx, 2, "0xABC"); err != nil || n != 0 {
+		t.Fatalf("Unexpected unknown nonce %v %v", n, err)
+	}
+	nonces.Set(ctx, 2, "0xABC", 7)
+	if n, err := nonces.Get(ctx, 2, "0xabc"); err != nil || n != 7 {
+		t.Fatalf("Unexpected nonce %v %v", n, err)
+	}
+	if n, _ := nonces.Get(ctx, 6, "0xabc"); n != 0 {
+		t.Fatalf("Expect nonces per chain, got %v", n)
+	}
+	if ok, _ := nonces.Lock(ctx, 2, "0xABC", time.Minute); !ok {
+		t.Fatal("Expect account nonce locked")
+	}
+	if ok, _ := nonces.Lock(ctx, 2, "0xabc", time.Minute); ok {
+		t.Fatal("Expect locked account nonce not locked again")
+	}
+	nonces.Unlock(ctx, 2, "0xabc")
+	if ok, _ := nonces.Lock(ctx, 2, "0xabc", time.Minute); !ok {
+		t.Fatal("Expect unlocked account nonce locked")
+	}
+}
+
+func TestRedisNonceLock(t *testing.T) {
+	ctx := context.Background()
+	db := testRedis(t)
+	nonces := NewRedisNonceStore(db)
+	if ok, err := nonces.Lock(ctx, 2, "0xabc", time.Minute); !ok || err != nil {
+		t.Fatalf("Expect account nonce locked, err %v", err)
+	}
+	if ok, _ := nonces.Lock(ctx, 2, "0xabc", time.Minute); ok {
+		t.Fatal("Expect locked account nonce not locked again")
+	}
+	nonces.Unlock(ctx, 2, "0xabc")
+	// Lock held by another instance is kept
+	key := nonceLockKey(2, "0xabc").Key()
+	db.Set(ctx, key, "other", time.Minute)
+	nonces.Unlock(ctx, 2, "0xabc")
+	if v, _ := db.Get(ctx, key).Result(); v != "other" {
+		t.Fatalf("Expect lock of another instance kept, got %v", v)
+	}
+}
+
+func TestMemoryDeadLetterBus(t *testing.T) {
+	b := NewMemoryDeadLetterBus(NewMemoryDB())
+	ctx := context.Background()
+	tx := &msg.Tx{PolyHash: "0xABC", DstChainId: 2}
+	tx.Fail(msg.ERR_TX_EXEC_ALWAYS_FAIL)
+	b.Put(ctx, &DeadLetter{Tx: tx, Reason: "test", Error: tx.LastError(), Created: 1})
+	b.Put(ctx, &DeadLetter{Tx: &msg.Tx{PolyHash: "0xdef", DstChainId: 6}, Created: 2})
+
+	list, err := b.List(ctx, 2)
+	if err != nil || len(list) != 1 || list[0].Tx.Attempts != 1 || list[0].Error != msg.ERR_TX_EXEC_ALWAYS_FAIL.Error() {
+		t.Fatalf("Unexpected dead letters %+v %v", list, err)
+	}
+	d, err := b.Get(ctx, "0xabc")
+	if err != nil || d == nil || len(d.Tx.History) != 1 {
+		t.Fatalf("Unexpected dead letter %+v %v", d, err)
+	}
+	if ok, _ := b.Remove(ctx, "0xABC"); !ok {
+		t.Fatal("Expect dead letter removed")
+	}
+	if list, _ = b.List(ctx, 0); len(list) != 1 {
+		t.Fatalf("Unexpected dead letters %+v", list)
+	}
+}
+
+func TestMemoryTxBusLanes(t *testing.T) {
+	b := NewMemoryTxBus(NewMemoryDB(), 2, msg.POLY)
+	b.lanes = newLanes(2)
+	ctx := context.Background()
+	for _, hash := range []string{"n1", "n2"} {
+		b.PushToChain(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: hash, DstChainId: 2})
+	}
+	for _, hash := range []string{"h1", "h2", "h3"} {
+		b.PushToChain(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: hash, DstChainId: 2, Priority: msg.PRIORITY_HIGH})
+	}
+	if n, _ := b.Len(ctx); n != 5 {
+		t.Fatalf("Unexpected len %v", n)
+	}
+	for _, hash := range []string{"h1", "h2", "n1", "h3", "n2"} {
+		tx, err := b.Pop(ctx)
+		if err != nil || tx.PolyHash != hash {
+			t.Fatalf("Expect tx %s, got %+v %v", hash, tx, err)
+		}
+	}
+}
+
+func TestMemoryQueueEntries(t *testing.T) {
+	db := NewMemoryDB()
+	b := NewMemoryTxBus(db, 2, msg.POLY)
+	ctx := context.Background()
+	b.Push(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "a", DstChainId: 2})
+	b.Push(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "b", DstChainId: 2, Priority: msg.PRIORITY_HIGH})
+
+	entries, err := b.Entries(ctx, 0)
+	if err != nil || len(entries) != 2 || entries[0].Tx.PolyHash != "b" || entries[0].Tx.Queued == 0 {
+		t.Fatalf("Unexpected entries %v %v", entries, err)
+	}
+	if n, _ := b.Remove(ctx, entries[0]); n != 1 {
+		t.Fatalf("Expect entry removed, got %v", n)
+	}
+	if n, _ := b.Remove(ctx, entries[0]); n != 0 {
+		t.Fatalf("Expect entry gone, got %v", n)
+	}
+	tx, err := b.Pop(ctx)
+	if err != nil || tx.PolyHash != "a" {
+		t.Fatalf("Expect tx a, got %+v %v", tx, err)
+	}
+
+	d := NewMemoryDelayedTxBus(db)
+	d.Delay(ctx, &msg.Tx{PolyHash: "c", DstChainId: 2}, 100)
+	d.Delay(ctx, &msg.Tx{PolyHash: "d", DstChainId: 6}, 200)
+	entries, err = d.Entries(ctx, 0)
+	if err != nil || len(entries) != 2 || entries[1].Score != 200 {
+		t.Fatalf("Unexpected delayed entries %v %v", entries, err)
+	}
+	if n, _ := d.Remove(ctx, entries...); n != 2 {
+		t.Fatalf("Expect delayed entries removed, got %v", n)
+	}
+	if n, _ := d.Len(ctx); n != 0 {
+		t.Fatalf("Unexpected delayed len %v", n)
+	}
+}
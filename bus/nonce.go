@@ -0,0 +1,119 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Next nonces of the submitter accounts keyed by chain and address
+var ACCOUNT_NONCES = QueueKey("nonces")
+
+// Nonces of an account shared by instances are used under the account lock
+type NonceStore interface {
+	Get(ctx context.Context, chain uint64, address string) (uint64, error) // Zero if unknown
+	Set(ctx context.Context, chain uint64, address string, nonce uint64) error
+	Lock(ctx context.Context, chain uint64, address string, ttl time.Duration) (bool, error) // False if held by another consumer
+	Unlock(ctx context.Context, chain uint64, address string) error
+}
+
+func nonceField(chain uint64, address string) string {
+	return fmt.Sprintf("%d:%s", chain, strings.ToLower(address))
+}
+
+func nonceLockKey(chain uint64, address string) QueueKey {
+	return QueueKey("nonce_lock:" + nonceField(chain, address))
+}
+
+type RedisNonceStore struct {
+	db redis.UniversalClient
+}
+
+func NewRedisNonceStore(db redis.UniversalClient) *RedisNonceStore {
+	return &RedisNonceStore{db}
+}
+
+func (s *RedisNonceStore) Get(ctx context.Context, chain uint64, address string) (uint64, error) {
+	v, err := s.db.HGet(ctx, ACCOUNT_NONCES.Key(), nonceField(chain, address)).Uint64()
+	if err == redis.Nil {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("Failed to get account nonce %v", err)
+	}
+	return v, nil
+}
+
+func (s *RedisNonceStore) Set(ctx context.Context, chain uint64, address string, nonce uint64) error {
+	err := s.db.HSet(ctx, ACCOUNT_NONCES.Key(), nonceField(chain, address), nonce).Err()
+	if err != nil {
+		return fmt.Errorf("Failed to set account nonce %v", err)
+	}
+	return nil
+}
+
+func (s *RedisNonceStore) Lock(ctx context.Context, chain uint64, address string, ttl time.Duration) (bool, error) {
+	ok, err := s.db.SetNX(ctx, nonceLockKey(chain, address).Key(), consumer, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("Failed to lock account nonce %v", err)
+	}
+	return ok, nil
+}
+
+func (s *RedisNonceStore) Unlock(ctx context.Context, chain uint64, address string) error {
+	err := resignScript.Run(ctx, s.db, []string{nonceLockKey(chain, address).Key()}, consumer).Err()
+	if err != nil {
+		return fmt.Errorf("Failed to unlock account nonce %v", err)
+	}
+	return nil
+}
+
+type MemoryNonceStore struct {
+	db *MemoryDB
+}
+
+func NewMemoryNonceStore(db *MemoryDB) *MemoryNonceStore {
+	return &MemoryNonceStore{db}
+}
+
+func (s *MemoryNonceStore) Get(ctx context.Context, chain uint64, address string) (uint64, error) {
+	v, ok := s.db.HGet(ACCOUNT_NONCES.Key(), nonceField(chain, address))
+	if !ok {
+		return 0, nil
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+func (s *MemoryNonceStore) Set(ctx context.Context, chain uint64, address string, nonce uint64) error {
+	s.db.HSet(ACCOUNT_NONCES.Key(), nonceField(chain, address), strconv.FormatUint(nonce, 10))
+	return nil
+}
+
+func (s *MemoryNonceStore) Lock(ctx context.Context, chain uint64, address string, ttl time.Duration) (bool, error) {
+	return s.db.SetNX(nonceLockKey(chain, address).Key(), consumer, ttl), nil
+}
+
+func (s *MemoryNonceStore) Unlock(ctx context.Context, chain uint64, address string) error {
+	s.db.CompareAndDel(nonceLockKey(chain, address).Key(), consumer)
+	return nil
+}
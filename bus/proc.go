@@ -20,6 +20,7 @@ package bus
 import (
 	"context"
 	"fmt"
+	"runtime/debug"
 	"time"
 
 	"github.com/polynetwork/bridge-common/log"
@@ -59,3 +60,33 @@ func Retry(ctx context.Context, f func() error, interval time.Duration, count in
 		}
 	}
 }
+
+type failureKey struct{}
+
+// Context of a supervised role, recovered panics of its goroutines are reported as the role failure
+func WithFailure(ctx context.Context, report func(error)) context.Context {
+	return context.WithValue(ctx, failureKey{}, report)
+}
+
+// Report the failure to the supervisor of the context, false if unsupervised
+func ReportFailure(ctx context.Context, err error) bool {
+	report, ok := ctx.Value(failureKey{}).(func(error))
+	if ok {
+		report(err)
+	}
+	return ok
+}
+
+// Recover panic of the goroutine and report it to the supervisor, panics again if unsupervised.
+// Should be deferred directly in the goroutine.
+func Recover(ctx context.Context) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	err := fmt.Errorf("Panic %v", r)
+	log.Error("Recovered goroutine panic", "err", err, "stack", string(debug.Stack()))
+	if !ReportFailure(ctx, err) {
+		panic(r)
+	}
+}
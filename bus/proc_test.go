@@ -0,0 +1,45 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	var failure error
+	ctx := WithFailure(context.Background(), func(err error) { failure = err })
+	func() {
+		defer Recover(ctx)
+		panic("boom")
+	}()
+	if failure == nil || failure.Error() != "Panic boom" {
+		t.Fatalf("Expect reported panic, got %v", failure)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expect panic again when unsupervised")
+		}
+	}()
+	func() {
+		defer Recover(context.Background())
+		panic("boom")
+	}()
+}
@@ -0,0 +1,282 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Tx sitting in a queue, the key and id locate the entry for removal
+type Entry struct {
+	Key   string `json:"-"`
+	Id    string `json:"-"`          // Raw member of lists and sorted sets or stream message id
+	Score int64  `json:",omitempty"` // Score of sorted txs or visible time of delayed txs
+	Tx    *msg.Tx
+}
+
+// Seconds since the tx was first queued, zero if unknown
+func (e *Entry) Age() int64 {
+	if e.Tx.Queued == 0 {
+		return 0
+	}
+	return time.Now().Unix() - e.Tx.Queued
+}
+
+func newEntry(key, id, payload string, score int64) (*Entry, error) {
+	tx := new(msg.Tx)
+	err := tx.Decode(payload)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode queued tx %v", err)
+	}
+	return &Entry{Key: key, Id: id, Score: score, Tx: tx}, nil
+}
+
+// Encode the tx to push into a queue, stamping the time first queued
+func encodeQueued(tx *msg.Tx) string {
+	if tx.Queued == 0 {
+		tx.Queued = time.Now().Unix()
+	}
+	return tx.Encode()
+}
+
+// Last index for a range of count items, zero count for all
+func rangeStop(count int64) int64 {
+	if count <= 0 {
+		return -1
+	}
+	return count - 1
+}
+
+func limitEntries(entries []*Entry, count int64) []*Entry {
+	if count > 0 && int64(len(entries)) > count {
+		return entries[:count]
+	}
+	return entries
+}
+
+func (b *RedisTxBus) Entries(ctx context.Context, count int64) (entries []*Entry, err error) {
+	for _, key := range laneKeys(b.Key, LANES) {
+		values, err := b.db.LRange(ctx, key, 0, rangeStop(count)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list queue %v", err)
+		}
+		for _, v := range values {
+			entry, err := newEntry(key, v, v, 0)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return limitEntries(entries, count), nil
+}
+
+func (b *RedisTxBus) Remove(ctx context.Context, entries ...*Entry) (count uint64, err error) {
+	for _, e := range entries {
+		n, err := b.db.LRem(ctx, e.Key, 1, e.Id).Result()
+		if err != nil {
+			return count, fmt.Errorf("Failed to remove queued tx %v", err)
+		}
+		count += uint64(n)
+	}
+	return
+}
+
+func (b *MemoryTxBus) Entries(ctx context.Context, count int64) (entries []*Entry, err error) {
+	for _, key := range laneKeys(b.Key, LANES) {
+		for _, v := range b.db.LRange(key, count) {
+			entry, err := newEntry(key, v, v, 0)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return limitEntries(entries, count), nil
+}
+
+func (b *MemoryTxBus) Remove(ctx context.Context, entries ...*Entry) (count uint64, err error) {
+	for _, e := range entries {
+		if b.db.LRem(e.Key, e.Id) {
+			count++
+		}
+	}
+	return
+}
+
+func (s *stream) entries(ctx context.Context, count int64) (entries []*Entry, err error) {
+	var msgs []redis.XMessage
+	if count > 0 {
+		msgs, err = s.db.XRangeN(ctx, s.key, "-", "+", count).Result()
+	} else {
+		msgs, err = s.db.XRange(ctx, s.key, "-", "+").Result()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list stream %v", err)
+	}
+	for i := range msgs {
+		score, _ := strconv.ParseInt(messageValue(&msgs[i], "score"), 10, 64)
+		entry, err := newEntry(s.key, msgs[i].ID, messageValue(&msgs[i], "tx"), score)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+func (s *stream) remove(ctx context.Context, entries ...*Entry) (count uint64, err error) {
+	for _, e := range entries {
+		n, err := s.db.XDel(ctx, e.Key, e.Id).Result()
+		if err != nil {
+			return count, fmt.Errorf("Failed to remove queued tx %v", err)
+		}
+		count += uint64(n)
+	}
+	return
+}
+
+func (b *RedisStreamTxBus) Entries(ctx context.Context, count int64) (entries []*Entry, err error) {
+	for _, p := range LANES {
+		list, err := b.lane(p).entries(ctx, count)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, list...)
+	}
+	return limitEntries(entries, count), nil
+}
+
+func (b *RedisStreamTxBus) Remove(ctx context.Context, entries ...*Entry) (uint64, error) {
+	return b.remove(ctx, entries...)
+}
+
+func (b *RedisStreamSortedTxBus) Entries(ctx context.Context, count int64) ([]*Entry, error) {
+	return b.entries(ctx, count)
+}
+
+func (b *RedisStreamSortedTxBus) Remove(ctx context.Context, entries ...*Entry) (uint64, error) {
+	return b.remove(ctx, entries...)
+}
+
+func zsetEntries(key string, members []string, scores []float64) (entries []*Entry, err error) {
+	for i, member := range members {
+		entry, err := newEntry(key, member, member, int64(scores[i]))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+func redisZSetEntries(ctx context.Context, db redis.UniversalClient, key string, count int64) ([]*Entry, error) {
+	res, err := db.ZRangeWithScores(ctx, key, 0, rangeStop(count)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list sorted queue %v", err)
+	}
+	members := make([]string, len(res))
+	scores := make([]float64, len(res))
+	for i, z := range res {
+		members[i], _ = z.Member.(string)
+		scores[i] = z.Score
+	}
+	return zsetEntries(key, members, scores)
+}
+
+func redisZSetRemove(ctx context.Context, db redis.UniversalClient, entries ...*Entry) (count uint64, err error) {
+	for _, e := range entries {
+		n, err := db.ZRem(ctx, e.Key, e.Id).Result()
+		if err != nil {
+			return count, fmt.Errorf("Failed to remove queued tx %v", err)
+		}
+		count += uint64(n)
+	}
+	return
+}
+
+func (b *RedisSortedTxBus) Entries(ctx context.Context, count int64) ([]*Entry, error) {
+	return redisZSetEntries(ctx, b.db, b.Key.Key(), count)
+}
+
+func (b *RedisSortedTxBus) Remove(ctx context.Context, entries ...*Entry) (uint64, error) {
+	return redisZSetRemove(ctx, b.db, entries...)
+}
+
+func (b *MemorySortedTxBus) Entries(ctx context.Context, count int64) ([]*Entry, error) {
+	members, scores := b.db.ZRange(b.Key.Key(), count)
+	return zsetEntries(b.Key.Key(), members, scores)
+}
+
+func (b *MemorySortedTxBus) Remove(ctx context.Context, entries ...*Entry) (count uint64, err error) {
+	for _, e := range entries {
+		if b.db.ZRem(e.Key, e.Id) {
+			count++
+		}
+	}
+	return
+}
+
+// Entries of all the delayed queues including the legacy one
+func (b *RedisDelayedTxBus) Entries(ctx context.Context, count int64) (entries []*Entry, err error) {
+	chains, err := b.Chains(ctx)
+	if err != nil {
+		return
+	}
+	for _, chain := range append(chains, 0) {
+		list, err := redisZSetEntries(ctx, b.db, NewDelayedKey(chain).Key(), count)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, list...)
+	}
+	return limitEntries(entries, count), nil
+}
+
+func (b *RedisDelayedTxBus) Remove(ctx context.Context, entries ...*Entry) (uint64, error) {
+	return redisZSetRemove(ctx, b.db, entries...)
+}
+
+func (b *MemoryDelayedTxBus) Entries(ctx context.Context, count int64) (entries []*Entry, err error) {
+	chains, _ := b.Chains(ctx)
+	for _, chain := range append(chains, 0) {
+		key := NewDelayedKey(chain).Key()
+		members, scores := b.db.ZRange(key, count)
+		list, err := zsetEntries(key, members, scores)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, list...)
+	}
+	return limitEntries(entries, count), nil
+}
+
+func (b *MemoryDelayedTxBus) Remove(ctx context.Context, entries ...*Entry) (count uint64, err error) {
+	for _, e := range entries {
+		if b.db.ZRem(e.Key, e.Id) {
+			count++
+		}
+	}
+	return
+}
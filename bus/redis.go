@@ -19,33 +19,49 @@ package bus
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/go-redis/redis/v8"
-	"github.com/polynetwork/bridge-common/util"
 	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/bridge-common/util"
+	"github.com/polynetwork/poly-relayer/config"
 )
 
+// Queue part of a key, in hash tag on redis cluster, so keys used together map to the same cluster slot
+func hashTag(s string) string {
+	if config.HashTag() {
+		return "{" + s + "}"
+	}
+	return s
+}
+
 type RedisConn struct {
-	Conn    *redis.Client
-	options *redis.Options
+	Conn    redis.UniversalClient
+	options *redis.UniversalOptions
+	cluster bool
 }
 
 func (c *RedisConn) Key() string {
-	return fmt.Sprintf("%s:%d", c.options.Addr, c.options.DB)
+	return fmt.Sprintf("%s:%s:%d:%v", c.options.MasterName, strings.Join(c.options.Addrs, ","), c.options.DB, c.cluster)
 }
 
 func (c *RedisConn) Create() (interface{}, error) {
-	c.Conn = redis.NewClient(c.options)
+	if c.cluster {
+		c.Conn = redis.NewClusterClient(c.options.Cluster())
+	} else {
+		c.Conn = redis.NewUniversalClient(c.options)
+	}
 	return c, nil
 }
 
-func New(options *redis.Options) *redis.Client {
-	if options.Addr == "" {
+func New(conf *config.BusConfig) redis.UniversalClient {
+	if len(conf.Redis.Addrs) == 0 {
 		log.Warn("Skipping redis connection for missing url")
 		return nil
 	}
 	c, _ := util.Single(&RedisConn{
-		options: options,
+		options: conf.Redis,
+		cluster: conf.Cluster(),
 	})
 	return c.(*RedisConn).Conn
 }
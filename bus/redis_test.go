@@ -0,0 +1,34 @@
+package bus
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestHashTagKeys(t *testing.T) {
+	config.SetHashTag(true)
+	defer config.SetHashTag(false)
+
+	slot := func(key string) string {
+		return key[strings.Index(key, "{"):strings.Index(key, "}")]
+	}
+	queue := &TxQueueKey{ChainId: 2, TxType: msg.POLY}
+	keys := laneKeys(queue, LANES)
+	f := inflight{keys[0], 0}
+	for _, key := range append(keys, f.processing(), f.registry()) {
+		if slot(key) != slot(keys[1]) {
+			t.Fatalf("Expect keys in the same slot, got %s %s", key, keys[1])
+		}
+	}
+	if slot(NewDelayedKey(2).Key()) != slot(DelayedChainsKey.Key()) {
+		t.Fatal("Expect delayed keys in the same slot")
+	}
+
+	config.SetHashTag(false)
+	if key := queue.Key(); strings.Contains(key, "{") || !strings.HasSuffix(key, ":relayer:bus:2:2") {
+		t.Fatalf("Unexpected key without hash tag %s", key)
+	}
+}
@@ -0,0 +1,442 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+const (
+	POLL_INTERVAL = 200 * time.Millisecond // Reliable pop polling interval
+	REAP_INTERVAL = 10 * time.Second       // Expired lease check interval
+)
+
+// Consumer name of the process, in flight txs are tracked per consumer
+var consumer = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}()
+
+// Delivery sequence of the process, makes in flight members unique for identical payloads
+var deliveries uint64
+
+// Unique id prefixed to an in flight member
+func delivery() string {
+	return fmt.Sprintf("#%s-%d", consumer, atomic.AddUint64(&deliveries, 1))
+}
+
+// Reaper puts back in flight txs whose lease expired
+type Reaper interface {
+	Reap(context.Context) (uint64, error)
+}
+
+// Extender renews the leases of in flight txs still being processed by the consumer
+type Extender interface {
+	Extend(context.Context) error
+}
+
+// Run reaper periodically till ctx is done
+func StartReaper(ctx context.Context, wg *sync.WaitGroup, r Reaper, name string) {
+	wg.Add(1)
+	defer wg.Done()
+	defer Recover(ctx)
+	ticker := time.NewTicker(REAP_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Bus reaper is exiting now", "bus", name)
+			return
+		case <-ticker.C:
+			if e, ok := r.(Extender); ok {
+				err := e.Extend(ctx)
+				if err != nil {
+					log.Error("Failed to extend in flight tx leases", "bus", name, "err", err)
+				}
+			}
+			count, err := r.Reap(ctx)
+			if err != nil {
+				log.Error("Failed to reap expired in flight txs", "bus", name, "err", err)
+			} else if count > 0 {
+				log.Warn("Redelivered expired in flight txs", "bus", name, "count", count)
+			}
+		}
+	}
+}
+
+var (
+	// Pop the head of the first non-empty lane into its processing set as "id|member" with lease deadline as score,
+	// keys are triples of queue, processing set and registry per lane
+	popScript = redis.NewScript(`
+for i = 1, #KEYS, 3 do
+	local v = redis.call('LPOP', KEYS[i])
+	if v then
+		redis.call('ZADD', KEYS[i + 1], ARGV[1], ARGV[2] .. '|' .. v)
+		redis.call('SADD', KEYS[i + 2], KEYS[i + 1])
+		return {(i - 1) / 3, v}
+	end
+end
+return false`)
+
+	// Pop min score member into processing set as "id|score|member"
+	sortedPopScript = redis.NewScript(`
+local r = redis.call('ZPOPMIN', KEYS[1])
+if #r == 0 then
+	return false
+end
+redis.call('ZADD', KEYS[2], ARGV[1], ARGV[2] .. '|' .. r[2] .. '|' .. r[1])
+redis.call('SADD', KEYS[3], KEYS[2])
+return {r[1], r[2]}`)
+
+	// Settle the lease and requeue the tx, skipped if the lease was reaped already
+	nackScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+if ARGV[3] == '' then
+	redis.call('RPUSH', KEYS[2], ARGV[2])
+else
+	redis.call('ZADD', KEYS[2], ARGV[3], ARGV[2])
+end
+return 1`)
+
+	// Reset the lease deadline, skipped if the lease was settled or reaped already
+	extendScript = redis.NewScript(`
+if redis.call('ZSCORE', KEYS[1], ARGV[2]) then
+	redis.call('ZADD', KEYS[1], ARGV[1], ARGV[2])
+	return 1
+end
+return 0`)
+
+	// Move expired leases back to the queue with delivery id stripped, unregister empty processing set
+	reapScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, v in ipairs(items) do
+	redis.call('ZREM', KEYS[1], v)
+	local m = v
+	if string.sub(m, 1, 1) == '#' then
+		m = string.sub(m, string.find(m, '|', 1, true) + 1)
+	end
+	if ARGV[2] == '1' then
+		local i = string.find(m, '|', 1, true)
+		redis.call('ZADD', KEYS[2], string.sub(m, 1, i - 1), string.sub(m, i + 1))
+	else
+		redis.call('LPUSH', KEYS[2], m)
+	end
+end
+if redis.call('ZCARD', KEYS[1]) == 0 then
+	redis.call('SREM', KEYS[3], KEYS[1])
+end
+return #items`)
+)
+
+// Leases held by the process, extended till settled
+type leases struct {
+	sync.Mutex
+	keys map[string]map[string]time.Duration // processing set -> member -> lease
+}
+
+var held = &leases{keys: map[string]map[string]time.Duration{}}
+
+func (l *leases) hold(key, member string, lease time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	members, ok := l.keys[key]
+	if !ok {
+		members = map[string]time.Duration{}
+		l.keys[key] = members
+	}
+	members[member] = lease
+}
+
+func (l *leases) release(key, member string) {
+	l.Lock()
+	defer l.Unlock()
+	delete(l.keys[key], member)
+}
+
+func (l *leases) list(key string) map[string]time.Duration {
+	l.Lock()
+	defer l.Unlock()
+	members := map[string]time.Duration{}
+	for m, lease := range l.keys[key] {
+		members[m] = lease
+	}
+	return members
+}
+
+// Keys of the in flight txs of a queue
+type inflight struct {
+	queue string
+	lease time.Duration
+}
+
+func (f inflight) processing() string {
+	return fmt.Sprintf("%s:processing:%s", f.queue, consumer)
+}
+
+func (f inflight) registry() string {
+	return f.queue + ":consumers"
+}
+
+func (f inflight) deadline() int64 {
+	return time.Now().Add(f.lease).Unix()
+}
+
+func (f inflight) hold(tx *msg.Tx) {
+	held.hold(f.processing(), tx.Lease, f.lease)
+}
+
+// Renew the deadlines of leases held, drop the ones settled or reaped
+func (f inflight) extend(ctx context.Context, db redis.UniversalClient) error {
+	key := f.processing()
+	for member, lease := range held.list(key) {
+		ok, err := extendScript.Run(ctx, db, []string{key}, time.Now().Add(lease).Unix(), member).Int()
+		if err != nil {
+			return fmt.Errorf("Failed to extend lease %v", err)
+		}
+		if ok == 0 {
+			held.release(key, member)
+		}
+	}
+	return nil
+}
+
+func (f inflight) ack(ctx context.Context, db redis.UniversalClient, tx *msg.Tx) error {
+	if tx.Lease == "" {
+		return nil
+	}
+	held.release(f.processing(), tx.Lease)
+	_, err := db.ZRem(ctx, f.processing(), tx.Lease).Result()
+	if err != nil {
+		return fmt.Errorf("Failed to ack message %v", err)
+	}
+	return nil
+}
+
+func (f inflight) nack(ctx context.Context, db redis.UniversalClient, tx *msg.Tx, score string) error {
+	held.release(f.processing(), tx.Lease)
+	ok, err := nackScript.Run(ctx, db, []string{f.processing(), f.queue}, tx.Lease, tx.Encode(), score).Int()
+	if err != nil {
+		return fmt.Errorf("Failed to nack message %v", err)
+	}
+	if ok == 0 {
+		log.Warn("Nack skipped for lease not found", "key", f.queue, "body", tx.Encode())
+	}
+	return nil
+}
+
+func (f inflight) reap(ctx context.Context, db redis.UniversalClient, sorted bool) (count uint64, err error) {
+	keys, err := db.SMembers(ctx, f.registry()).Result()
+	if err != nil {
+		return 0, fmt.Errorf("Failed to list bus consumers %v", err)
+	}
+	flag := "0"
+	if sorted {
+		flag = "1"
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	for _, key := range keys {
+		for {
+			n, err := reapScript.Run(ctx, db, []string{key, f.queue, f.registry()}, now, flag).Int()
+			if err != nil {
+				return count, fmt.Errorf("Failed to reap in flight messages %v", err)
+			}
+			count += uint64(n)
+			if n < 100 {
+				break
+			}
+		}
+	}
+	return
+}
+
+// Poll f till it returns true, with timeout, zero timeout polls till ctx is done
+func poll(ctx context.Context, timeout time.Duration, f func() (bool, error)) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		ok, err := f()
+		if err != nil || ok {
+			return err
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(POLL_INTERVAL):
+		}
+	}
+}
+
+// Redis tx bus with at-least-once delivery, popped txs stay in flight till acked or lease expired
+type RedisReliableTxBus struct {
+	*RedisTxBus
+	lease time.Duration
+}
+
+func NewRedisReliableTxBus(db redis.UniversalClient, chainId uint64, txType msg.TxType, lease time.Duration) *RedisReliableTxBus {
+	return &RedisReliableTxBus{NewRedisTxBus(db, chainId, txType), lease}
+}
+
+// In flight keys of the lane
+func (b *RedisReliableTxBus) lane(priority int) inflight {
+	return inflight{LaneKey(b.Key, priority).Key(), b.lease}
+}
+
+func (b *RedisReliableTxBus) Pop(ctx context.Context) (*msg.Tx, error) {
+	return b.PopTimed(ctx, 0)
+}
+
+func (b *RedisReliableTxBus) PopTimed(ctx context.Context, duration time.Duration) (tx *msg.Tx, err error) {
+	err = poll(ctx, duration, func() (bool, error) {
+		order := b.lanes.order()
+		keys := []string{}
+		for _, p := range order {
+			f := b.lane(p)
+			keys = append(keys, f.queue, f.processing(), f.registry())
+		}
+		id := delivery()
+		v, err := popScript.Run(ctx, b.db, keys, b.lane(msg.PRIORITY_NORMAL).deadline(), id).Result()
+		if err == redis.Nil {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		res, _ := v.([]interface{})
+		if len(res) < 2 {
+			return false, fmt.Errorf("Unexpected pop result %v", res)
+		}
+		index, _ := res[0].(int64)
+		member, _ := res[1].(string)
+		tx = new(msg.Tx)
+		err = tx.Decode(member)
+		tx.Lease = id + "|" + member
+		tx.Priority = order[index]
+		b.lane(tx.Priority).hold(tx)
+		return true, err
+	})
+	if err != nil && tx == nil {
+		return nil, fmt.Errorf("Failed to pop message %v", err)
+	}
+	return
+}
+
+func (b *RedisReliableTxBus) Ack(ctx context.Context, tx *msg.Tx) error {
+	return b.lane(tx.Priority).ack(ctx, b.db, tx)
+}
+
+func (b *RedisReliableTxBus) Nack(ctx context.Context, tx *msg.Tx) error {
+	if tx.Lease == "" {
+		return b.Push(ctx, tx)
+	}
+	return b.lane(tx.Priority).nack(ctx, b.db, tx, "")
+}
+
+func (b *RedisReliableTxBus) Extend(ctx context.Context) error {
+	for _, p := range LANES {
+		err := b.lane(p).extend(ctx, b.db)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *RedisReliableTxBus) Reap(ctx context.Context) (count uint64, err error) {
+	for _, p := range LANES {
+		n, err := b.lane(p).reap(ctx, b.db, false)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return
+}
+
+// Redis sorted tx bus with at-least-once delivery, in flight members keep the score to restore
+type RedisReliableSortedTxBus struct {
+	*RedisSortedTxBus
+	inflight
+}
+
+func NewRedisReliableSortedTxBus(db redis.UniversalClient, chainId uint64, txType msg.TxType, lease time.Duration) *RedisReliableSortedTxBus {
+	b := NewRedisSortedTxBus(db, chainId, txType)
+	return &RedisReliableSortedTxBus{b, inflight{b.Key.Key(), lease}}
+}
+
+func (b *RedisReliableSortedTxBus) Pop(ctx context.Context) (tx *msg.Tx, score uint64, err error) {
+	err = poll(ctx, 0, func() (bool, error) {
+		id := delivery()
+		v, err := sortedPopScript.Run(ctx, b.db, []string{b.queue, b.processing(), b.registry()}, b.deadline(), id).Result()
+		if err == redis.Nil {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		res, _ := v.([]interface{})
+		if len(res) < 2 {
+			return false, fmt.Errorf("Unexpected sorted pop result %v", res)
+		}
+		member, _ := res[0].(string)
+		value, err := strconv.ParseFloat(fmt.Sprint(res[1]), 64)
+		if err != nil {
+			return false, err
+		}
+		score = uint64(value)
+		tx = new(msg.Tx)
+		err = tx.Decode(member)
+		tx.Lease = fmt.Sprintf("%s|%v|%s", id, res[1], member)
+		b.hold(tx)
+		return true, err
+	})
+	return
+}
+
+func (b *RedisReliableSortedTxBus) Ack(ctx context.Context, tx *msg.Tx) error {
+	return b.ack(ctx, b.db, tx)
+}
+
+func (b *RedisReliableSortedTxBus) Nack(ctx context.Context, tx *msg.Tx, score uint64) error {
+	if tx.Lease == "" {
+		return b.Push(ctx, tx, score)
+	}
+	return b.nack(ctx, b.db, tx, strconv.FormatUint(score, 10))
+}
+
+func (b *RedisReliableSortedTxBus) Extend(ctx context.Context) error {
+	return b.extend(ctx, b.db)
+}
+
+func (b *RedisReliableSortedTxBus) Reap(ctx context.Context) (uint64, error) {
+	return b.reap(ctx, b.db, true)
+}
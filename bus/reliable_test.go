@@ -0,0 +1,119 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func testRedis(t *testing.T) redis.UniversalClient {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	return redis.NewClient(&redis.Options{Addr: m.Addr()})
+}
+
+func TestRedisReliableTxBus(t *testing.T) {
+	ctx := context.Background()
+	db := testRedis(t)
+	b := NewRedisReliableTxBus(db, 2, msg.POLY, time.Minute)
+	tx := &msg.Tx{TxType: msg.POLY, PolyHash: "0x01", DstChainId: 2, Queued: 1}
+	b.Push(ctx, tx)
+	b.Push(ctx, tx)
+
+	first, err := b.PopTimed(ctx, time.Second)
+	if err != nil || first == nil || first.PolyHash != "0x01" {
+		t.Fatalf("Expect tx popped, got %v err %v", first, err)
+	}
+	second, _ := b.PopTimed(ctx, time.Second)
+	if second == nil || second.Lease == first.Lease {
+		t.Fatalf("Expect identical payloads delivered with distinct leases, got %v", second)
+	}
+	if n, _ := db.ZCard(ctx, b.lane(first.Priority).processing()).Result(); n != 2 {
+		t.Fatalf("Expect two txs in flight, got %v", n)
+	}
+
+	b.Ack(ctx, first)
+	b.Nack(ctx, second)
+	if n, _ := db.ZCard(ctx, b.lane(first.Priority).processing()).Result(); n != 0 {
+		t.Fatalf("Expect no tx in flight, got %v", n)
+	}
+	if n, _ := b.Len(ctx); n != 1 {
+		t.Fatalf("Expect nacked tx requeued, got len %v", n)
+	}
+	// Nack of a settled lease is skipped
+	b.Nack(ctx, second)
+	if n, _ := b.Len(ctx); n != 1 {
+		t.Fatalf("Expect settled lease nack skipped, got len %v", n)
+	}
+}
+
+func TestRedisReliableTxBusReap(t *testing.T) {
+	ctx := context.Background()
+	db := testRedis(t)
+	b := NewRedisReliableTxBus(db, 2, msg.POLY, 0)
+	b.Push(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "0x01", DstChainId: 2, Queued: 1})
+	tx, _ := b.PopTimed(ctx, time.Second)
+	if tx == nil {
+		t.Fatal("Expect tx popped")
+	}
+	if n, err := b.Reap(ctx); n != 1 || err != nil {
+		t.Fatalf("Expect expired lease reaped, got %v err %v", n, err)
+	}
+	again, _ := b.PopTimed(ctx, time.Second)
+	if again == nil || again.PolyHash != "0x01" {
+		t.Fatalf("Expect reaped tx redelivered, got %v", again)
+	}
+
+	b.Ack(ctx, again)
+
+	// Extended lease is not reaped
+	b = NewRedisReliableTxBus(db, 2, msg.POLY, time.Minute)
+	b.Push(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "0x01", DstChainId: 2, Queued: 1})
+	tx, _ = b.PopTimed(ctx, time.Second)
+	key := b.lane(tx.Priority).processing()
+	db.ZAdd(ctx, key, &redis.Z{Score: 0, Member: tx.Lease})
+	b.Extend(ctx)
+	if n, _ := b.Reap(ctx); n != 0 {
+		t.Fatalf("Expect extended lease kept, got reaped %v", n)
+	}
+	b.Ack(ctx, tx)
+	if err := b.Extend(ctx); err != nil || len(held.list(key)) != 0 {
+		t.Fatalf("Expect settled lease released, err %v", err)
+	}
+}
+
+func TestRedisReliableSortedTxBus(t *testing.T) {
+	ctx := context.Background()
+	db := testRedis(t)
+	b := NewRedisReliableSortedTxBus(db, 2, msg.SRC, 0)
+	b.Push(ctx, &msg.Tx{TxType: msg.SRC, SrcHash: "0x02"}, 20)
+	b.Push(ctx, &msg.Tx{TxType: msg.SRC, SrcHash: "0x01"}, 10)
+
+	tx, score, err := b.Pop(ctx)
+	if err != nil || tx.SrcHash != "0x01" || score != 10 {
+		t.Fatalf("Expect min score tx popped, got %v score %v err %v", tx, score, err)
+	}
+	if n, err := b.Reap(ctx); n != 1 || err != nil {
+		t.Fatalf("Expect expired lease reaped, got %v err %v", n, err)
+	}
+	tx, score, _ = b.Pop(ctx)
+	if tx.SrcHash != "0x01" || score != 10 {
+		t.Fatalf("Expect reaped tx restored with score, got %v score %v", tx, score)
+	}
+	b.Nack(ctx, tx, 30)
+	tx, score, _ = b.Pop(ctx)
+	if tx.SrcHash != "0x02" || score != 20 {
+		t.Fatalf("Expect nacked tx requeued with new score, got %v score %v", tx, score)
+	}
+	b.Ack(ctx, tx)
+	if n, _ := db.ZCard(ctx, b.processing()).Result(); n != 0 {
+		t.Fatalf("Expect no tx in flight, got %v", n)
+	}
+}
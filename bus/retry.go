@@ -0,0 +1,103 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Retry rules matching the legacy fixed delays
+var RETRY_DEFAULTS = map[string]*config.RetryConfig{
+	msg.CLASS_DEFAULT:                  {Delay: 1},
+	msg.CLASS_EXEC_FAILURE:             {Delay: 180},
+	msg.CLASS_EXEC_ALWAYS_FAIL:         {Delay: 180},
+	msg.CLASS_FEE_CHECK_FAILURE:        {Delay: 10},
+	msg.CLASS_FEE_MISSING:              {Delay: 5},
+	msg.CLASS_FEE_NOT_PAID:             {Delay: 600},
+	msg.CLASS_PAID_FEE_TOO_LOW:         {Delay: 600},
+	msg.CLASS_LOW_BALANCE:              {Delay: 1},
+	msg.CLASS_SEQUENCE_NUMBER_INVALID:  {Delay: 60},
+	msg.CLASS_COIN_STORE_NOT_PUBLISHED: {Delay: 600},
+	msg.CLASS_TREASURY_NOT_EXIST:       {Delay: 600},
+	msg.CLASS_EXECUTED:                 {Delay: 600},
+	msg.CLASS_INVALID_HEADER:           {Delay: 60},
+}
+
+// Decides when a failed tx becomes visible again in the delayed tx queue
+type RetryPolicy interface {
+	Next(*msg.Tx, error) int64
+}
+
+// Exponential backoff with jitter and cap per error class
+type BackoffPolicy struct {
+	rules map[string]*config.RetryConfig
+}
+
+// Create policy with rule sets layered over RETRY_DEFAULTS, later sets take precedence
+func NewRetryPolicy(sets ...map[string]*config.RetryConfig) *BackoffPolicy {
+	rules := map[string]*config.RetryConfig{}
+	for _, set := range append([]map[string]*config.RetryConfig{RETRY_DEFAULTS}, sets...) {
+		for class, rule := range set {
+			if rule == nil {
+				continue
+			}
+			r := *rule
+			rules[class] = r.Fill(rules[class])
+		}
+	}
+	return &BackoffPolicy{rules}
+}
+
+func (p *BackoffPolicy) rule(class string) *config.RetryConfig {
+	if rule, ok := p.rules[class]; ok {
+		return rule
+	}
+	return p.rules[msg.CLASS_DEFAULT]
+}
+
+// Delay before the attempt of the error class
+func (p *BackoffPolicy) Delay(class string, attempts int) time.Duration {
+	rule := p.rule(class)
+	if rule == nil {
+		return 0
+	}
+	delay := float64(rule.Delay)
+	if rule.Factor > 0 && attempts > 1 {
+		delay *= math.Pow(rule.Factor, float64(attempts-1))
+	}
+	if rule.Max > 0 && delay > float64(rule.Max) {
+		delay = float64(rule.Max)
+	}
+	if delay > math.MaxInt32 {
+		delay = math.MaxInt32
+	}
+	if rule.Jitter > 0 {
+		delay *= 1 + rule.Jitter*rand.Float64()
+	}
+	return time.Duration(delay * float64(time.Second))
+}
+
+// Next visible timestamp of the tx, nil error for the check after a successful submit
+func (p *BackoffPolicy) Next(tx *msg.Tx, err error) int64 {
+	return time.Now().Add(p.Delay(msg.ErrorClass(err), tx.Attempts)).Unix()
+}
@@ -0,0 +1,37 @@
+package bus
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestBackoffPolicy(t *testing.T) {
+	p := NewRetryPolicy(map[string]*config.RetryConfig{
+		msg.CLASS_EXEC_FAILURE: {Factor: 2, Max: 500},
+		msg.CLASS_FEE_MISSING:  {Delay: 3, Jitter: 0.5},
+	})
+	for attempts, expect := range []int64{180, 180, 360, 500, 500} {
+		if d := p.Delay(msg.CLASS_EXEC_FAILURE, attempts); d != time.Duration(expect)*time.Second {
+			t.Fatalf("Unexpected delay of attempt %v: %v", attempts, d)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if d := p.Delay(msg.CLASS_FEE_MISSING, 1); d < 3*time.Second || d >= 4500*time.Millisecond {
+			t.Fatalf("Unexpected jitter delay %v", d)
+		}
+	}
+	if d := p.Delay("unknown", 5); d != time.Second {
+		t.Fatalf("Expect default delay, got %v", d)
+	}
+
+	err := fmt.Errorf("%w wrapped", msg.ERR_PAID_FEE_TOO_LOW)
+	tx := &msg.Tx{}
+	tx.Fail(err)
+	if tsp := p.Next(tx, err); tsp < time.Now().Unix()+599 {
+		t.Fatalf("Unexpected next timestamp %v", tsp)
+	}
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/bridge-common/base"
+)
+
+// Key of the shard registry, sharing one cluster slot to replace assignments atomically
+type ShardKey string
+
+func (k ShardKey) Key() string {
+	return fmt.Sprintf("%s:relayer:%s:%s", base.ENV, hashTag("shard"), string(k))
+}
+
+const (
+	SHARD_INSTANCES   = ShardKey("instances")
+	SHARD_ASSIGNMENTS = ShardKey("assignments")
+)
+
+// Role states
+const (
+	ROLE_STARTING = "starting"
+	ROLE_RUNNING  = "running"
+	ROLE_BACKOFF  = "backoff"
+	ROLE_PAUSED   = "paused"
+	ROLE_STOPPED  = "stopped"
+)
+
+// State of a role on an instance
+type RoleStatus struct {
+	Name     string
+	Chain    uint64
+	State    string
+	Restarts int
+	Error    string `json:",omitempty"` // Last failure
+	Since    int64  // Time entered the state
+}
+
+// Relayer instance with the tasks it is able to run and running now
+type Instance struct {
+	Id        string
+	Tasks     []string          `json:",omitempty"`
+	Running   []string          `json:",omitempty"`
+	Roles     []RoleStatus      `json:",omitempty"`
+	Drops     map[string]uint64 `json:",omitempty"` // Filtered tx counts per reason
+	Outcomes  map[string]uint64 `json:",omitempty"` // Dst tx outcome counts per chain
+	GasUsed   map[string]uint64 `json:",omitempty"` // Gas used by dst txs per chain
+	Started   int64
+	Heartbeat int64
+}
+
+// Instance heartbeat within the ttl
+func (i *Instance) Alive(ttl time.Duration) bool {
+	return time.Since(time.Unix(i.Heartbeat, 0)) < ttl
+}
+
+// Registry of relayer instances and task assignments keyed by task
+type Registry interface {
+	Heartbeat(context.Context, *Instance) error
+	Instances(context.Context) ([]*Instance, error)
+	Leave(ctx context.Context, id string) error
+	Assign(context.Context, map[string]string) error // Replace all the assignments
+	Assignments(context.Context) (map[string]string, error)
+}
+
+func decodeInstances(values map[string]string) (list []*Instance, err error) {
+	for _, v := range values {
+		i := new(Instance)
+		err = json.Unmarshal([]byte(v), i)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode instance %v", err)
+		}
+		list = append(list, i)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Id < list[b].Id })
+	return
+}
+
+type RedisRegistry struct {
+	db redis.UniversalClient
+}
+
+func NewRedisRegistry(db redis.UniversalClient) *RedisRegistry {
+	return &RedisRegistry{db}
+}
+
+func (r *RedisRegistry) Heartbeat(ctx context.Context, i *Instance) error {
+	i.Heartbeat = time.Now().Unix()
+	data, _ := json.Marshal(i)
+	err := r.db.HSet(ctx, SHARD_INSTANCES.Key(), i.Id, string(data)).Err()
+	if err != nil {
+		return fmt.Errorf("Failed to send instance heartbeat %v", err)
+	}
+	return nil
+}
+
+func (r *RedisRegistry) Instances(ctx context.Context) ([]*Instance, error) {
+	values, err := r.db.HGetAll(ctx, SHARD_INSTANCES.Key()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list instances %v", err)
+	}
+	return decodeInstances(values)
+}
+
+func (r *RedisRegistry) Leave(ctx context.Context, id string) error {
+	err := r.db.HDel(ctx, SHARD_INSTANCES.Key(), id).Err()
+	if err != nil {
+		return fmt.Errorf("Failed to remove instance %v", err)
+	}
+	return nil
+}
+
+func (r *RedisRegistry) Assign(ctx context.Context, assignments map[string]string) error {
+	_, err := r.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, SHARD_ASSIGNMENTS.Key())
+		if len(assignments) > 0 {
+			p.HSet(ctx, SHARD_ASSIGNMENTS.Key(), assignments)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to update assignments %v", err)
+	}
+	return nil
+}
+
+func (r *RedisRegistry) Assignments(ctx context.Context) (map[string]string, error) {
+	values, err := r.db.HGetAll(ctx, SHARD_ASSIGNMENTS.Key()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get assignments %v", err)
+	}
+	return values, nil
+}
+
+type MemoryRegistry struct {
+	db *MemoryDB
+}
+
+func NewMemoryRegistry(db *MemoryDB) *MemoryRegistry {
+	return &MemoryRegistry{db}
+}
+
+func (r *MemoryRegistry) Heartbeat(ctx context.Context, i *Instance) error {
+	i.Heartbeat = time.Now().Unix()
+	data, _ := json.Marshal(i)
+	r.db.HSet(SHARD_INSTANCES.Key(), i.Id, string(data))
+	return nil
+}
+
+func (r *MemoryRegistry) Instances(ctx context.Context) ([]*Instance, error) {
+	return decodeInstances(r.db.HGetAll(SHARD_INSTANCES.Key()))
+}
+
+func (r *MemoryRegistry) Leave(ctx context.Context, id string) error {
+	r.db.HDel(SHARD_INSTANCES.Key(), id)
+	return nil
+}
+
+func (r *MemoryRegistry) Assign(ctx context.Context, assignments map[string]string) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+	values := map[string]string{}
+	for task, id := range assignments {
+		values[task] = id
+	}
+	r.db.hashes[SHARD_ASSIGNMENTS.Key()] = values
+	return nil
+}
+
+func (r *MemoryRegistry) Assignments(ctx context.Context) (map[string]string, error) {
+	return r.db.HGetAll(SHARD_ASSIGNMENTS.Key()), nil
+}
+
+// Assign the tasks of live instances, current owners keep their tasks unless the load is uneven,
+// tasks move from the most loaded instances to the least loaded ones capable of them
+func Balance(instances []*Instance, current map[string]string) map[string]string {
+	capable := map[string]map[string]bool{}
+	load := map[string]int{}
+	tasks := []string{}
+	for _, i := range instances {
+		load[i.Id] = 0
+		for _, task := range i.Tasks {
+			if capable[task] == nil {
+				capable[task] = map[string]bool{}
+				tasks = append(tasks, task)
+			}
+			capable[task][i.Id] = true
+		}
+	}
+	sort.Strings(tasks)
+	ids := make([]string, 0, len(instances))
+	for _, i := range instances {
+		ids = append(ids, i.Id)
+	}
+	sort.Strings(ids)
+
+	assignments := map[string]string{}
+	for _, task := range tasks {
+		if id, ok := current[task]; ok && capable[task][id] {
+			assignments[task] = id
+			load[id]++
+		}
+	}
+	// Least loaded capable instance for the task, excluding the instance
+	pick := func(task, exclude string) (target string) {
+		for _, id := range ids {
+			if id != exclude && capable[task][id] && (target == "" || load[id] < load[target]) {
+				target = id
+			}
+		}
+		return
+	}
+	for _, task := range tasks {
+		if _, ok := assignments[task]; !ok {
+			id := pick(task, "")
+			assignments[task] = id
+			load[id]++
+		}
+	}
+	for moved := true; moved; {
+		moved = false
+		for _, task := range tasks {
+			owner := assignments[task]
+			target := pick(task, owner)
+			if target != "" && load[owner]-load[target] > 1 {
+				assignments[task] = target
+				load[owner]--
+				load[target]++
+				moved = true
+			}
+		}
+	}
+	return assignments
+}
@@ -0,0 +1,32 @@
+package bus
+
+import (
+	"testing"
+)
+
+func TestBalance(t *testing.T) {
+	a := &Instance{Id: "a", Tasks: []string{"2:PolyCommit", "6:PolyCommit", "7:PolyCommit", "0:PolyListen"}}
+	b := &Instance{Id: "b", Tasks: []string{"2:PolyCommit", "6:PolyCommit", "7:PolyCommit"}}
+	assignments := Balance([]*Instance{a}, nil)
+	for _, task := range a.Tasks {
+		if assignments[task] != "a" {
+			t.Fatalf("Expect all tasks assigned to a, got %v", assignments)
+		}
+	}
+
+	// New instance takes over part of the load, current owners keep the rest
+	assignments = Balance([]*Instance{a, b}, assignments)
+	load := map[string]int{}
+	for _, id := range assignments {
+		load[id]++
+	}
+	if load["a"] != 2 || load["b"] != 2 || assignments["0:PolyListen"] != "a" {
+		t.Fatalf("Expect even assignments, got %v", assignments)
+	}
+
+	// Tasks of the gone instance move to capable instances
+	assignments = Balance([]*Instance{b}, assignments)
+	if len(assignments) != 3 || assignments["2:PolyCommit"] != "b" {
+		t.Fatalf("Expect tasks moved to b, got %v", assignments)
+	}
+}
@@ -33,10 +33,10 @@ type SkipCheck interface {
 
 type RedisSkipCheck struct {
 	Key
-	db *redis.Client
+	db redis.UniversalClient
 }
 
-func NewRedisSkipCheck(db *redis.Client) *RedisSkipCheck {
+func NewRedisSkipCheck(db redis.UniversalClient) *RedisSkipCheck {
 	return &RedisSkipCheck{String("skip_map"), db}
 }
 
@@ -30,7 +30,7 @@ import (
 type SortedTxQueueKey TxQueueKey
 
 func (k *SortedTxQueueKey) Key() string {
-	return fmt.Sprintf("%s:relayer:sorted_bus:%v:%v", base.ENV, k.ChainId, k.TxType)
+	return fmt.Sprintf("%s:relayer:%s", base.ENV, hashTag(fmt.Sprintf("sorted_bus:%v:%v", k.ChainId, k.TxType)))
 }
 
 type SortedTxBus interface {
@@ -39,14 +39,18 @@ type SortedTxBus interface {
 	Pop(context.Context) (*msg.Tx, uint64, error)
 	Len(context.Context) (uint64, error)
 	Topic() string
+	Ack(context.Context, *msg.Tx) error
+	Nack(context.Context, *msg.Tx, uint64) error      // Return a popped tx to the queue with new score
+	Entries(context.Context, int64) ([]*Entry, error) // Queued txs not popped yet, at most count, zero for all
+	Remove(context.Context, ...*Entry) (uint64, error)
 }
 
 type RedisSortedTxBus struct {
 	Key
-	db *redis.Client
+	db redis.UniversalClient
 }
 
-func NewRedisSortedTxBus(db *redis.Client, chainId uint64, txType msg.TxType) *RedisSortedTxBus {
+func NewRedisSortedTxBus(db redis.UniversalClient, chainId uint64, txType msg.TxType) *RedisSortedTxBus {
 	bus := &RedisSortedTxBus{
 		db:  db,
 		Key: &SortedTxQueueKey{ChainId: chainId, TxType: txType},
@@ -112,3 +116,11 @@ func (b *RedisSortedTxBus) Pop(ctx context.Context) (tx *msg.Tx, score uint64, e
 	err = tx.Decode(res.Member.(string))
 	return
 }
+
+func (b *RedisSortedTxBus) Ack(ctx context.Context, tx *msg.Tx) error {
+	return nil
+}
+
+func (b *RedisSortedTxBus) Nack(ctx context.Context, tx *msg.Tx, score uint64) error {
+	return b.Push(ctx, tx, score)
+}
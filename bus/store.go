@@ -4,17 +4,13 @@ import (
 	"context"
 	"fmt"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/polynetwork/bridge-common/base"
-	"github.com/polynetwork/bridge-common/log"
 )
 
 const (
-	POLY_SYNC = String("poly_sync_running")
-
 	KEY_HEIGHT_HEADER       ChainHeightType = "header_sync"       // chain sync mark
 	KEY_HEIGHT_CHAIN_HEADER ChainHeightType = "chain_header_sync" // chain sync state
 	KEY_HEIGHT_HEADER_RESET ChainHeightType = "header_sync_reset" // chain sync reset
@@ -43,11 +39,11 @@ func (k ChainHeightKey) Key() string {
 
 type RedisChainStore struct {
 	Key
-	db    *redis.Client
+	db    redis.UniversalClient
 	timer *time.Ticker
 }
 
-func NewRedisChainStore(key Key, db *redis.Client, interval uint64) *RedisChainStore {
+func NewRedisChainStore(key Key, db redis.UniversalClient, interval uint64) *RedisChainStore {
 	if interval == 0 {
 		interval = 5
 	}
@@ -85,51 +81,3 @@ func (s *RedisChainStore) GetHeight(ctx context.Context) (height uint64, err err
 	height = uint64(h)
 	return
 }
-
-// A simple redis lock used to hint running status, avoid concurrencies
-type Lock struct {
-	key Key
-	db  *redis.Client
-	ctx context.Context
-	wg  *sync.WaitGroup
-}
-
-func NewStatusLock(db *redis.Client, key Key) *Lock {
-	return &Lock{key: key, db: db, ctx: context.Background()}
-}
-
-func (l *Lock) Start(ctx context.Context, wg *sync.WaitGroup) (ok bool, err error) {
-	l.wg = wg
-	l.ctx = ctx
-	ok, err = l.db.SetNX(context.Background(), l.key.Key(), time.Now(), 60*time.Second).Result()
-	if ok {
-		go l.start()
-	}
-	return
-}
-
-func (l *Lock) start() {
-	defer l.stop()
-	l.wg.Add(1)
-	timer := time.NewTicker(10 * time.Second)
-	defer timer.Stop()
-	for {
-		select {
-		case <-l.ctx.Done():
-			return
-		case <-timer.C:
-			_, err := l.db.Set(context.Background(), l.key.Key(), time.Now(), 60*time.Second).Result()
-			if err != nil {
-				log.Error("Failed to update redis status lock", "key", l.key.Key())
-			}
-		}
-	}
-}
-
-func (l *Lock) stop() {
-	_, err := l.db.Del(context.Background(), l.key.Key()).Result()
-	if err != nil {
-		log.Error("Failed to remove redis status lock", "key", l.key.Key())
-	}
-	l.wg.Done()
-}
@@ -0,0 +1,457 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+type StreamKey TxQueueKey
+
+func (k *StreamKey) Key() string {
+	return fmt.Sprintf("%s:relayer:%s%s", base.ENV, hashTag(fmt.Sprintf("stream:%v:%v", k.ChainId, k.TxType)), laneSuffix(k.Priority))
+}
+
+type SortedStreamKey TxQueueKey
+
+func (k *SortedStreamKey) Key() string {
+	return fmt.Sprintf("%s:relayer:%s", base.ENV, hashTag(fmt.Sprintf("sorted_stream:%v:%v", k.ChainId, k.TxType)))
+}
+
+// Pending entry of a stream consumer group
+type PendingTx struct {
+	Id         string
+	Consumer   string
+	Idle       time.Duration
+	Deliveries int64
+}
+
+// Inspect txs delivered but not acked yet
+type PendingInspector interface {
+	Pending(context.Context, int64) ([]*PendingTx, error)
+}
+
+// Stream with a consumer group per chain
+type stream struct {
+	key   string
+	group string
+	lease time.Duration
+	db    redis.UniversalClient
+	once  sync.Once
+}
+
+func newStream(db redis.UniversalClient, key Key, chainId uint64, lease time.Duration) *stream {
+	return &stream{
+		key:   key.Key(),
+		group: fmt.Sprintf("relayer:%v", chainId),
+		lease: lease,
+		db:    db,
+	}
+}
+
+// Create the consumer group from the stream start if not exist
+func (s *stream) init(ctx context.Context) {
+	s.once.Do(func() {
+		err := s.db.XGroupCreateMkStream(ctx, s.key, s.group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			log.Error("Failed to create stream consumer group", "stream", s.key, "group", s.group, "err", err)
+		}
+	})
+}
+
+func (s *stream) add(ctx context.Context, key string, values map[string]interface{}) error {
+	_, err := s.db.XAdd(ctx, &redis.XAddArgs{Stream: key, Values: values}).Result()
+	if err != nil {
+		return fmt.Errorf("Failed to push message %v", err)
+	}
+	return nil
+}
+
+// Read one new message for the consumer, zero duration blocks till ctx is done
+func (s *stream) read(ctx context.Context, duration time.Duration) (*redis.XMessage, error) {
+	_, m, err := readLanes(ctx, []*stream{s}, duration)
+	return m, err
+}
+
+// Read one new message from the first non-empty lane stream in order, lanes share the consumer group
+func readLanes(ctx context.Context, lanes []*stream, duration time.Duration) (*stream, *redis.XMessage, error) {
+	streams := []string{}
+	for _, s := range lanes {
+		s.init(ctx)
+		streams = append(streams, s.key)
+	}
+	for range lanes {
+		streams = append(streams, ">")
+	}
+	group := lanes[0].group
+	db := lanes[0].db
+	var deadline time.Time
+	if duration > 0 {
+		deadline = time.Now().Add(duration)
+	}
+	for {
+		// Try lanes in order without blocking, as a blocking read returns from any lane
+		if len(lanes) > 1 {
+			for _, s := range lanes {
+				res, err := db.XReadGroup(ctx, &redis.XReadGroupArgs{
+					Group: group, Consumer: consumer, Streams: []string{s.key, ">"}, Count: 1, Block: -1,
+				}).Result()
+				if err != nil && err != redis.Nil {
+					return nil, nil, fmt.Errorf("Failed to pop message %v", err)
+				}
+				if len(res) > 0 && len(res[0].Messages) > 0 {
+					return s, &res[0].Messages[0], nil
+				}
+			}
+		}
+		block := time.Second
+		if !deadline.IsZero() {
+			block = time.Until(deadline)
+			if block <= 0 {
+				return nil, nil, nil
+			}
+			if block < time.Millisecond {
+				block = time.Millisecond
+			}
+		}
+		res, err := db.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: consumer,
+			Streams:  streams,
+			Count:    1,
+			Block:    block,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return nil, nil, fmt.Errorf("Failed to pop message %v", err)
+		}
+		for _, r := range res {
+			for _, s := range lanes {
+				if s.key == r.Stream && len(r.Messages) > 0 {
+					return s, &r.Messages[0], nil
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, nil, fmt.Errorf("Failed to pop message %v", ctx.Err())
+		}
+	}
+}
+
+// Settle the message, deleted as the stream is used as a queue
+func (s *stream) ack(ctx context.Context, id string) error {
+	if id == "" {
+		return nil
+	}
+	_, err := s.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.XAck(ctx, s.key, s.group, id)
+		p.XDel(ctx, s.key, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to ack message %v", err)
+	}
+	return nil
+}
+
+// Settle the message and append the values as a new message
+func (s *stream) requeue(ctx context.Context, id string, values map[string]interface{}) error {
+	_, err := s.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.XAdd(ctx, &redis.XAddArgs{Stream: s.key, Values: values})
+		if id != "" {
+			p.XAck(ctx, s.key, s.group, id)
+			p.XDel(ctx, s.key, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to requeue message %v", err)
+	}
+	return nil
+}
+
+// Number of messages not delivered yet
+func (s *stream) len(ctx context.Context) (uint64, error) {
+	total, err := s.db.XLen(ctx, s.key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("Get stream length error %v", err)
+	}
+	pending, err := s.db.XPending(ctx, s.key, s.group).Result()
+	if err == nil && pending.Count <= total {
+		total -= pending.Count
+	}
+	return uint64(total), nil
+}
+
+func (s *stream) pending(ctx context.Context, count int64) (list []*PendingTx, err error) {
+	res, err := s.db.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: s.key, Group: s.group, Start: "-", End: "+", Count: count,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to inspect pending messages %v", err)
+	}
+	for _, p := range res {
+		list = append(list, &PendingTx{Id: p.ID, Consumer: p.Consumer, Idle: p.Idle, Deliveries: p.RetryCount})
+	}
+	return
+}
+
+// Claim messages idle longer than the lease and append them back to be delivered again
+func (s *stream) reap(ctx context.Context) (count uint64, err error) {
+	s.init(ctx)
+	start := "-"
+	for {
+		res, err := s.db.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: s.key, Group: s.group, Start: start, End: "+", Count: 100,
+		}).Result()
+		if err != nil {
+			return count, fmt.Errorf("Failed to inspect pending messages %v", err)
+		}
+		ids := []string{}
+		for _, p := range res {
+			if p.Idle >= s.lease {
+				ids = append(ids, p.ID)
+			}
+		}
+		if len(ids) > 0 {
+			// Claim with min idle time to skip messages acked or claimed in the meantime
+			msgs, err := s.db.XClaim(ctx, &redis.XClaimArgs{
+				Stream: s.key, Group: s.group, Consumer: consumer, MinIdle: s.lease, Messages: ids,
+			}).Result()
+			if err != nil {
+				return count, fmt.Errorf("Failed to claim idle messages %v", err)
+			}
+			for _, m := range msgs {
+				err = s.requeue(ctx, m.ID, m.Values)
+				if err != nil {
+					return count, err
+				}
+				count++
+			}
+		}
+		if len(res) < 100 {
+			return count, nil
+		}
+		start = "(" + res[len(res)-1].ID
+	}
+}
+
+func messageValue(m *redis.XMessage, field string) string {
+	v, _ := m.Values[field].(string)
+	return v
+}
+
+// Redis stream tx bus, popped txs stay pending in the consumer group till acked, requires redis 6.2+
+type RedisStreamTxBus struct {
+	*stream // normal priority lane
+	lanes   *lanes
+	streams map[int]*stream
+}
+
+func NewRedisStreamTxBus(db redis.UniversalClient, chainId uint64, txType msg.TxType, lease time.Duration) *RedisStreamTxBus {
+	streams := map[int]*stream{}
+	for _, p := range LANES {
+		streams[p] = newStream(db, &StreamKey{ChainId: chainId, TxType: txType, Priority: p}, chainId, lease)
+	}
+	return &RedisStreamTxBus{streams[msg.PRIORITY_NORMAL], newLanes(0), streams}
+}
+
+func (b *RedisStreamTxBus) lane(priority int) *stream {
+	if s, ok := b.streams[priority]; ok {
+		return s
+	}
+	return b.stream
+}
+
+func (b *RedisStreamTxBus) Topic() string {
+	return b.key
+}
+
+func (b *RedisStreamTxBus) Pop(ctx context.Context) (*msg.Tx, error) {
+	return b.PopTimed(ctx, 0)
+}
+
+func (b *RedisStreamTxBus) PopTimed(ctx context.Context, duration time.Duration) (*msg.Tx, error) {
+	order := b.lanes.order()
+	ordered := make([]*stream, len(order))
+	for i, p := range order {
+		ordered[i] = b.lane(p)
+	}
+	s, m, err := readLanes(ctx, ordered, duration)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	tx := new(msg.Tx)
+	err = tx.Decode(messageValue(m, "tx"))
+	tx.Lease = m.ID
+	for p, lane := range b.streams {
+		if lane == s {
+			tx.Priority = p
+		}
+	}
+	return tx, err
+}
+
+func (b *RedisStreamTxBus) Push(ctx context.Context, tx *msg.Tx) error {
+	return b.add(ctx, b.lane(tx.Priority).key, map[string]interface{}{"tx": encodeQueued(tx)})
+}
+
+func (b *RedisStreamTxBus) PushToChain(ctx context.Context, tx *msg.Tx) error {
+	key := &StreamKey{ChainId: tx.DstChainId, TxType: tx.Type(), Priority: tx.Priority}
+	return b.add(ctx, key.Key(), map[string]interface{}{"tx": encodeQueued(tx)})
+}
+
+// Stream has no head insertion, tx is appended to the tail of the dst chain stream
+func (b *RedisStreamTxBus) PushBack(ctx context.Context, tx *msg.Tx) error {
+	return b.PushToChain(ctx, tx)
+}
+
+// Patches always go through list based patch queues
+func (b *RedisStreamTxBus) Patch(ctx context.Context, tx *msg.Tx) error {
+	return NewRedisPatchTxBus(b.db, 0).Patch(ctx, tx)
+}
+
+func (b *RedisStreamTxBus) Len(ctx context.Context) (total uint64, err error) {
+	for _, s := range b.streams {
+		size, err := s.len(ctx)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return
+}
+
+func (b *RedisStreamTxBus) LenOf(ctx context.Context, chain uint64, ty msg.TxType) (uint64, error) {
+	return NewRedisStreamTxBus(b.db, chain, ty, b.lease).Len(ctx)
+}
+
+func (b *RedisStreamTxBus) Ack(ctx context.Context, tx *msg.Tx) error {
+	return b.lane(tx.Priority).ack(ctx, tx.Lease)
+}
+
+func (b *RedisStreamTxBus) Nack(ctx context.Context, tx *msg.Tx) error {
+	return b.lane(tx.Priority).requeue(ctx, tx.Lease, map[string]interface{}{"tx": encodeQueued(tx)})
+}
+
+func (b *RedisStreamTxBus) Reap(ctx context.Context) (count uint64, err error) {
+	for _, s := range b.streams {
+		n, err := s.reap(ctx)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return
+}
+
+func (b *RedisStreamTxBus) Pending(ctx context.Context, count int64) (list []*PendingTx, err error) {
+	for _, s := range b.streams {
+		pending, err := s.pending(ctx, count)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, pending...)
+	}
+	return
+}
+
+// Redis stream sorted tx bus, score is kept in message field, txs are delivered in insertion order
+type RedisStreamSortedTxBus struct {
+	*stream
+}
+
+func NewRedisStreamSortedTxBus(db redis.UniversalClient, chainId uint64, txType msg.TxType, lease time.Duration) *RedisStreamSortedTxBus {
+	return &RedisStreamSortedTxBus{newStream(db, &SortedStreamKey{ChainId: chainId, TxType: txType}, chainId, lease)}
+}
+
+func (b *RedisStreamSortedTxBus) Topic() string {
+	return b.key
+}
+
+func (b *RedisStreamSortedTxBus) Len(ctx context.Context) (uint64, error) {
+	return b.len(ctx)
+}
+
+func (b *RedisStreamSortedTxBus) Push(ctx context.Context, tx *msg.Tx, score uint64) error {
+	return b.add(ctx, b.key, map[string]interface{}{"tx": tx.Encode(), "score": score})
+}
+
+func (b *RedisStreamSortedTxBus) Range(ctx context.Context, height uint64, count int64) (txs []*msg.Tx, err error) {
+	start := "-"
+	for count <= 0 || int64(len(txs)) < count {
+		res, err := b.db.XRangeN(ctx, b.key, start, "+", 100).Result()
+		if err != nil {
+			return txs, fmt.Errorf("Failed to range stream %v", err)
+		}
+		for _, m := range res {
+			score, _ := strconv.ParseUint(messageValue(&m, "score"), 10, 64)
+			if score > height || (count > 0 && int64(len(txs)) >= count) {
+				continue
+			}
+			tx := new(msg.Tx)
+			e := tx.Decode(messageValue(&m, "tx"))
+			if e != nil {
+				err = e
+			}
+			txs = append(txs, tx)
+		}
+		if len(res) < 100 {
+			break
+		}
+		start = "(" + res[len(res)-1].ID
+	}
+	return
+}
+
+func (b *RedisStreamSortedTxBus) Pop(ctx context.Context) (tx *msg.Tx, score uint64, err error) {
+	m, err := b.read(ctx, 0)
+	if err != nil || m == nil {
+		return
+	}
+	score, _ = strconv.ParseUint(messageValue(m, "score"), 10, 64)
+	tx = new(msg.Tx)
+	err = tx.Decode(messageValue(m, "tx"))
+	tx.Lease = m.ID
+	return
+}
+
+func (b *RedisStreamSortedTxBus) Ack(ctx context.Context, tx *msg.Tx) error {
+	return b.ack(ctx, tx.Lease)
+}
+
+func (b *RedisStreamSortedTxBus) Nack(ctx context.Context, tx *msg.Tx, score uint64) error {
+	return b.requeue(ctx, tx.Lease, map[string]interface{}{"tx": tx.Encode(), "score": score})
+}
+
+func (b *RedisStreamSortedTxBus) Reap(ctx context.Context) (uint64, error) {
+	return b.reap(ctx)
+}
+
+func (b *RedisStreamSortedTxBus) Pending(ctx context.Context, count int64) ([]*PendingTx, error) {
+	return b.pending(ctx, count)
+}
@@ -0,0 +1,122 @@
+//go:build redis
+// +build redis
+
+package bus
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Stream tests need redis 6.2+, run with `go test -tags mainnet,redis` and REDIS_ADDR
+func streamRedis(t *testing.T) redis.UniversalClient {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:6379"
+	}
+	db := redis.NewClient(&redis.Options{Addr: addr})
+	ctx := context.Background()
+	if err := db.Ping(ctx).Err(); err != nil {
+		t.Skipf("Redis not available at %s %v", addr, err)
+	}
+	t.Cleanup(func() {
+		keys, _ := db.Keys(ctx, "*stream:999*").Result()
+		if len(keys) > 0 {
+			db.Del(ctx, keys...)
+		}
+	})
+	return db
+}
+
+func TestRedisStreamTxBus(t *testing.T) {
+	ctx := context.Background()
+	b := NewRedisStreamTxBus(streamRedis(t), 999, msg.POLY, time.Minute)
+	b.Push(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "0x01", DstChainId: 999})
+	b.Push(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "0x02", DstChainId: 999, Priority: msg.PRIORITY_HIGH})
+
+	tx, err := b.PopTimed(ctx, time.Second)
+	if err != nil || tx == nil || tx.PolyHash != "0x02" || tx.Priority != msg.PRIORITY_HIGH {
+		t.Fatalf("Expect high priority tx popped first, got %v err %v", tx, err)
+	}
+	if n, _ := b.Len(ctx); n != 1 {
+		t.Fatalf("Expect popped tx excluded from len, got %v", n)
+	}
+	pending, _ := b.Pending(ctx, 10)
+	if len(pending) != 1 || pending[0].Id != tx.Lease || pending[0].Consumer != consumer {
+		t.Fatalf("Expect popped tx pending for the consumer, got %v", pending)
+	}
+
+	b.Ack(ctx, tx)
+	if pending, _ = b.Pending(ctx, 10); len(pending) != 0 {
+		t.Fatalf("Expect acked tx settled, got %v", pending)
+	}
+
+	tx, _ = b.PopTimed(ctx, time.Second)
+	if tx == nil || tx.PolyHash != "0x01" {
+		t.Fatalf("Expect normal tx popped, got %v", tx)
+	}
+	b.Nack(ctx, tx)
+	if pending, _ = b.Pending(ctx, 10); len(pending) != 0 {
+		t.Fatalf("Expect nacked tx settled, got %v", pending)
+	}
+	tx, _ = b.PopTimed(ctx, time.Second)
+	if tx == nil || tx.PolyHash != "0x01" {
+		t.Fatalf("Expect nacked tx redelivered, got %v", tx)
+	}
+	b.Ack(ctx, tx)
+}
+
+func TestRedisStreamTxBusReap(t *testing.T) {
+	ctx := context.Background()
+	b := NewRedisStreamTxBus(streamRedis(t), 999, msg.POLY, 0)
+	b.Push(ctx, &msg.Tx{TxType: msg.POLY, PolyHash: "0x01", DstChainId: 999})
+	tx, _ := b.PopTimed(ctx, time.Second)
+	if tx == nil {
+		t.Fatal("Expect tx popped")
+	}
+	if n, err := b.Reap(ctx); n != 1 || err != nil {
+		t.Fatalf("Expect idle tx claimed and requeued, got %v err %v", n, err)
+	}
+	if pending, _ := b.Pending(ctx, 10); len(pending) != 0 {
+		t.Fatalf("Expect reaped tx settled, got %v", pending)
+	}
+	again, _ := b.PopTimed(ctx, time.Second)
+	if again == nil || again.PolyHash != "0x01" || again.Lease == tx.Lease {
+		t.Fatalf("Expect reaped tx redelivered as a new message, got %v", again)
+	}
+	b.Ack(ctx, again)
+}
+
+func TestRedisStreamSortedTxBus(t *testing.T) {
+	ctx := context.Background()
+	b := NewRedisStreamSortedTxBus(streamRedis(t), 999, msg.SRC, time.Minute)
+	b.Push(ctx, &msg.Tx{TxType: msg.SRC, SrcHash: "0x01"}, 10)
+	b.Push(ctx, &msg.Tx{TxType: msg.SRC, SrcHash: "0x02"}, 20)
+
+	if txs, _ := b.Range(ctx, 15, 0); len(txs) != 1 || txs[0].SrcHash != "0x01" {
+		t.Fatalf("Expect txs ranged by score, got %v", txs)
+	}
+	tx, score, err := b.Pop(ctx)
+	if err != nil || tx.SrcHash != "0x01" || score != 10 {
+		t.Fatalf("Expect tx popped in insertion order, got %v score %v err %v", tx, score, err)
+	}
+	b.Nack(ctx, tx, 30)
+	tx, score, _ = b.Pop(ctx)
+	if tx.SrcHash != "0x02" || score != 20 {
+		t.Fatalf("Expect next tx popped, got %v score %v", tx, score)
+	}
+	b.Ack(ctx, tx)
+	tx, score, _ = b.Pop(ctx)
+	if tx.SrcHash != "0x01" || score != 30 {
+		t.Fatalf("Expect nacked tx redelivered with new score, got %v score %v", tx, score)
+	}
+	b.Ack(ctx, tx)
+	if pending, _ := b.Pending(ctx, 10); len(pending) != 0 {
+		t.Fatalf("Expect all txs settled, got %v", pending)
+	}
+}
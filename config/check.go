@@ -0,0 +1,247 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/wallet"
+)
+
+// Reject unknown keys in the config and roles files
+var STRICT bool
+
+func decode(data []byte, v interface{}) error {
+	if !STRICT {
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// Severity of config issues
+const (
+	ISSUE_ERROR = "error"
+	ISSUE_WARN  = "warn"
+)
+
+type Issue struct {
+	Level   string
+	Path    string
+	Message string
+}
+
+// Issues found by config checks
+type Report struct {
+	Issues []Issue
+}
+
+func (r *Report) Error(path, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, Issue{ISSUE_ERROR, path, fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) Warn(path, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, Issue{ISSUE_WARN, path, fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) Errors() (count int) {
+	for _, i := range r.Issues {
+		if i.Level == ISSUE_ERROR {
+			count++
+		}
+	}
+	return
+}
+
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Config check report:\n")
+	for _, i := range r.Issues {
+		fmt.Fprintf(w, "  [%s] %s: %s\n", i.Level, i.Path, i.Message)
+	}
+	fmt.Fprintf(w, "Errors: %d, warnings: %d\n", r.Errors(), len(r.Issues)-r.Errors())
+}
+
+// Config path of the task
+func TaskPath(t Task) string {
+	if t.Chain == base.POLY {
+		return "Poly.PolyTxSync"
+	}
+	field := map[string]string{
+		ROLE_HEADER_SYNC: "HeaderSync",
+		ROLE_TX_LISTEN:   "SrcTxSync",
+		ROLE_TX_COMMIT:   "SrcTxCommit",
+		ROLE_POLY_COMMIT: "PolyTxCommit",
+	}[t.Role]
+	return fmt.Sprintf("Chains.%d.%s", t.Chain, field)
+}
+
+// Check the initialized config against the enabled roles
+func (c *Config) Check(r *Report) {
+	if c.Bus == nil {
+		r.Error("Bus", "bus config missing")
+	} else {
+		switch c.Bus.Backend {
+		case BUS_REDIS, BUS_MEMORY, BUS_STREAM:
+		default:
+			r.Error("Bus.Backend", "unknown backend %s", c.Bus.Backend)
+		}
+	}
+	tasks := c.Tasks()
+	if len(tasks) == 0 {
+		r.Warn("Roles", "no roles enabled")
+	}
+	for id, chain := range c.Chains {
+		if c.Active(id) && len(chain.Nodes) == 0 && chain.Wallet == nil {
+			r.Error(fmt.Sprintf("Chains.%d", id), "chain %s enabled in roles but not configured", base.GetChainName(id))
+		}
+		checkFilter(r, fmt.Sprintf("Chains.%d.SrcFilter", id), chain.SrcFilter)
+		checkFilter(r, fmt.Sprintf("Chains.%d.DstFilter", id), chain.DstFilter)
+	}
+
+	polyWallet := false
+	for _, t := range tasks {
+		path := TaskPath(t)
+		switch conf := c.TaskConfig(t).(type) {
+		case *HeaderSyncConfig:
+			checkNodes(r, path, conf.Nodes)
+			polyWallet = true
+		case *SrcTxSyncConfig:
+			checkNodes(r, path, conf.Nodes)
+			checkContract(r, path, t.Chain, conf.CCMContract)
+		case *SrcTxCommitConfig:
+			checkNodes(r, path, conf.Nodes)
+			checkContract(r, path, t.Chain, conf.CCMContract)
+			checkFilter(r, path+".Filter", conf.Filter)
+			polyWallet = true
+		case *PolyTxSyncConfig:
+			checkNodes(r, path, conf.Nodes)
+		case *PolyTxCommitConfig:
+			if conf.SubmitterConfig == nil {
+				r.Error(path, "submitter config missing")
+				continue
+			}
+			checkNodes(r, path, conf.Nodes)
+			checkContract(r, path, t.Chain, conf.CCMContract)
+			checkFilter(r, path+".Filter", conf.Filter)
+			checkWallet(r, path+".Wallet", conf.Wallet)
+			if conf.CheckFee && len(c.Bridge) == 0 {
+				r.Error("Bridge", "bridge endpoints required to check fee for chain %s", base.GetChainName(t.Chain))
+			}
+		}
+	}
+	if polyWallet {
+		if c.Poly == nil || len(c.Poly.Nodes) == 0 {
+			r.Error("Poly.Nodes", "poly nodes required to submit to poly")
+		}
+		if c.Poly != nil {
+			checkWallet(r, "Poly.Wallet", c.Poly.Wallet)
+		}
+	}
+}
+
+func checkNodes(r *Report, path string, nodes []string) {
+	if len(nodes) == 0 {
+		r.Error(path+".Nodes", "no nodes specified")
+	}
+}
+
+// CCM contract of eth compatible chains, other chains use native contracts
+func checkContract(r *Report, path string, chain uint64, address string) {
+	if !base.SameAsETH(chain) {
+		return
+	}
+	if address == "" {
+		r.Error(path+".CCMContract", "CCMContract missing")
+	} else if !isHex(address, 20) {
+		r.Error(path+".CCMContract", "invalid address %s", address)
+	}
+}
+
+func checkFilter(r *Report, path string, f *FilterConfig) {
+	if f == nil {
+		return
+	}
+	check := func(name string, enabled bool, addresses []string) {
+		for _, a := range addresses {
+			if !isHex(a, 0) {
+				r.Error(name, "invalid address %s", a)
+			}
+		}
+		if enabled && len(addresses) == 0 {
+			r.Warn(name, "filter enabled with empty list, all txs are skipped")
+		}
+	}
+	check(path+".SrcProxies", f.SrcProxyFilter, f.SrcProxies)
+	check(path+".DstProxies", f.DstProxyFilter, f.DstProxies)
+	check(path+".Addresses", false, f.Addresses)
+	if f.Default != "" && f.Default != FILTER_ALLOW && f.Default != FILTER_DENY {
+		r.Error(path+".Default", "invalid action %s", f.Default)
+	}
+	for i, rule := range f.Rules {
+		p := fmt.Sprintf("%s.Rules.%d", path, i)
+		if rule == nil {
+			r.Error(p, "empty rule")
+			continue
+		}
+		if rule.Action != FILTER_ALLOW && rule.Action != FILTER_DENY {
+			r.Error(p+".Action", "invalid action %s, should be allow or deny", rule.Action)
+		}
+		check(p+".SrcProxies", false, rule.SrcProxies)
+		check(p+".DstProxies", false, rule.DstProxies)
+		check(p+".Assets", false, rule.Assets)
+		check(p+".Senders", false, rule.Senders)
+		if rule.MinAmount != nil && rule.MaxAmount != nil && rule.MinAmount.Cmp(rule.MaxAmount) > 0 {
+			r.Warn(p, "MinAmount is greater than MaxAmount, rule never matches")
+		}
+	}
+}
+
+func checkWallet(r *Report, path string, w *wallet.Config) {
+	if w == nil {
+		r.Error(path, "wallet config missing")
+		return
+	}
+	if w.Path != "" {
+		if _, err := os.Stat(w.Path); err != nil {
+			r.Error(path+".Path", "wallet file not found %s", w.Path)
+		}
+	}
+	for i, p := range w.KeyStoreProviders {
+		if _, err := os.Stat(p.Path); err != nil {
+			r.Error(fmt.Sprintf("%s.KeyStoreProviders.%d", path, i), "keystore not found %s", p.Path)
+		}
+	}
+	if w.Path == "" && len(w.KeyStoreProviders) == 0 && len(w.KeyProviders) == 0 && w.PrivateKey == "" {
+		r.Error(path, "no keys specified")
+	}
+}
+
+// Hex string of the byte size, any size if zero
+func isHex(s string, size int) bool {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	data, err := hex.DecodeString(s)
+	return err == nil && len(data) > 0 && (size == 0 || len(data) == size)
+}
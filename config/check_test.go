@@ -0,0 +1,39 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"testing"
+)
+
+func TestCheckFilter(t *testing.T) {
+	r := new(Report)
+	checkFilter(r, "Filter", &FilterConfig{
+		SrcProxyFilter: true,
+		DstProxies:     []string{"0x250e76987d838a75310c34bf422ea9f1ac4cc906", "not an address"},
+	})
+	if r.Errors() != 1 || len(r.Issues) != 2 {
+		t.Fatalf("Unexpected issues %+v", r.Issues)
+	}
+	if r.Issues[0].Path != "Filter.SrcProxies" || r.Issues[0].Level != ISSUE_WARN {
+		t.Fatalf("Expect empty src proxy list warning, got %+v", r.Issues[0])
+	}
+	if !isHex("0x250e76987d838a75310c34bf422ea9f1ac4cc906", 20) || isHex("0x250e", 20) || isHex("", 0) {
+		t.Fatal("Unexpected hex check")
+	}
+}
@@ -20,12 +20,16 @@ package config
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"math/big"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/log"
 	"github.com/polynetwork/bridge-common/tools"
 	"github.com/polynetwork/bridge-common/util"
 	"github.com/polynetwork/bridge-common/wallet"
@@ -39,6 +43,7 @@ var (
 	CONFIG_PATH string
 	ENCRYPTED   bool
 	PLAIN       bool
+	KEY_FILE    string // Passphrase file of the encrypted config
 )
 
 type Config struct {
@@ -46,10 +51,12 @@ type Config struct {
 	Bus    *BusConfig
 	Poly   *PolyChainConfig
 	Chains map[uint64]*ChainConfig
+	Shard  *ShardConfig
 
 	// Http
-	Host string
-	Port int
+	Host       string
+	Port       int
+	AdminToken string // Bearer token of the admin api, disabled if empty
 
 	ValidMethods []string
 	validMethods map[string]bool
@@ -79,25 +86,30 @@ func GetConfigPath(path, file string) string {
 }
 
 func New(path string) (config *Config, err error) {
-	data, err := ioutil.ReadFile(path)
+	tree, err := load(path)
 	if err != nil {
-		return nil, fmt.Errorf("Read config file error %v", err)
-	}
-	if ENCRYPTED {
-		var passphrase []byte
-		if PLAIN {
-			passphrase, err = msg.ReadInput("passphrase")
-		} else {
-			passphrase, err = msg.ReadPassword("passphrase")
-		}
-		if err != nil { return nil, err }
-		data = msg.Decrypt(data, passphrase)
+		return nil, err
+	}
+	v, err := substitute(tree)
+	if err != nil {
+		return nil, fmt.Errorf("Resolve config references error %v", err)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("Parse config file error %v", err)
 	}
 	config = &Config{chains: map[uint64]bool{}}
-	err = json.Unmarshal(data, config)
+	err = decode(data, config)
 	if err != nil {
 		return nil, fmt.Errorf("Parse config file error %v", err)
 	}
+	overrides, err := config.ApplyEnv(os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	if len(overrides) > 0 {
+		log.Info("Applied config env overrides", "envs", overrides)
+	}
 	if config.Env != base.ENV {
 		util.Fatal("Config env(%s) and build env(%s) does not match!", config.Env, base.ENV)
 	}
@@ -134,6 +146,11 @@ type ChainConfig struct {
 	Wallet            *wallet.Config
 	SrcFilter         *FilterConfig
 	DstFilter         *FilterConfig
+	DeadLetter        *DeadLetterConfig
+	Retry             map[string]*RetryConfig // Retry policies keyed by error class
+	DynamicFee        *DynamicFeeConfig       // Submit EIP-1559 dynamic fee txs if specified
+	GasBump           *GasBumpConfig          // Replacement of stuck dst txs
+	Confirms          int                     // Dst tx confirmations before final, chain default if zero, negative for none
 
 	HeaderSync   *HeaderSyncConfig   // chain -> ch -> poly
 	SrcTxSync    *SrcTxSyncConfig    // chain -> mq
@@ -184,6 +201,60 @@ type SubmitterConfig struct {
 	CCMContract string
 	CCDContract string
 	Wallet      *wallet.Config
+	Retry       map[string]*RetryConfig
+	DynamicFee  *DynamicFeeConfig
+	GasBump     *GasBumpConfig
+	Confirms    int
+	Bus         *BusConfig `json:"-"` // Bus of the role, stores the account nonces
+}
+
+// Fees of EIP-1559 dynamic fee txs derived from eth_feeHistory of recent blocks
+type DynamicFeeConfig struct {
+	Blocks        int      // Recent blocks to sample, 10 by default
+	TipPercentile float64  // Reward percentile of the sampled blocks, the median is used as tip, 50 by default
+	BaseFeeX      float64  // Multiplier of the next block base fee in the fee cap, 2 by default
+	MinTip        *big.Int // Tip floor in wei
+	MaxTip        *big.Int // Tip cap in wei
+	MaxFee        *big.Int // Fee cap in wei
+}
+
+// Stuck dst txs are sent again with the same nonce and bumped gas price
+type GasBumpConfig struct {
+	Deadline int64    // Seconds pending before a bump, chain default if unspecified
+	Ratio    float64  // Bump ratio, at least 10% for legacy txs and 12.5% for dynamic fee txs
+	MaxPrice *big.Int // Gas price or fee cap ceiling in wei, 3 times the original price if unspecified
+	MaxBumps int      // Max replacements of a tx, 5 by default, negative to disable
+}
+
+// Retry delay of attempt n: min(Delay * Factor^(n-1), Max) * (1 + Jitter * rand[0, 1))
+type RetryConfig struct {
+	Delay  int64   // Base delay in seconds
+	Factor float64 // Backoff multiplier per attempt, no backoff if unspecified
+	Jitter float64 // Max random ratio added to the delay
+	Max    int64   // Delay cap in seconds, no cap if unspecified
+}
+
+// Fill unspecified fields with the default
+func (c *RetryConfig) Fill(o *RetryConfig) *RetryConfig {
+	if c == nil {
+		c = new(RetryConfig)
+	}
+	if o == nil {
+		return c
+	}
+	if c.Delay == 0 {
+		c.Delay = o.Delay
+	}
+	if c.Factor == 0 {
+		c.Factor = o.Factor
+	}
+	if c.Jitter == 0 {
+		c.Jitter = o.Jitter
+	}
+	if c.Max == 0 {
+		c.Max = o.Max
+	}
+	return c
 }
 
 type WalletConfig struct {
@@ -192,25 +263,80 @@ type WalletConfig struct {
 	KeyPwd   map[string]string
 }
 
+const (
+	BUS_REDIS  = "redis"
+	BUS_MEMORY = "memory"
+	BUS_STREAM = "stream" // Redis streams for tx buses, other stores stay on redis
+)
+
+// Default leader lease in seconds
+const LEADER_TTL = 15
+
 type BusConfig struct {
-	Redis                *redis.Options `json:"-"`
+	Backend              string                  // redis(default), stream or memory
+	Redis                *redis.UniversalOptions `json:"-"`
 	HeightUpdateInterval uint64
-	Config               *struct {
-		Network    string
-		Addr       string
-		Username   string
-		Password   string
-		DB         int
-		MaxRetries int
+	Reliable             bool   // Keep popped txs in flight till acked
+	LeaseTime            uint64 // In flight tx lease time in seconds before getting redelivered
+	DedupTime            uint64 // Window in seconds to drop duplicate txs pushed to tx buses, zero to disable
+	PriorityWeight       uint64 // High priority pops served for every normal priority pop, default 4
+	LeaderTTL            uint64 // Leader lease in seconds before standby replicas take over, default 15
+	Config               *RedisConfig
+}
+
+func (c *BusConfig) Lease() time.Duration {
+	return time.Duration(c.LeaseTime) * time.Second
+}
+
+func (c *BusConfig) Dedup() time.Duration {
+	return time.Duration(c.DedupTime) * time.Second
+}
+
+func (c *BusConfig) LeaderLease() time.Duration {
+	return time.Duration(c.LeaderTTL) * time.Second
+}
+
+// Redis cluster mode, keys used together need hash tags
+func (c *BusConfig) Cluster() bool {
+	return c.Config != nil && c.Config.Mode == REDIS_CLUSTER
+}
+
+var hashTag int32
+
+// Wrap bus keys in hash tags, enabled by the bus config init on redis cluster before any bus is created
+func HashTag() bool {
+	return atomic.LoadInt32(&hashTag) == 1
+}
+
+func SetHashTag(on bool) {
+	var v int32
+	if on {
+		v = 1
 	}
+	atomic.StoreInt32(&hashTag, v)
 }
 
-func (c *BusConfig) Init() {
-	c.Redis = new(redis.Options)
+func (c *BusConfig) Init() (err error) {
+	if c.Backend == "" {
+		c.Backend = BUS_REDIS
+	}
+	if c.LeaseTime == 0 {
+		c.LeaseTime = 600
+	}
+	if c.LeaderTTL == 0 {
+		c.LeaderTTL = LEADER_TTL
+	}
+	if c.Cluster() {
+		SetHashTag(true)
+	}
+	c.Redis = new(redis.UniversalOptions)
 	if c.Config != nil {
-		v, _ := json.Marshal(c.Config)
-		json.Unmarshal(v, c.Redis)
+		c.Redis, err = c.Config.Options()
+		if err != nil {
+			return fmt.Errorf("Invalid redis config %v", err)
+		}
 	}
+	return
 }
 
 type HeaderSyncConfig struct {
@@ -253,8 +379,30 @@ type PolyTxCommitConfig struct {
 	Procs            int
 	Enabled          bool
 	CheckFee         bool
+	OverpaidRatio    float64 // Paid over min fee ratio to serve txs with high priority, default 2
 	Bus              *BusConfig
 	Filter           *FilterConfig
+	DeadLetter       *DeadLetterConfig
+}
+
+// Policy to move txs exhausted retries into dead letter bus
+type DeadLetterConfig struct {
+	MaxAttempts int   // Max failed attempts, unlimited if zero
+	MaxAge      int64 // Max seconds since the first failure, unlimited if zero
+}
+
+// Check if the tx exhausted retries, returns the reason
+func (c *DeadLetterConfig) Exhausted(tx *msg.Tx) (reason string, exhausted bool) {
+	if c == nil {
+		return
+	}
+	if c.MaxAttempts > 0 && tx.Attempts > c.MaxAttempts {
+		return fmt.Sprintf("attempts %d exceeded max %d", tx.Attempts, c.MaxAttempts), true
+	}
+	if c.MaxAge > 0 && tx.FirstFailure > 0 && time.Now().Unix()-tx.FirstFailure > c.MaxAge {
+		return fmt.Sprintf("failing for over %d seconds", c.MaxAge), true
+	}
+	return
 }
 
 func (c *Config) Active(chain uint64) bool {
@@ -269,7 +417,13 @@ func (c *Config) Init() (err error) {
 		c.Port = 6500
 	}
 	if c.Bus != nil {
-		c.Bus.Init()
+		err = c.Bus.Init()
+		if err != nil {
+			return
+		}
+	}
+	if c.Shard != nil {
+		c.Shard.Init()
 	}
 
 	if c.Poly != nil {
@@ -385,9 +539,16 @@ func (c *ChainConfig) Init(chain uint64, bus *BusConfig, poly *PolyChainConfig)
 	if c.PolyTxCommit.Bus == nil {
 		c.PolyTxCommit.Bus = bus
 	}
+	c.PolyTxCommit.SubmitterConfig.Bus = c.PolyTxCommit.Bus
 	if c.PolyTxCommit.Filter == nil {
 		c.PolyTxCommit.Filter = c.DstFilter
 	}
+	if c.PolyTxCommit.DeadLetter == nil {
+		c.PolyTxCommit.DeadLetter = c.DeadLetter
+	}
+	if c.PolyTxCommit.DeadLetter == nil && base.ENV == "testnet" {
+		c.PolyTxCommit.DeadLetter = &DeadLetterConfig{MaxAttempts: 1000}
+	}
 	return
 }
 
@@ -423,6 +584,18 @@ func (c *ChainConfig) FillSubmitter(o *SubmitterConfig) *SubmitterConfig {
 	if o.CCDContract == "" {
 		o.CCDContract = c.CCDContract
 	}
+	if o.Retry == nil {
+		o.Retry = c.Retry
+	}
+	if o.DynamicFee == nil {
+		o.DynamicFee = c.DynamicFee
+	}
+	if o.GasBump == nil {
+		o.GasBump = c.GasBump
+	}
+	if o.Confirms == 0 {
+		o.Confirms = c.Confirms
+	}
 
 	return o
 }
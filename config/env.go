@@ -0,0 +1,178 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/polynetwork/bridge-common/wallet"
+)
+
+// Config string references resolved on load: ${ENV:NAME} and ${FILE:/path}
+var reference = regexp.MustCompile(`\$\{(ENV|FILE):([^}]+)\}`)
+
+// Resolve references in the string values of the config tree
+func substitute(v interface{}) (interface{}, error) {
+	var err error
+	switch value := v.(type) {
+	case string:
+		return resolve(value)
+	case []interface{}:
+		for i := range value {
+			value[i], err = substitute(value[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+	case map[string]interface{}:
+		for k := range value {
+			value[k], err = substitute(value[k])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+func resolve(s string) (string, error) {
+	var err error
+	value := reference.ReplaceAllStringFunc(s, func(ref string) string {
+		m := reference.FindStringSubmatch(ref)
+		if m[1] == "ENV" {
+			v, ok := os.LookupEnv(m[2])
+			if !ok && err == nil {
+				err = fmt.Errorf("Config env %s not set", m[2])
+			}
+			return v
+		}
+		data, e := ioutil.ReadFile(m[2])
+		if e != nil && err == nil {
+			err = fmt.Errorf("Config secret file error %v", e)
+		}
+		return strings.TrimRight(string(data), "\r\n")
+	})
+	return value, err
+}
+
+// Prefix of the env overrides
+const ENV_PREFIX = "RELAYER_"
+
+var chainEnv = regexp.MustCompile(`^CHAIN_(\d+)_(NODES|WALLET_PASSWORD)$`)
+
+func list(v string) (values []string) {
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return
+}
+
+func (c *Config) redis() *RedisConfig {
+	if c.Bus == nil {
+		c.Bus = new(BusConfig)
+	}
+	if c.Bus.Config == nil {
+		c.Bus.Config = new(RedisConfig)
+	}
+	return c.Bus.Config
+}
+
+func (c *Config) poly() *PolyChainConfig {
+	if c.Poly == nil {
+		c.Poly = new(PolyChainConfig)
+	}
+	return c.Poly
+}
+
+func (c *Config) polyWallet() *wallet.Config {
+	if c.poly().Wallet == nil {
+		c.Poly.Wallet = new(wallet.Config)
+	}
+	return c.Poly.Wallet
+}
+
+// Override config fields with RELAYER_ prefixed envs, list values are comma separated, returns the applied env names
+func (c *Config) ApplyEnv(environ []string) (applied []string, err error) {
+	for _, item := range environ {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], ENV_PREFIX) {
+			continue
+		}
+		name, v := strings.TrimPrefix(parts[0], ENV_PREFIX), parts[1]
+		switch name {
+		case "REDIS_ADDR":
+			c.redis().Addr = v
+		case "REDIS_ADDRS":
+			c.redis().Addrs = list(v)
+		case "REDIS_USERNAME":
+			c.redis().Username = v
+		case "REDIS_PASSWORD":
+			c.redis().Password = v
+		case "REDIS_SENTINEL_PASSWORD":
+			c.redis().SentinelPassword = v
+		case "POLY_NODES":
+			c.poly().Nodes = list(v)
+		case "POLY_WALLET_PASSWORD":
+			c.polyWallet().Password = v
+		case "BRIDGE":
+			c.Bridge = list(v)
+		case "HOST":
+			c.Host = v
+		case "PORT":
+			c.Port, err = strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid env %s %v", parts[0], err)
+			}
+		case "ADMIN_TOKEN":
+			c.AdminToken = v
+		default:
+			m := chainEnv.FindStringSubmatch(name)
+			if m == nil {
+				continue
+			}
+			id, _ := strconv.ParseUint(m[1], 10, 64)
+			if c.Chains == nil {
+				c.Chains = map[uint64]*ChainConfig{}
+			}
+			chain := c.Chains[id]
+			if chain == nil {
+				chain = new(ChainConfig)
+				c.Chains[id] = chain
+			}
+			if m[2] == "NODES" {
+				chain.Nodes = list(v)
+			} else {
+				if chain.Wallet == nil {
+					chain.Wallet = new(wallet.Config)
+				}
+				chain.Wallet.Password = v
+			}
+		}
+		applied = append(applied, parts[0])
+	}
+	sort.Strings(applied)
+	return
+}
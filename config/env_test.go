@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubstitute(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secret := filepath.Join(dir, "secret")
+	ioutil.WriteFile(secret, []byte("p\"ss\n"), 0600)
+	os.Setenv("TEST_REDIS_HOST", "redis")
+	defer os.Unsetenv("TEST_REDIS_HOST")
+
+	var tree interface{}
+	err = parseJson([]byte(`{"Bus":{"Config":{"Addr":"${ENV:TEST_REDIS_HOST}:6379","Password":"${FILE:`+secret+`}"}},"Chains":{"2":{"NativeId":18446744073709551615}}}`), &tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err = substitute(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(tree)
+	c := new(Config)
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Bus.Config.Addr != "redis:6379" || c.Bus.Config.Password != `p"ss` || c.Chains[2].NativeId != 18446744073709551615 {
+		t.Fatalf("Unexpected substitution %s", data)
+	}
+	_, err = substitute(map[string]interface{}{"Host": "${ENV:TEST_MISSING_ENV}"})
+	if err == nil {
+		t.Fatal("Expect error for missing env")
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	c := new(Config)
+	applied, err := c.ApplyEnv([]string{
+		"RELAYER_REDIS_PASSWORD=secret", "RELAYER_CHAIN_2_NODES=http://a, http://b", "RELAYER_CHAIN_2_WALLET_PASSWORD=pass",
+		"RELAYER_PORT=6600", "RELAYER_BIN=/bin", "HOME=/root",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 4 || c.Bus.Config.Password != "secret" || c.Port != 6600 {
+		t.Fatalf("Unexpected overrides %v", applied)
+	}
+	if len(c.Chains[2].Nodes) != 2 || c.Chains[2].Nodes[1] != "http://b" || c.Chains[2].Wallet.Password != "pass" {
+		t.Fatalf("Unexpected chain overrides %+v", c.Chains[2])
+	}
+}
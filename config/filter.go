@@ -18,35 +18,66 @@
 package config
 
 import (
+	"fmt"
+	"math/big"
+
 	"github.com/polynetwork/bridge-common/util"
 	"github.com/polynetwork/poly-relayer/msg"
 )
 
+const (
+	FILTER_ALLOW = "allow"
+	FILTER_DENY  = "deny"
+)
+
 type FilterConfig struct {
-	SrcProxyFilter bool     // Enable src proxy filter
-	DstProxyFilter bool     // Enable dst proxy filter
-	SrcProxies     []string // Desired src proxy list
-	DstProxies     []string // Desired dst proxy list
-	AddressFilter  bool     // Enable address filter
-	Addresses      []string // Address black list
+	SrcProxyFilter bool          // Enable src proxy filter
+	DstProxyFilter bool          // Enable dst proxy filter
+	SrcProxies     []string      // Desired src proxy list
+	DstProxies     []string      // Desired dst proxy list
+	AddressFilter  bool          // Enable address filter
+	Addresses      []string      // Address black list
+	Rules          []*FilterRule `json:",omitempty"` // Ordered rules checked after the lists above, first match decides
+	Default        string        `json:",omitempty"` // Action if no rule matches, allow by default
 }
 
-func (c *FilterConfig) Init() {
-	parse := func(addresses []string) []string {
-		proxies := []string{}
-		for _, p := range addresses {
-			p = util.LowerHex(p)
-			if len(p) > 0 {
-				proxies = append(proxies, p)
-			}
+// Filter rule matches txs meeting all the specified conditions, empty conditions match any
+type FilterRule struct {
+	Action     string   // allow or deny
+	Reason     string   `json:",omitempty"` // Drop reason to log and count, rule index by default
+	SrcChains  []uint64 `json:",omitempty"`
+	DstChains  []uint64 `json:",omitempty"`
+	Methods    []string `json:",omitempty"` // Make tx param methods, like unlock
+	SrcProxies []string `json:",omitempty"`
+	DstProxies []string `json:",omitempty"`
+	Assets     []string `json:",omitempty"` // Dst asset decoded from lock proxy args
+	Senders    []string `json:",omitempty"` // Src tx sender
+	MinAmount  *big.Int `json:",omitempty"` // Inclusive amount range decoded from lock proxy args
+	MaxAmount  *big.Int `json:",omitempty"`
+}
+
+func parseAddresses(addresses []string) []string {
+	proxies := []string{}
+	for _, p := range addresses {
+		p = util.LowerHex(p)
+		if len(p) > 0 {
+			proxies = append(proxies, p)
 		}
-		return proxies
 	}
-	c.SrcProxies = parse(c.SrcProxies)
-	c.DstProxies = parse(c.DstProxies)
-	c.Addresses = parse(c.Addresses)
+	return proxies
 }
 
+func (c *FilterConfig) Init() {
+	c.SrcProxies = parseAddresses(c.SrcProxies)
+	c.DstProxies = parseAddresses(c.DstProxies)
+	c.Addresses = parseAddresses(c.Addresses)
+	for _, r := range c.Rules {
+		r.SrcProxies = parseAddresses(r.SrcProxies)
+		r.DstProxies = parseAddresses(r.DstProxies)
+		r.Assets = parseAddresses(r.Assets)
+		r.Senders = parseAddresses(r.Senders)
+	}
+}
 func filterOut(enabled bool, sets []string, value string) bool {
 	if enabled {
 		value = util.LowerHex(value)
@@ -80,10 +111,115 @@ func filter(enabled bool, sets []string, value string) bool {
 	return true
 }
 
-func (c *FilterConfig) Check(tx *msg.Tx) bool {
+// Match result of a rule condition
+type match int
+
+const (
+	matchNo match = iota
+	matchYes
+	matchUnknown // Tx field is not available yet
+)
+
+func matchChain(chains []uint64, chain uint64) match {
+	if len(chains) == 0 {
+		return matchYes
+	}
+	for _, c := range chains {
+		if c == chain {
+			return matchYes
+		}
+	}
+	return matchNo
+}
+
+func matchString(values []string, value string, hex bool) match {
+	if len(values) == 0 {
+		return matchYes
+	}
+	if hex {
+		value = util.LowerHex(value)
+	}
+	if value == "" {
+		return matchUnknown
+	}
+	for _, v := range values {
+		if v == value {
+			return matchYes
+		}
+	}
+	return matchNo
+}
+
+func (r *FilterRule) match(tx *msg.Tx) match {
+	res := matchYes
+	and := func(m match) {
+		if m == matchNo || res == matchNo {
+			res = matchNo
+		} else if m == matchUnknown {
+			res = matchUnknown
+		}
+	}
+	and(matchChain(r.SrcChains, tx.SrcChainId))
+	and(matchChain(r.DstChains, tx.DstChainId))
+	and(matchString(r.SrcProxies, tx.SrcProxy, true))
+	and(matchString(r.DstProxies, tx.DstProxy, true))
+	and(matchString(r.Senders, tx.SrcAddress, true))
+	if len(r.Methods) > 0 {
+		if param := tx.MakeTxParam(); param != nil {
+			and(matchString(r.Methods, param.Method, false))
+		} else {
+			and(matchUnknown)
+		}
+	}
+	if len(r.Assets) > 0 || r.MinAmount != nil || r.MaxAmount != nil {
+		if tx.DecodeArgs() {
+			and(matchString(r.Assets, tx.DstAsset, true))
+			if r.MinAmount != nil && tx.DstAmount.Cmp(r.MinAmount) < 0 {
+				and(matchNo)
+			}
+			if r.MaxAmount != nil && tx.DstAmount.Cmp(r.MaxAmount) > 0 {
+				and(matchNo)
+			}
+		} else {
+			and(matchUnknown)
+		}
+	}
+	return res
+}
+
+// Evaluate the filter, returns the reason if the tx should be dropped.
+// Rules depending on tx fields not available yet pass the tx unless final, as txs are checked again once composed.
+// Rules with unavailable fields do not match on final evaluation.
+func (c *FilterConfig) Evaluate(tx *msg.Tx, final bool) (ok bool, reason string) {
 	if c == nil {
-		return true
+		return true, ""
+	}
+	switch {
+	case !filter(c.SrcProxyFilter, c.SrcProxies, tx.SrcProxy):
+		return false, "src proxy not allowed"
+	case !filter(c.DstProxyFilter, c.DstProxies, tx.DstProxy):
+		return false, "dst proxy not allowed"
+	case !filterOut(c.AddressFilter, c.Addresses, tx.SrcAddress) || !filterOut(c.AddressFilter, c.Addresses, tx.DstAddress):
+		return false, "address blocked"
+	}
+	for i, r := range c.Rules {
+		switch r.match(tx) {
+		case matchUnknown:
+			if !final {
+				return true, ""
+			}
+		case matchYes:
+			if r.Action == FILTER_ALLOW {
+				return true, ""
+			}
+			if r.Reason != "" {
+				return false, r.Reason
+			}
+			return false, fmt.Sprintf("denied by rule %d", i)
+		}
+	}
+	if c.Default == FILTER_DENY {
+		return false, "denied by default"
 	}
-	return filter(c.SrcProxyFilter, c.SrcProxies, tx.SrcProxy) && filter(c.DstProxyFilter, c.DstProxies, tx.DstProxy) &&
-		filterOut(c.AddressFilter, c.Addresses, tx.SrcAddress) && filterOut(c.AddressFilter, c.Addresses, tx.DstAddress)
+	return true, ""
 }
@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	pcom "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func lockTx(method string, asset []byte, amount int64) *msg.Tx {
+	sink := pcom.NewZeroCopySink(nil)
+	sink.WriteVarBytes(asset)
+	sink.WriteVarBytes([]byte{1, 2, 3})
+	value := make([]byte, 32)
+	data := big.NewInt(amount).Bytes()
+	for i, b := range data {
+		value[len(data)-1-i] = b
+	}
+	sink.WriteBytes(value)
+	return &msg.Tx{
+		SrcChainId: 2,
+		DstChainId: 6,
+		SrcProxy:   "0xAA",
+		DstProxy:   "0xbb",
+		Param:      &common.MakeTxParam{Method: method, Args: sink.Bytes()},
+	}
+}
+
+func TestFilterRules(t *testing.T) {
+	f := new(FilterConfig)
+	err := json.Unmarshal([]byte(`{
+		"Rules": [
+			{"Action": "deny", "Reason": "large usdt", "Assets": ["0x0C"], "MinAmount": 1000},
+			{"Action": "allow", "SrcChains": [2], "Methods": ["unlock"]},
+			{"Action": "deny", "DstProxies": ["0xBB"]}
+		],
+		"Default": "deny"
+	}`), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Init()
+
+	cases := []struct {
+		tx     *msg.Tx
+		ok     bool
+		reason string
+	}{
+		{lockTx("unlock", []byte{0x0c}, 1000), false, "large usdt"},
+		{lockTx("unlock", []byte{0x0c}, 999), true, ""},
+		{lockTx("unlock", []byte{0x0d}, 5000), true, ""},
+		{lockTx("other", []byte{0x0d}, 1), false, "denied by rule 2"},
+		{&msg.Tx{SrcChainId: 3, DstProxy: "0xcc"}, false, "denied by default"},
+	}
+	for i, c := range cases {
+		ok, reason := f.Evaluate(c.tx, true)
+		if ok != c.ok || reason != c.reason {
+			t.Fatalf("Case %d expect %v %q, got %v %q", i, c.ok, c.reason, ok, reason)
+		}
+	}
+
+	// Rules depending on the tx param pass before the tx is composed
+	tx := &msg.Tx{SrcChainId: 2, DstProxy: "0xbb"}
+	if ok, _ := f.Evaluate(tx, false); !ok {
+		t.Fatal("Expect tx to pass before composed")
+	}
+	if ok, reason := f.Evaluate(tx, true); ok || reason != "denied by rule 2" {
+		t.Fatalf("Unexpected final evaluation %v %q", ok, reason)
+	}
+}
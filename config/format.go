@@ -0,0 +1,236 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Parse the config file as a generic tree, yaml or toml by extension, json otherwise
+func parseFile(path string, encrypted bool) (tree interface{}, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Read config file error %v", err)
+	}
+	if encrypted {
+		passphrase, err := Passphrase()
+		if err != nil {
+			return nil, err
+		}
+		if !msg.IsEncrypted(data) {
+			log.Warn("Config file is in legacy encryption format, migrate with migratefile command", "path", path)
+		}
+		data, err = msg.Decrypt(data, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("Decrypt config file %s error %v", path, err)
+		}
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+		tree = normalize(tree)
+	case ".toml":
+		var t *toml.Tree
+		t, err = toml.LoadBytes(data)
+		if err == nil {
+			// Arrays of tables as generic arrays
+			data, _ = json.Marshal(t.ToMap())
+			err = parseJson(data, &tree)
+		}
+	default:
+		err = parseJson(data, &tree)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Parse config file %s error %v", path, err)
+	}
+	return
+}
+
+func parseJson(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // Keep big integers as is
+	return dec.Decode(v)
+}
+
+// Yaml maps with non-string keys, like chain ids, as json objects
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[fmt.Sprint(k)] = normalize(item)
+		}
+		return m
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = normalize(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalize(item)
+		}
+	}
+	return v
+}
+
+// Key of the object matching the field name, case insensitive as json decoding
+func field(m map[string]interface{}, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return ""
+}
+
+// Merge the override object into the base one, fields of the override take precedence
+func merge(base, override map[string]interface{}) map[string]interface{} {
+	if base == nil {
+		return override
+	}
+	for k, v := range override {
+		key := field(base, k)
+		if key == "" {
+			key = k
+		}
+		a, ok := base[key].(map[string]interface{})
+		b, found := v.(map[string]interface{})
+		if ok && found {
+			base[key] = merge(a, b)
+		} else {
+			base[key] = v
+		}
+	}
+	return base
+}
+
+// Remove the field from the object
+func take(m map[string]interface{}, name string) (v interface{}, ok bool) {
+	key := field(m, name)
+	if key == "" {
+		return nil, false
+	}
+	v = m[key]
+	delete(m, key)
+	return v, true
+}
+
+func includeChain(path string) (map[string]interface{}, error) {
+	tree, err := parseFile(path, ENCRYPTED)
+	if err != nil {
+		return nil, err
+	}
+	chain, ok := tree.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Included chain config %s is not an object", path)
+	}
+	return chain, nil
+}
+
+// Load the config tree with included chain files merged into Chains, as if the chain configs were inline.
+// Chain entries can include a file with "Include": "chains/56.yaml", fields of the entry take precedence.
+// Top level "Include": ["chains/*.yaml"] adds chain files keyed by ChainId or the file name, inline entries take precedence.
+// Include paths are relative to the config file.
+func load(path string) (tree map[string]interface{}, err error) {
+	v, err := parseFile(path, ENCRYPTED)
+	if err != nil {
+		return
+	}
+	tree, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Config file %s is not an object", path)
+	}
+	dir := filepath.Dir(path)
+	key := field(tree, "Chains")
+	if key == "" {
+		key = "Chains"
+	}
+	chains, _ := tree[key].(map[string]interface{})
+	if chains == nil {
+		chains = map[string]interface{}{}
+	}
+
+	for id, entry := range chains {
+		m, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		include, ok := take(m, "Include")
+		if !ok {
+			continue
+		}
+		file, ok := include.(string)
+		if !ok {
+			return nil, fmt.Errorf("Invalid include of chain %s", id)
+		}
+		chain, err := includeChain(GetConfigPath(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		chains[id] = merge(chain, m)
+	}
+
+	include, ok := take(tree, "Include")
+	if ok {
+		var patterns []string
+		switch value := include.(type) {
+		case string:
+			patterns = []string{value}
+		case []interface{}:
+			for _, p := range value {
+				patterns = append(patterns, fmt.Sprint(p))
+			}
+		default:
+			return nil, fmt.Errorf("Invalid config include %v", include)
+		}
+		for _, pattern := range patterns {
+			files, err := filepath.Glob(GetConfigPath(dir, pattern))
+			if err != nil || len(files) == 0 {
+				return nil, fmt.Errorf("Config include %s matches no files", pattern)
+			}
+			for _, file := range files {
+				chain, err := includeChain(file)
+				if err != nil {
+					return nil, err
+				}
+				id := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+				if k := field(chain, "ChainId"); k != "" {
+					id = fmt.Sprint(chain[k])
+				}
+				inline, _ := chains[id].(map[string]interface{})
+				chains[id] = merge(chain, inline)
+			}
+		}
+	}
+	tree[key] = chains
+	return
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/polynetwork/bridge-common/base"
+)
+
+func write(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write(t, dir, "chains/bsc.toml", `
+ChainId = 6
+Nodes = ["http://bsc:8545"]
+CheckFee = true
+`)
+	write(t, dir, "chains/2.yaml", `
+Nodes: ["http://eth:8545"]
+Defer: 10
+`)
+	write(t, dir, "chains/heco.yml", `
+ChainId: 7
+Nodes: ["http://heco:8545"]
+`)
+	path := write(t, dir, "config.yaml", `
+Env: `+base.ENV+`
+Include: "chains/*.toml"
+Chains:
+  2:
+    Include: chains/2.yaml
+    Defer: 20
+  6:
+    CheckFee: false
+  7:
+    Include: chains/heco.yml
+`)
+
+	c, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth, bsc, heco := c.Chains[2], c.Chains[6], c.Chains[7]
+	if eth == nil || bsc == nil || heco == nil {
+		t.Fatalf("Missing included chains %v", c.Chains)
+	}
+	if len(eth.Nodes) != 1 || eth.Nodes[0] != "http://eth:8545" || eth.Defer != 20 {
+		t.Fatalf("Unexpected chain 2 config %+v", eth)
+	}
+	if len(bsc.Nodes) != 1 || bsc.Nodes[0] != "http://bsc:8545" || bsc.CheckFee {
+		t.Fatalf("Unexpected chain 6 config %+v", bsc)
+	}
+	if len(heco.Nodes) != 1 || heco.ChainId != 7 {
+		t.Fatalf("Unexpected chain 7 config %+v", heco)
+	}
+
+	path = write(t, dir, "config.toml", `
+Env = "`+base.ENV+`"
+[Chains.2]
+Include = "chains/missing.yaml"
+`)
+	_, err = New(path)
+	if err == nil {
+		t.Fatal("Expect error for missing include")
+	}
+}
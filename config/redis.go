@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis deployment modes
+const (
+	REDIS_SINGLE   = "single"
+	REDIS_SENTINEL = "sentinel"
+	REDIS_CLUSTER  = "cluster"
+)
+
+type RedisConfig struct {
+	Mode             string // single(default), sentinel or cluster
+	Network          string
+	Addr             string
+	Addrs            []string // Sentinel or cluster seed addresses, Addr is used if empty
+	MasterName       string   // Sentinel master name
+	Username         string
+	Password         string
+	SentinelPassword string
+	DB               int
+	MaxRetries       int
+	PoolSize         int
+	RouteByLatency   bool // Route read only commands to the closest cluster node
+	TLS              *TLSConfig
+}
+
+type TLSConfig struct {
+	CAFile             string // Server CA, system roots if unspecified
+	CertFile           string // Client certificate
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+func (c *TLSConfig) Load() (conf *tls.Config, err error) {
+	conf = &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if c.CAFile != "" {
+		data, err := ioutil.ReadFile(GetConfigPath("", c.CAFile))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read redis tls ca file %v", err)
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("Invalid redis tls ca file %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(GetConfigPath("", c.CertFile), GetConfigPath("", c.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load redis tls client cert %v", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+// Client options of the redis deployment
+func (c *RedisConfig) Options() (o *redis.UniversalOptions, err error) {
+	if c.Mode == "" {
+		c.Mode = REDIS_SINGLE
+	}
+	o = &redis.UniversalOptions{
+		Addrs:            c.Addrs,
+		Username:         c.Username,
+		Password:         c.Password,
+		SentinelPassword: c.SentinelPassword,
+		DB:               c.DB,
+		MaxRetries:       c.MaxRetries,
+		PoolSize:         c.PoolSize,
+		RouteByLatency:   c.RouteByLatency,
+	}
+	if len(o.Addrs) == 0 && c.Addr != "" {
+		o.Addrs = []string{c.Addr}
+	}
+	switch c.Mode {
+	case REDIS_SINGLE:
+		if len(o.Addrs) > 1 {
+			return nil, fmt.Errorf("Single redis mode takes one address, got %v", o.Addrs)
+		}
+		if c.Network != "" && c.Network != "tcp" {
+			network := c.Network
+			o.Dialer = func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return new(net.Dialer).DialContext(ctx, network, addr)
+			}
+		}
+	case REDIS_SENTINEL:
+		if c.MasterName == "" {
+			return nil, fmt.Errorf("Missing master name for redis sentinel")
+		}
+		o.MasterName = c.MasterName
+	case REDIS_CLUSTER:
+		if c.DB != 0 {
+			return nil, fmt.Errorf("Redis cluster supports db 0 only, got %v", c.DB)
+		}
+	default:
+		return nil, fmt.Errorf("Unknown redis mode %s", c.Mode)
+	}
+	if c.TLS != nil {
+		o.TLSConfig, err = c.TLS.Load()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
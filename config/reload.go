@@ -0,0 +1,172 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Task changes of a config reload
+const (
+	CHANGE_START  = "start"
+	CHANGE_STOP   = "stop"
+	CHANGE_RELOAD = "reload"
+)
+
+var passphrase struct {
+	sync.Mutex
+	value []byte
+}
+
+// Passphrase of the encrypted config, read from the key file or input once and cached for reloads
+func Passphrase() ([]byte, error) {
+	passphrase.Lock()
+	defer passphrase.Unlock()
+	if passphrase.value != nil {
+		return passphrase.value, nil
+	}
+	var (
+		value []byte
+		err   error
+	)
+	if KEY_FILE != "" {
+		value, err = ioutil.ReadFile(KEY_FILE)
+		if err != nil {
+			return nil, fmt.Errorf("Read key file error %v", err)
+		}
+		value = []byte(strings.TrimSpace(string(value)))
+	} else if PLAIN {
+		value, err = msg.ReadInput("passphrase")
+	} else {
+		value, err = msg.ReadPassword("passphrase")
+	}
+	if err != nil {
+		return nil, err
+	}
+	passphrase.value = value
+	return value, nil
+}
+
+// Read the config and the roles, then initialize it as the active config
+func Load(path, roles string) (c *Config, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Load config error %v", r)
+		}
+	}()
+	c, err = New(path)
+	if err != nil {
+		return
+	}
+	if roles != "" {
+		err = c.ReadRoles(roles)
+		if err != nil {
+			return
+		}
+	}
+	err = c.Init()
+	return
+}
+
+// Change of a task between configs
+type Change struct {
+	Task
+	Action string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s", c.Action, c.Task)
+}
+
+func marshal(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// Task configs as json keyed by task, taken before handlers start to diff against reloaded configs
+func (c *Config) Snapshot() map[string]string {
+	snapshot := map[string]string{}
+	for _, t := range c.Tasks() {
+		if t.Role == ROLE_POLY_COMMIT {
+			// Fee checks of poly commit use the bridge endpoints
+			snapshot[t.String()] = marshal([]interface{}{c.TaskConfig(t), c.Bridge})
+		} else {
+			snapshot[t.String()] = marshal(c.TaskConfig(t))
+		}
+	}
+	return snapshot
+}
+
+// Tasks to start, stop or reload to apply the new snapshot
+func Diff(before, after map[string]string) (changes []Change) {
+	names := []string{}
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t, err := ParseTask(name)
+		if err != nil {
+			continue
+		}
+		a, ok := before[name]
+		b, found := after[name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{t, CHANGE_START})
+		case !found:
+			changes = append(changes, Change{t, CHANGE_STOP})
+		case a != b:
+			changes = append(changes, Change{t, CHANGE_RELOAD})
+		}
+	}
+	return
+}
+
+// Settings outside of the tasks changed by the new config
+func (c *Config) Changed(o *Config) (fields []string) {
+	if !reflect.DeepEqual(c.ValidMethods, o.ValidMethods) {
+		fields = append(fields, "ValidMethods")
+	}
+	if marshal(c.Bus) != marshal(o.Bus) {
+		fields = append(fields, "Bus")
+	}
+	if marshal(c.Shard) != marshal(o.Shard) {
+		fields = append(fields, "Shard")
+	}
+	if c.Host != o.Host || c.Port != o.Port || c.AdminToken != o.AdminToken {
+		fields = append(fields, "Http")
+	}
+	return
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := map[string]string{"2:TxListen": "a", "2:PolyCommit": "b", "3:TxCommit": "c"}
+	after := map[string]string{"2:TxListen": "a", "2:PolyCommit": "x", "6:TxCommit": "c"}
+	changes := Diff(before, after)
+	expected := []string{"reload 2:PolyCommit", "stop 3:TxCommit", "start 6:TxCommit"}
+	if len(changes) != len(expected) {
+		t.Fatalf("Unexpected changes %v", changes)
+	}
+	for i, c := range changes {
+		if c.String() != expected[i] {
+			t.Fatalf("Expect change %s, got %s", expected[i], c)
+		}
+	}
+	if len(Diff(before, before)) != 0 {
+		t.Fatal("Expect no changes")
+	}
+}
@@ -20,7 +20,6 @@ package config
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 
 	"github.com/polynetwork/bridge-common/base"
 )
@@ -36,12 +35,13 @@ type Role struct {
 type Roles map[uint64]Role
 
 func (c *Config) ReadRoles(path string) (err error) {
-	data, err := ioutil.ReadFile(path)
+	tree, err := parseFile(path, false)
 	if err != nil {
 		return fmt.Errorf("Read roles file error %v", err)
 	}
+	data, _ := json.Marshal(tree)
 	roles := Roles{}
-	err = json.Unmarshal(data, &roles)
+	err = decode(data, &roles)
 	if err != nil {
 		return fmt.Errorf("Parse roles file error %v", err)
 	}
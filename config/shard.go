@@ -0,0 +1,135 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/polynetwork/bridge-common/base"
+)
+
+// Roles of a chain, named after the fields of Role
+const (
+	ROLE_HEADER_SYNC = "HeaderSync"
+	ROLE_TX_LISTEN   = "TxListen"
+	ROLE_TX_COMMIT   = "TxCommit"
+	ROLE_POLY_LISTEN = "PolyListen"
+	ROLE_POLY_COMMIT = "PolyCommit"
+)
+
+// Dynamic assignment of roles across relayer instances
+type ShardConfig struct {
+	Enabled   bool
+	Instance  string // Instance id, hostname and pid if unspecified
+	Heartbeat uint64 // Heartbeat and assignment sync interval in seconds, default 5
+	TTL       uint64 // Instances missing heartbeats for ttl seconds are considered gone, default 20
+	Rebalance uint64 // Coordinator assignment interval in seconds, default 10
+}
+
+func (c *ShardConfig) Init() {
+	if c.Heartbeat == 0 {
+		c.Heartbeat = 5
+	}
+	if c.TTL == 0 {
+		c.TTL = 4 * c.Heartbeat
+	}
+	if c.Rebalance == 0 {
+		c.Rebalance = 2 * c.Heartbeat
+	}
+}
+
+// Role of a chain, the unit of assignments
+type Task struct {
+	Chain uint64
+	Role  string
+}
+
+func (t Task) String() string {
+	return fmt.Sprintf("%d:%s", t.Chain, t.Role)
+}
+
+func ParseTask(s string) (t Task, err error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return t, fmt.Errorf("Invalid task %s", s)
+	}
+	t.Chain, err = strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return t, fmt.Errorf("Invalid task chain %s", s)
+	}
+	t.Role = parts[1]
+	return
+}
+
+// Handler config of the task, nil if not enabled
+func (c *Config) TaskConfig(t Task) (conf interface{}) {
+	if t.Chain == base.POLY {
+		if t.Role == ROLE_POLY_LISTEN && c.Poly != nil && c.Poly.PolyTxSync != nil && c.Poly.PolyTxSync.Enabled {
+			return c.Poly.PolyTxSync
+		}
+		return
+	}
+	chain := c.Chains[t.Chain]
+	if chain == nil {
+		return
+	}
+	switch t.Role {
+	case ROLE_HEADER_SYNC:
+		if chain.HeaderSync != nil && chain.HeaderSync.Enabled {
+			return chain.HeaderSync
+		}
+	case ROLE_TX_LISTEN:
+		if chain.SrcTxSync != nil && chain.SrcTxSync.Enabled {
+			return chain.SrcTxSync
+		}
+	case ROLE_TX_COMMIT:
+		if chain.SrcTxCommit != nil && chain.SrcTxCommit.Enabled {
+			return chain.SrcTxCommit
+		}
+	case ROLE_POLY_COMMIT:
+		if chain.PolyTxCommit != nil && chain.PolyTxCommit.Enabled {
+			return chain.PolyTxCommit
+		}
+	}
+	return
+}
+
+// Enabled tasks of the active chains, sorted
+func (c *Config) Tasks() (tasks []Task) {
+	chains := []uint64{base.POLY}
+	for id := range c.Chains {
+		chains = append(chains, id)
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i] < chains[j] })
+	roles := []string{ROLE_HEADER_SYNC, ROLE_TX_LISTEN, ROLE_TX_COMMIT, ROLE_POLY_LISTEN, ROLE_POLY_COMMIT}
+	for _, chain := range chains {
+		if !c.Active(chain) {
+			continue
+		}
+		for _, role := range roles {
+			t := Task{chain, role}
+			if c.TaskConfig(t) != nil {
+				tasks = append(tasks, t)
+			}
+		}
+	}
+	return
+}
@@ -3,9 +3,12 @@ module github.com/polynetwork/poly-relayer
 go 1.15
 
 require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/alicebob/miniredis v2.5.0+incompatible
 	github.com/btcsuite/btcd v0.22.1
 	github.com/ethereum/go-ethereum v1.10.7
 	github.com/go-redis/redis/v8 v8.11.3
+	github.com/gomodule/redigo v2.0.0+incompatible // indirect
 	github.com/joeqian10/neo-gogogo v1.4.0
 	github.com/kr/pretty v0.3.0 // indirect
 	github.com/onflow/cadence v0.23.3-patch.1
@@ -15,6 +18,7 @@ require (
 	github.com/ontio/ontology v1.11.1-0.20200812075204-26cf1fa5dd47
 	github.com/ontio/ontology-crypto v1.2.1
 	github.com/ontio/ontology-go-sdk v1.11.4
+	github.com/pelletier/go-toml v1.9.4
 	github.com/polynetwork/bridge-common v0.0.39
 	github.com/polynetwork/poly v1.3.1
 	github.com/polynetwork/poly-go-sdk v0.0.0-20210114035303-84e1615f4ad4
@@ -28,10 +32,12 @@ require (
 	github.com/spf13/viper v1.10.1 // indirect
 	github.com/starcoinorg/starcoin-go v0.0.0-20220105024102-530daedc128b
 	github.com/urfave/cli/v2 v2.3.0
+	github.com/yuin/gopher-lua v0.0.0-20200816102855-ee81675732da // indirect
 	go.uber.org/goleak v1.1.11-0.20210813005559-691160354723 // indirect
 	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa
 	golang.org/x/net v0.0.0-20211123203042-d83791d6bcd9 // indirect
 	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
 	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba // indirect
 	google.golang.org/grpc v1.46.2
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
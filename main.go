@@ -33,6 +33,14 @@ func main() {
 			&cli.BoolFlag{
 				Name: "plain",
 			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "reject unknown keys in the config and roles files",
+			},
+			&cli.StringFlag{
+				Name:  "keyfile",
+				Usage: "passphrase file of the encrypted config, read from input when unspecified",
+			},
 			&cli.StringFlag{
 				Name:  "log",
 				Value: "",
@@ -188,6 +196,14 @@ func main() {
 						Name:  "pricex",
 						Usage: "tx gas priceX",
 					},
+					&cli.StringFlag{
+						Name:  "tip",
+						Usage: "dynamic fee tx max priority fee per gas",
+					},
+					&cli.StringFlag{
+						Name:  "feecap",
+						Usage: "dynamic fee tx max fee per gas",
+					},
 					&cli.BoolFlag{
 						Name:  "free",
 						Usage: "skip check fee",
@@ -232,6 +248,14 @@ func main() {
 						Name:  "pricex",
 						Usage: "tx gas priceX",
 					},
+					&cli.StringFlag{
+						Name:  "tip",
+						Usage: "dynamic fee tx max priority fee per gas",
+					},
+					&cli.StringFlag{
+						Name:  "feecap",
+						Usage: "dynamic fee tx max fee per gas",
+					},
 					&cli.StringFlag{
 						Name:  "hash",
 						Usage: "target tx hash",
@@ -240,6 +264,14 @@ func main() {
 						Name:  "free",
 						Usage: "skip check fee",
 					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "push tx even if queued within the dedup window",
+					},
+					&cli.BoolFlag{
+						Name:  "resubmit",
+						Usage: "submit tx even if a dst tx of it is pending",
+					},
 					&cli.BoolFlag{
 						Name:  "auto",
 						Usage: "auto patch",
@@ -363,6 +395,18 @@ func main() {
 					},
 				},
 			},
+			&cli.Command{
+				Name:   relayer.MIGRATE_FILE,
+				Usage:  "Re-encrypt legacy encrypted files in place with the current format",
+				Action: command(relayer.MIGRATE_FILE),
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:     "file",
+						Usage:    "file path, can be repeated",
+						Required: true,
+					},
+				},
+			},
 			&cli.Command{
 				Name:   relayer.APPROVE_SIDECHAIN,
 				Usage:  "Approve side chain",
@@ -499,6 +543,197 @@ func main() {
 					},
 				},
 			},
+			&cli.Command{
+				Name:  relayer.DLQ,
+				Usage: "Manage dead letter txs which exhausted retries",
+				Subcommands: []*cli.Command{
+					&cli.Command{
+						Name:   "list",
+						Usage:  "List dead letter txs",
+						Action: command(relayer.DLQ_LIST),
+						Flags: []cli.Flag{
+							&cli.Uint64Flag{
+								Name:  "chain",
+								Usage: "target dst chain, all chains when unspecified",
+							},
+						},
+					},
+					&cli.Command{
+						Name:   "show",
+						Usage:  "Show dead letter tx details",
+						Action: command(relayer.DLQ_SHOW),
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "hash",
+								Usage:    "poly tx hash",
+								Required: true,
+							},
+						},
+					},
+					&cli.Command{
+						Name:   "requeue",
+						Usage:  "Push dead letter tx back to the tx bus with retries reset",
+						Action: command(relayer.DLQ_REQUEUE),
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "hash",
+								Usage:    "poly tx hash",
+								Required: true,
+							},
+						},
+					},
+					&cli.Command{
+						Name:   "drop",
+						Usage:  "Remove dead letter tx permanently",
+						Action: command(relayer.DLQ_DROP),
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "hash",
+								Usage:    "poly tx hash",
+								Required: true,
+							},
+						},
+					},
+				},
+			},
+			&cli.Command{
+				Name:   relayer.CHECK_CONFIG,
+				Usage:  "Validate the config and roles files",
+				Action: command(relayer.CHECK_CONFIG),
+			},
+			&cli.Command{
+				Name:   relayer.SHARD_STATUS,
+				Usage:  "Show relayer instances and task assignments",
+				Action: command(relayer.SHARD_STATUS),
+			},
+			&cli.Command{
+				Name:  relayer.ROLE,
+				Usage: "Pause, resume and reconfigure running roles",
+				Subcommands: []*cli.Command{
+					&cli.Command{
+						Name:   "list",
+						Usage:  "List runtime role controls",
+						Action: command(relayer.ROLE_LIST),
+					},
+					&cli.Command{
+						Name:   "pause",
+						Usage:  "Pause the role till resumed",
+						Action: command(relayer.ROLE_PAUSE),
+						Flags: append(roleFlags(),
+							&cli.StringFlag{
+								Name:  "reason",
+								Usage: "reason to pause",
+							},
+						),
+					},
+					&cli.Command{
+						Name:   "resume",
+						Usage:  "Resume the paused role",
+						Action: command(relayer.ROLE_RESUME),
+						Flags:  roleFlags(),
+					},
+					&cli.Command{
+						Name:   "procs",
+						Usage:  "Override procs of the role",
+						Action: command(relayer.ROLE_PROCS),
+						Flags: append(roleFlags(),
+							&cli.IntFlag{
+								Name:     "procs",
+								Usage:    "worker count, zero to restore the config",
+								Required: true,
+							},
+						),
+					},
+					&cli.Command{
+						Name:   "filter",
+						Usage:  "Override filter of the role",
+						Action: command(relayer.ROLE_FILTER),
+						Flags: append(roleFlags(),
+							&cli.StringFlag{
+								Name:  "file",
+								Usage: "filter config json file, restore the config when unspecified",
+							},
+						),
+					},
+				},
+			},
+			&cli.Command{
+				Name:  relayer.QUEUE,
+				Usage: "Inspect and operate on tx queues",
+				Subcommands: []*cli.Command{
+					&cli.Command{
+						Name:   "list",
+						Usage:  "List queued txs",
+						Action: command(relayer.QUEUE_LIST),
+						Flags:  queueFlags("bus"),
+					},
+					&cli.Command{
+						Name:   "peek",
+						Usage:  "Show queued tx details",
+						Action: command(relayer.QUEUE_PEEK),
+						Flags:  queueFlags("bus"),
+					},
+					&cli.Command{
+						Name:   "move",
+						Usage:  "Move queued txs into another queue",
+						Action: command(relayer.QUEUE_MOVE),
+						Flags: append(queueFlags("bus"),
+							&cli.StringFlag{
+								Name:     "to",
+								Usage:    "target queue: bus, sorted, delayed or patch",
+								Required: true,
+							},
+							&cli.Uint64Flag{
+								Name:  "tochain",
+								Usage: "chain id of the target queue, same as source when unspecified",
+							},
+							&cli.Int64Flag{
+								Name:  "delay",
+								Usage: "delay seconds when moving into the delayed queue",
+							},
+						),
+					},
+					&cli.Command{
+						Name:   "purge",
+						Usage:  "Remove queued txs",
+						Action: command(relayer.QUEUE_PURGE),
+						Flags: append(queueFlags("bus"),
+							&cli.BoolFlag{
+								Name:  "all",
+								Usage: "purge all txs of the queue when no filter specified",
+							},
+						),
+					},
+					&cli.Command{
+						Name:   "requeue",
+						Usage:  "Push queued txs back to the tx buses with retries reset",
+						Action: command(relayer.QUEUE_REQUEUE),
+						Flags:  queueFlags("delayed"),
+					},
+					&cli.Command{
+						Name:   "export",
+						Usage:  "Export queued txs as json lines",
+						Action: command(relayer.QUEUE_EXPORT),
+						Flags: append(queueFlags("bus"),
+							&cli.StringFlag{
+								Name:  "file",
+								Usage: "output file, stdout when unspecified",
+							},
+						),
+					},
+					&cli.Command{
+						Name:   "import",
+						Usage:  "Import queued txs from json lines",
+						Action: command(relayer.QUEUE_IMPORT),
+						Flags: append(queueFlags("bus"),
+							&cli.StringFlag{
+								Name:  "file",
+								Usage: "input file, stdin when unspecified",
+							},
+						),
+					},
+				},
+			},
 			&cli.Command{
 				Name:   relayer.INIT_GENESIS,
 				Usage:  "Init genesis for contract",
@@ -532,31 +767,32 @@ func main() {
 }
 
 func start(c *cli.Context) error {
-	config, err := config.New(c.String("config"))
-	if err != nil {
-		log.Error("Failed to parse config file", "err", err)
-		os.Exit(2)
-	}
-	err = config.ReadRoles(c.String("roles"))
-	if err != nil {
-		log.Error("Failed to read roles configuration", "err", err)
-		os.Exit(2)
-	}
-	err = config.Init()
+	conf, err := config.Load(c.String("config"), c.String("roles"))
 	if err != nil {
-		log.Error("Failed to initialize configuration", "err", err)
+		log.Error("Failed to load configuration", "err", err)
 		os.Exit(2)
 	}
 
 	wg := &sync.WaitGroup{}
 	ctx, cancel := context.WithCancel(context.Background())
 	status := 0
-	err = relayer.Start(ctx, wg, config)
+	server, err := relayer.Start(ctx, wg, conf)
 	if err == nil {
 		sc := make(chan os.Signal, 10)
 		signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGSTOP, syscall.SIGQUIT)
-		sig := <-sc
-		log.Info("Poly relayer is exiting with received signal", "signal", sig.String())
+		for sig := range sc {
+			if sig != syscall.SIGHUP {
+				log.Info("Poly relayer is exiting with received signal", "signal", sig.String())
+				break
+			}
+			log.Info("Reloading configuration", "config", c.String("config"), "roles", c.String("roles"))
+			conf, err := config.Load(c.String("config"), c.String("roles"))
+			if err != nil {
+				log.Error("Failed to reload configuration, keeping the running one", "err", err)
+				continue
+			}
+			server.Reload(conf)
+		}
 	} else {
 		log.Error("Failed to start relayer service", "err", err)
 		status = 2
@@ -575,7 +811,7 @@ func command(method string) func(*cli.Context) error {
 			if c.String("url") != "" {
 				readConf = false
 			}
-		case relayer.ENCRYPT_FILE, relayer.DECRYPT_FILE, relayer.CREATE_ACCOUNT, relayer.UPDATE_ACCOUNT:
+		case relayer.ENCRYPT_FILE, relayer.DECRYPT_FILE, relayer.MIGRATE_FILE, relayer.CREATE_ACCOUNT, relayer.UPDATE_ACCOUNT, relayer.CHECK_CONFIG:
 			readConf = false
 		}
 		if readConf {
@@ -606,6 +842,9 @@ func command(method string) func(*cli.Context) error {
 		err := relayer.HandleCommand(method, c)
 		if err != nil {
 			log.Error("Failure", "command", method, "err", err)
+			if method == relayer.CHECK_CONFIG {
+				os.Exit(1)
+			}
 		} else {
 			log.Info("Command was executed successful!")
 		}
@@ -619,6 +858,8 @@ func Init(ctx *cli.Context) (err error) {
 	config.CONFIG_PATH = ctx.String("config")
 	config.ENCRYPTED = ctx.Bool("encrypted")
 	config.PLAIN = ctx.Bool("plain")
+	config.KEY_FILE = ctx.String("keyfile")
+	config.STRICT = ctx.Bool("strict")
 
 	log.Init(&log.LogConfig{
 		Path:     ctx.String("log"),
@@ -627,3 +868,52 @@ func Init(ctx *cli.Context) (err error) {
 
 	return
 }
+
+func queueFlags(queue string) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "queue",
+			Usage: "queue to operate on: bus, sorted, delayed or patch",
+			Value: queue,
+		},
+		&cli.Uint64Flag{
+			Name:  "chain",
+			Usage: "queue chain id, filters dst chain for the delayed queue",
+		},
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "queue tx type: poly or src",
+		},
+		&cli.StringFlag{
+			Name:  "hash",
+			Usage: "poly or src tx hash",
+		},
+		&cli.Int64Flag{
+			Name:  "age",
+			Usage: "min seconds since the tx was queued",
+		},
+		&cli.IntFlag{
+			Name:  "attempts",
+			Usage: "min failed attempts of the tx",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "max txs to operate on",
+		},
+	}
+}
+
+func roleFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.Uint64Flag{
+			Name:     "chain",
+			Usage:    "chain id of the role",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "role",
+			Usage:    "role name: HeaderSync, TxListen, TxCommit, PolyListen or PolyCommit",
+			Required: true,
+		},
+	}
+}
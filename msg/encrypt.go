@@ -18,13 +18,17 @@
 package msg
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"errors"
 	"fmt"
-	"golang.org/x/term"
 	"io"
 	"syscall"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
 )
 
 func ReadInput(name string) ([]byte, error) {
@@ -42,58 +46,99 @@ func ReadPassword(name string) ([]byte, error) {
 	return passphrase, nil
 }
 
-func Decrypt(cipherText, key []byte) []byte {
-	// Create the AES cipher
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		panic(err)
-	}
+// Encrypted data format: magic, version, scrypt salt, gcm nonce, then sealed data with the header as additional data
+var ENCRYPT_MAGIC = []byte("PRENC")
 
-	// Before even testing the decryption,
-	// if the text is too small, then it is incorrect
-	if len(cipherText) < aes.BlockSize {
-		panic("Text is too short")
-	}
+const (
+	ENCRYPT_VERSION = byte(1)
+	SALT_SIZE       = 16
+	NONCE_SIZE      = 12
+	SCRYPT_N        = 1 << 15
+	SCRYPT_R        = 8
+	SCRYPT_P        = 1
+)
 
-	// Get the 16 byte IV
-	iv := cipherText[:aes.BlockSize]
+var ErrDecrypt = errors.New("Failed to decrypt data, wrong passphrase or corrupted data")
 
-	// Remove the IV from the ciphertext
-	cipherText = cipherText[aes.BlockSize:]
+// Check if the data is in the versioned encryption format, legacy format otherwise
+func IsEncrypted(data []byte) bool {
+	return len(data) > len(ENCRYPT_MAGIC) && bytes.Equal(data[:len(ENCRYPT_MAGIC)], ENCRYPT_MAGIC)
+}
 
-	// Return a decrypted stream
-	stream := cipher.NewCFBDecrypter(block, iv)
+func sealer(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, SCRYPT_N, SCRYPT_R, SCRYPT_P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive key %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
 
-	// Decrypt bytes from ciphertext
-	stream.XORKeyStream(cipherText, cipherText)
+// Encrypt data with AES-GCM and a key derived from the passphrase with scrypt
+func Encrypt(data, passphrase []byte) ([]byte, error) {
+	header := make([]byte, len(ENCRYPT_MAGIC)+1+SALT_SIZE+NONCE_SIZE)
+	n := copy(header, ENCRYPT_MAGIC)
+	header[n] = ENCRYPT_VERSION
+	salt := header[n+1 : n+1+SALT_SIZE]
+	nonce := header[n+1+SALT_SIZE:]
+	if _, err := io.ReadFull(rand.Reader, header[n+1:]); err != nil {
+		return nil, err
+	}
+	aead, err := sealer(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(header, nonce, data, header), nil
+}
 
-	return cipherText
+// Decrypt data of the versioned format, or the legacy AES-CFB format with the raw passphrase as key
+func Decrypt(cipherText, passphrase []byte) ([]byte, error) {
+	if !IsEncrypted(cipherText) {
+		return DecryptLegacy(cipherText, passphrase)
+	}
+	n := len(ENCRYPT_MAGIC)
+	if cipherText[n] != ENCRYPT_VERSION {
+		return nil, fmt.Errorf("Unsupported encryption version %v", cipherText[n])
+	}
+	size := n + 1 + SALT_SIZE + NONCE_SIZE
+	if len(cipherText) < size {
+		return nil, fmt.Errorf("Encrypted data is too short")
+	}
+	header := cipherText[:size]
+	aead, err := sealer(passphrase, header[n+1:n+1+SALT_SIZE])
+	if err != nil {
+		return nil, err
+	}
+	data, err := aead.Open(nil, header[n+1+SALT_SIZE:], cipherText[size:], header)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return data, nil
 }
 
-func Encrypt(data, key []byte) []byte {
+// Decrypt the legacy format, no integrity check so a wrong passphrase yields garbage
+func DecryptLegacy(cipherText, key []byte) ([]byte, error) {
 	// Create the AES cipher
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("Invalid legacy passphrase %v", err)
 	}
 
-	// Empty array of 16 + plaintext length
-	// Include the IV at the beginning
-	cipherText := make([]byte, aes.BlockSize+len(data))
-
-	// Slice of first 16 bytes
-	iv := cipherText[:aes.BlockSize]
-
-	// Write 16 rand bytes to fill iv
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		panic(err)
+	// Before even testing the decryption,
+	// if the text is too small, then it is incorrect
+	if len(cipherText) < aes.BlockSize {
+		return nil, fmt.Errorf("Encrypted data is too short")
 	}
 
-	// Return an encrypted stream
-	stream := cipher.NewCFBEncrypter(block, iv)
-
-	// Encrypt bytes from plaintext to ciphertext
-	stream.XORKeyStream(cipherText[aes.BlockSize:], data)
+	// Get the 16 byte IV
+	iv := cipherText[:aes.BlockSize]
 
-	return cipherText
+	// Decrypt bytes from ciphertext
+	data := make([]byte, len(cipherText)-aes.BlockSize)
+	stream := cipher.NewCFBDecrypter(block, iv)
+	stream.XORKeyStream(data, cipherText[aes.BlockSize:])
+	return data, nil
 }
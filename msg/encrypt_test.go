@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package msg
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	data := []byte(`{"Env":"mainnet"}`)
+	cipherText, err := Encrypt(data, []byte("any length passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(cipherText) {
+		t.Fatal("Expect versioned format")
+	}
+	plain, err := Decrypt(cipherText, []byte("any length passphrase"))
+	if err != nil || !bytes.Equal(plain, data) {
+		t.Fatalf("Unexpected decrypted data %s %v", plain, err)
+	}
+	_, err = Decrypt(cipherText, []byte("wrong passphrase"))
+	if err != ErrDecrypt {
+		t.Fatalf("Expect decrypt error with wrong passphrase, got %v", err)
+	}
+	cipherText[len(cipherText)-1] ^= 1
+	_, err = Decrypt(cipherText, []byte("any length passphrase"))
+	if err != ErrDecrypt {
+		t.Fatalf("Expect decrypt error with tampered data, got %v", err)
+	}
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	data := []byte(`{"Env":"mainnet"}`)
+	block, _ := aes.NewCipher(key)
+	cipherText := make([]byte, aes.BlockSize+len(data))
+	cipher.NewCFBEncrypter(block, cipherText[:aes.BlockSize]).XORKeyStream(cipherText[aes.BlockSize:], data)
+	plain, err := Decrypt(cipherText, key)
+	if err != nil || !bytes.Equal(plain, data) {
+		t.Fatalf("Unexpected decrypted legacy data %s %v", plain, err)
+	}
+	_, err = Decrypt(cipherText[:4], key)
+	if err == nil {
+		t.Fatal("Expect error for short data")
+	}
+	_, err = Decrypt(cipherText, []byte("short"))
+	if err == nil {
+		t.Fatal("Expect error for invalid legacy passphrase")
+	}
+}
@@ -32,6 +32,10 @@ var (
 	ERR_LOW_BALANCE           = errors.New("Insufficient balance")
 	ERR_PAID_FEE_TOO_LOW      = errors.New("Paid fee too low")
 	ERR_Tx_VERIFYMERKLEPROOF  = errors.New("Tx verifyMerkleProof err")
+	ERR_FEE_MISSING           = errors.New("Tx missing in fee check")
+	ERR_FEE_NOT_PAID          = errors.New("Tx fee not paid")
+	ERR_TX_EXECUTED           = errors.New("Tx already executed")
+	ERR_TX_INVALID_HEADER     = errors.New("Tx header or signature invalid")
 
 	ERR_TX_VOILATION     = errors.New("Possible cross chain voilation")
 	ERR_TX_PROOF_MISSING = errors.New("Possible cross chain proof missing")
@@ -40,3 +44,48 @@ var (
 	ERR_TREASURY_NOT_EXIST       = errors.New("Asset not exist in lock proxy")
 	ERR_SEQUENCE_NUMBER_INVALID  = errors.New("Sequence number is invalid")
 )
+
+// Error classes used to pick retry policies
+const (
+	CLASS_DEFAULT                  = "DEFAULT"
+	CLASS_EXEC_FAILURE             = "EXEC_FAILURE"
+	CLASS_EXEC_ALWAYS_FAIL         = "EXEC_ALWAYS_FAIL"
+	CLASS_FEE_CHECK_FAILURE        = "FEE_CHECK_FAILURE"
+	CLASS_FEE_MISSING              = "FEE_MISSING"
+	CLASS_FEE_NOT_PAID             = "FEE_NOT_PAID"
+	CLASS_PAID_FEE_TOO_LOW         = "PAID_FEE_TOO_LOW"
+	CLASS_LOW_BALANCE              = "LOW_BALANCE"
+	CLASS_SEQUENCE_NUMBER_INVALID  = "SEQUENCE_NUMBER_INVALID"
+	CLASS_COIN_STORE_NOT_PUBLISHED = "COIN_STORE_NOT_PUBLISHED"
+	CLASS_TREASURY_NOT_EXIST       = "TREASURY_NOT_EXIST"
+	CLASS_EXECUTED                 = "EXECUTED"
+	CLASS_INVALID_HEADER           = "INVALID_HEADER"
+)
+
+var errorClasses = []struct {
+	err   error
+	class string
+}{
+	{ERR_TX_EXEC_FAILURE, CLASS_EXEC_FAILURE},
+	{ERR_TX_EXEC_ALWAYS_FAIL, CLASS_EXEC_ALWAYS_FAIL},
+	{ERR_FEE_CHECK_FAILURE, CLASS_FEE_CHECK_FAILURE},
+	{ERR_FEE_MISSING, CLASS_FEE_MISSING},
+	{ERR_FEE_NOT_PAID, CLASS_FEE_NOT_PAID},
+	{ERR_PAID_FEE_TOO_LOW, CLASS_PAID_FEE_TOO_LOW},
+	{ERR_LOW_BALANCE, CLASS_LOW_BALANCE},
+	{ERR_SEQUENCE_NUMBER_INVALID, CLASS_SEQUENCE_NUMBER_INVALID},
+	{ERR_COIN_STORE_NOT_PUBLISHED, CLASS_COIN_STORE_NOT_PUBLISHED},
+	{ERR_TREASURY_NOT_EXIST, CLASS_TREASURY_NOT_EXIST},
+	{ERR_TX_EXECUTED, CLASS_EXECUTED},
+	{ERR_TX_INVALID_HEADER, CLASS_INVALID_HEADER},
+}
+
+// Classify the error for retry policies
+func ErrorClass(err error) string {
+	for _, c := range errorClasses {
+		if errors.Is(err, c.err) {
+			return c.class
+		}
+	}
+	return CLASS_DEFAULT
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math/big"
 	"strings"
+	"time"
 
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -37,6 +38,12 @@ const (
 	HEADER TxType = 3
 )
 
+// Tx priorities, txs of higher priority lanes are served first
+const (
+	PRIORITY_NORMAL = 0
+	PRIORITY_HIGH   = 1
+)
+
 type Header struct {
 	Height uint64
 	Hash   []byte
@@ -49,9 +56,21 @@ type SrcComposer interface {
 	LatestHeight() (uint64, error)
 }
 
+// Failed attempt record of a tx
+type Attempt struct {
+	Time  int64
+	Error string
+}
+
+// Max attempt records kept in tx history
+const MAX_ATTEMPT_HISTORY = 10
+
 type Tx struct {
-	TxType   TxType
-	Attempts int
+	TxType       TxType
+	Attempts     int
+	FirstFailure int64      `json:",omitempty"` // Timestamp of the first failed attempt
+	Queued       int64      `json:",omitempty"` // Timestamp first pushed into a bus
+	History      []*Attempt `json:",omitempty"` // Latest failed attempts
 
 	TxId        string                `json:",omitempty"`
 	MerkleValue *common.ToMerkleValue `json:"-"`
@@ -81,17 +100,23 @@ type Tx struct {
 	DstAddress              string                `json:",omitempty"`
 	DstHash                 string                `json:",omitempty"`
 	DstHeight               uint64                `json:",omitempty"`
+	DstGasUsed              uint64                `json:",omitempty"` // Gas used by the mined dst tx
 	DstChainId              uint64                `json:",omitempty"`
 	DstGasLimit             uint64                `json:",omitempty"`
 	DstGasPrice             string                `json:",omitempty"`
 	DstGasPriceX            string                `json:",omitempty"`
+	DstGasTipCap            string                `json:",omitempty"` // Max priority fee of dynamic fee txs
+	DstGasFeeCap            string                `json:",omitempty"` // Max fee of dynamic fee txs
 	DstSender               interface{}           `json:"-"`
 	DstPolyEpochStartHeight uint32                `json:",omitempty"`
 	DstPolyKeepers          []byte                `json:"-"`
 	DstData                 []byte                `json:"-"`
 	DstProxy                string                `json:",omitempty"`
 	SkipCheckFee            bool                  `json:",omitempty"`
-	CheckFeeOff             bool                  `json:"-"` // CheckFee disabled in submitter
+	Force                   bool                  `json:",omitempty"` // Bypass bus dedup once, set by forced patch requests
+	Resubmit                bool                  `json:",omitempty"` // Submit once even with a dst tx pending, set by resubmit patch requests
+	Priority                int                   `json:",omitempty"` // Tx bus lane of the tx
+	CheckFeeOff             bool                  `json:"-"`          // CheckFee disabled in submitter
 	Skipped                 bool                  `json:",omitempty"`
 	PaidGas                 float64               `json:",omitempty"`
 	CheckFeeStatus          bridge.CheckFeeStatus `json:",omitempty"`
@@ -101,6 +126,7 @@ type Tx struct {
 	// aptos
 	ToAssetAddress string `json:",omitempty"`
 
+	Lease string      `json:"-"` // In-flight reference of a reliable bus pop, used to ack/nack
 	Extra interface{} `json:"-"`
 }
 
@@ -148,10 +174,22 @@ func (tx *Tx) CapturePatchParams(o *Tx) *Tx {
 		if len(o.DstGasPriceX) > 0 {
 			tx.DstGasPriceX = o.DstGasPriceX
 		}
+		if len(o.DstGasTipCap) > 0 {
+			tx.DstGasTipCap = o.DstGasTipCap
+		}
+		if len(o.DstGasFeeCap) > 0 {
+			tx.DstGasFeeCap = o.DstGasFeeCap
+		}
 
 		if o.SkipCheckFee {
 			tx.SkipCheckFee = o.SkipCheckFee
 		}
+		if o.Force {
+			tx.Force = o.Force
+		}
+		if o.Resubmit {
+			tx.Resubmit = o.Resubmit
+		}
 		if o.DstSender != nil {
 			tx.DstSender = o.DstSender
 		}
@@ -159,6 +197,78 @@ func (tx *Tx) CapturePatchParams(o *Tx) *Tx {
 	return tx
 }
 
+// Make tx param of the poly merkle value, or of the src event if not composed yet
+func (tx *Tx) MakeTxParam() *common.MakeTxParam {
+	if tx.MerkleValue != nil && tx.MerkleValue.MakeTxParam != nil {
+		return tx.MerkleValue.MakeTxParam
+	}
+	return tx.Param
+}
+
+// Decode dst asset and amount from the lock proxy args of the make tx param, false if unavailable
+func (tx *Tx) DecodeArgs() bool {
+	if tx.DstAmount != nil {
+		return true
+	}
+	param := tx.MakeTxParam()
+	if param == nil {
+		return false
+	}
+	source := pcom.NewZeroCopySource(param.Args)
+	asset, eof := source.NextVarBytes()
+	if eof {
+		return false
+	}
+	_, eof = source.NextVarBytes()
+	if eof {
+		return false
+	}
+	amount := new(big.Int)
+	if source.Len() >= 32 {
+		// uint256 in little endian
+		value, _ := source.NextBytes(32)
+		data := make([]byte, 32)
+		for i, b := range value {
+			data[31-i] = b
+		}
+		amount.SetBytes(data)
+	} else {
+		value, eof := source.NextUint64()
+		if eof {
+			return false
+		}
+		amount.SetUint64(value)
+	}
+	tx.DstAsset = "0x" + hex.EncodeToString(asset)
+	tx.DstAmount = amount
+	return true
+}
+
+// Record a failed attempt
+func (tx *Tx) Fail(err error) {
+	now := time.Now().Unix()
+	tx.Attempts++
+	if tx.FirstFailure == 0 {
+		tx.FirstFailure = now
+	}
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	tx.History = append(tx.History, &Attempt{Time: now, Error: reason})
+	if len(tx.History) > MAX_ATTEMPT_HISTORY {
+		tx.History = tx.History[len(tx.History)-MAX_ATTEMPT_HISTORY:]
+	}
+}
+
+// Last failure reason of the tx
+func (tx *Tx) LastError() string {
+	if len(tx.History) == 0 {
+		return ""
+	}
+	return tx.History[len(tx.History)-1].Error
+}
+
 func (tx *Tx) SkipFee() bool {
 	if tx.SkipCheckFee {
 		return true
@@ -0,0 +1,269 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package relayer
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+// Roles honoring the procs and filter controls
+var (
+	procsRoles  = map[string]bool{config.ROLE_TX_COMMIT: true}
+	filterRoles = map[string]bool{config.ROLE_TX_COMMIT: true, config.ROLE_POLY_COMMIT: true}
+)
+
+// Update the runtime control of the role, cleared once back to defaults
+func UpdateControl(ctx context.Context, t config.Task, update func(*bus.Control) error) (c *bus.Control, err error) {
+	if config.CONFIG.TaskConfig(t) == nil {
+		return nil, fmt.Errorf("Role %s of chain %d is not enabled", t.Role, t.Chain)
+	}
+	store := bus.NewControlStore(config.CONFIG.Bus)
+	c, err = store.Get(ctx, t.String())
+	if err != nil {
+		return
+	}
+	if c == nil {
+		c = new(bus.Control)
+	}
+	err = update(c)
+	if err != nil {
+		return
+	}
+	if c.Same(nil) {
+		err = store.Clear(ctx, t.String())
+	} else {
+		err = store.Set(ctx, t.String(), c)
+	}
+	if err == nil {
+		log.Info("Updated role control", "role", t.Role, "chain", t.Chain, "paused", c.Paused, "procs", c.Procs, "filter", c.Filter != nil)
+	}
+	return
+}
+
+func PauseRole(ctx context.Context, t config.Task, reason string) (*bus.Control, error) {
+	return UpdateControl(ctx, t, func(c *bus.Control) error {
+		c.Paused = true
+		c.Reason = reason
+		return nil
+	})
+}
+
+func ResumeRole(ctx context.Context, t config.Task) (*bus.Control, error) {
+	return UpdateControl(ctx, t, func(c *bus.Control) error {
+		c.Paused = false
+		c.Reason = ""
+		return nil
+	})
+}
+
+// Override procs of the role, zero to restore the config
+func SetRoleProcs(ctx context.Context, t config.Task, procs int) (*bus.Control, error) {
+	return UpdateControl(ctx, t, func(c *bus.Control) error {
+		if !procsRoles[t.Role] {
+			return fmt.Errorf("Procs control is not supported by role %s", t.Role)
+		}
+		if procs < 0 {
+			return fmt.Errorf("Invalid procs %d", procs)
+		}
+		c.Procs = procs
+		return nil
+	})
+}
+
+// Override filter of the role, nil to restore the config
+func SetRoleFilter(ctx context.Context, t config.Task, filter *config.FilterConfig) (*bus.Control, error) {
+	return UpdateControl(ctx, t, func(c *bus.Control) error {
+		if !filterRoles[t.Role] {
+			return fmt.Errorf("Filter control is not supported by role %s", t.Role)
+		}
+		if filter != nil {
+			filter.Init()
+		}
+		c.Filter = filter
+		return nil
+	})
+}
+
+// Require the bearer admin token, admin api is disabled without token configured
+func Admin(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := config.CONFIG.AdminToken
+		if token == "" {
+			http.Error(w, "admin api disabled", http.StatusForbidden)
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		f(w, r)
+	}
+}
+
+func formTask(r *http.Request) (t config.Task, err error) {
+	t.Chain, err = strconv.ParseUint(r.FormValue("chain"), 10, 64)
+	if err != nil {
+		return t, fmt.Errorf("Invalid chain %v", err)
+	}
+	t.Role = r.FormValue("role")
+	return
+}
+
+func controlHandler(update func(*http.Request, config.Task) (*bus.Control, error)) http.HandlerFunc {
+	return Admin(func(w http.ResponseWriter, r *http.Request) {
+		t, err := formTask(r)
+		if err == nil {
+			var c *bus.Control
+			c, err = update(r, t)
+			if err == nil {
+				Json(w, c)
+				return
+			}
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	})
+}
+
+// POST /api/v1/admin/pause?chain=&role=&reason=
+var PauseRoleHandler = controlHandler(func(r *http.Request, t config.Task) (*bus.Control, error) {
+	return PauseRole(r.Context(), t, r.FormValue("reason"))
+})
+
+// POST /api/v1/admin/resume?chain=&role=
+var ResumeRoleHandler = controlHandler(func(r *http.Request, t config.Task) (*bus.Control, error) {
+	return ResumeRole(r.Context(), t)
+})
+
+// POST /api/v1/admin/procs?chain=&role=&procs=, zero procs to restore the config
+var RoleProcsHandler = controlHandler(func(r *http.Request, t config.Task) (*bus.Control, error) {
+	procs, err := strconv.Atoi(r.FormValue("procs"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid procs %v", err)
+	}
+	return SetRoleProcs(r.Context(), t, procs)
+})
+
+// POST /api/v1/admin/filter?chain=&role= with filter config json as body, empty body to restore the config
+var RoleFilterHandler = controlHandler(func(r *http.Request, t config.Task) (*bus.Control, error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := parseFilter(data)
+	if err != nil {
+		return nil, err
+	}
+	return SetRoleFilter(r.Context(), t, filter)
+})
+
+// /api/v1/controls runtime controls of the roles
+func RoleControls(w http.ResponseWriter, r *http.Request) {
+	controls, err := bus.NewControlStore(config.CONFIG.Bus).List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		Json(w, controls)
+	}
+}
+
+func parseFilter(data []byte) (filter *config.FilterConfig, err error) {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return
+	}
+	filter = new(config.FilterConfig)
+	err = json.Unmarshal(data, filter)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid filter config %v", err)
+	}
+	return
+}
+
+func cliTask(ctx *cli.Context) config.Task {
+	return config.Task{Chain: ctx.Uint64("chain"), Role: ctx.String("role")}
+}
+
+func RolePause(ctx *cli.Context) (err error) {
+	_, err = PauseRole(context.Background(), cliTask(ctx), ctx.String("reason"))
+	return
+}
+
+func RoleResume(ctx *cli.Context) (err error) {
+	_, err = ResumeRole(context.Background(), cliTask(ctx))
+	return
+}
+
+func RoleProcs(ctx *cli.Context) (err error) {
+	_, err = SetRoleProcs(context.Background(), cliTask(ctx), ctx.Int("procs"))
+	return
+}
+
+func RoleFilter(ctx *cli.Context) (err error) {
+	var data []byte
+	if file := ctx.String("file"); file != "" {
+		data, err = ioutil.ReadFile(file)
+		if err != nil {
+			return
+		}
+	}
+	filter, err := parseFilter(data)
+	if err != nil {
+		return
+	}
+	_, err = SetRoleFilter(context.Background(), cliTask(ctx), filter)
+	return
+}
+
+func RoleList(ctx *cli.Context) (err error) {
+	controls, err := bus.NewControlStore(config.CONFIG.Bus).List(context.Background())
+	if err != nil {
+		return
+	}
+	names := []string{}
+	for name := range controls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("Role controls:\n")
+	for _, name := range names {
+		c := controls[name]
+		t, _ := config.ParseTask(name)
+		fmt.Printf("  %s %s paused %v procs %v filter %v reason %q\n", base.GetChainName(t.Chain), t.Role, c.Paused, c.Procs, c.Filter != nil, c.Reason)
+	}
+	return
+}
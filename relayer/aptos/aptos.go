@@ -31,17 +31,26 @@ const mainnetChainID = 1
 
 type Submitter struct {
 	context.Context
-	wg     *sync.WaitGroup
-	config *config.SubmitterConfig
-	sdk    *aptos.SDK
-	name   string
-	ccm    string
-	polyId uint64
-	wallet *wallet.AptosWallet
+	wg       *sync.WaitGroup
+	config   *config.SubmitterConfig
+	sdk      *aptos.SDK
+	name     string
+	ccm      string
+	polyId   uint64
+	wallet   *wallet.AptosWallet
+	retry    bus.RetryPolicy
+	confirms *bus.Confirmer // Submitted dst txs till final
+}
+
+// Aptos waits longer on low balance and unknown errors
+var retryRules = map[string]*config.RetryConfig{
+	msg.CLASS_DEFAULT:     {Delay: 60 * 3},
+	msg.CLASS_LOW_BALANCE: {Delay: 60 * 10},
 }
 
 func (s *Submitter) Init(config *config.SubmitterConfig) (err error) {
 	s.config = config
+	s.retry = bus.NewRetryPolicy(retryRules, config.Retry)
 	s.sdk, err = aptos.WithOptions(config.ChainId, config.Nodes, time.Minute, 1)
 	if err != nil {
 		return
@@ -75,18 +84,21 @@ func (s *Submitter) Hook(ctx context.Context, wg *sync.WaitGroup, ch <-chan msg.
 	return nil
 }
 
-func (s *Submitter) Start(ctx context.Context, wg *sync.WaitGroup, bus bus.TxBus, delay bus.DelayedTxBus, composer msg.PolyComposer) error {
+func (s *Submitter) Start(ctx context.Context, wg *sync.WaitGroup, mq bus.TxBus, delay bus.DelayedTxBus, composer msg.PolyComposer) error {
 	fmt.Printf("Submitter=%+v\n", s)
 	s.Context = ctx
 	s.wg = wg
+	s.confirms = bus.NewConfirmer(s.config.ChainId, &receipts{s.sdk}, delay, s.retry, bus.Confirmations(s.config.ChainId, s.config.Confirms))
+	go s.confirms.Start(ctx, wg)
 	log.Info("Starting submitter worker", "index", 0, "total", 1, "account", s.wallet.Address, "chain", s.name)
-	go s.run(s.wallet, bus, delay, composer)
+	go s.run(s.wallet, mq, delay, composer)
 	return nil
 }
 
 func (s *Submitter) run(wallet *wallet.AptosWallet, mq bus.TxBus, delay bus.DelayedTxBus, compose msg.PolyComposer) error {
 	s.wg.Add(1)
 	defer s.wg.Done()
+	defer bus.Recover(s.Context)
 	for {
 		select {
 		case <-s.Done():
@@ -103,6 +115,12 @@ func (s *Submitter) run(wallet *wallet.AptosWallet, mq bus.TxBus, delay bus.Dela
 			time.Sleep(time.Second)
 			continue
 		}
+		if !tx.Resubmit && s.confirms.Pending(tx.PolyHash) {
+			log.Info("Skipped poly tx with dst tx pending", "chain", s.name, "poly_hash", tx.PolyHash)
+			bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
+			continue
+		}
+		tx.Resubmit = false // Honored once, retries skip while pending
 		log.Info("Processing poly tx", "poly_hash", tx.PolyHash, "account", wallet.Address)
 		err = s.ProcessTx(tx, compose)
 		if err == nil {
@@ -113,34 +131,18 @@ func (s *Submitter) run(wallet *wallet.AptosWallet, mq bus.TxBus, delay bus.Dela
 			log.Json(log.ERROR, tx)
 			if errors.Is(err, msg.ERR_INVALID_TX) || errors.Is(err, msg.ERR_TX_BYPASS) {
 				log.Error("Skipped poly tx for error", "poly_hash", tx.PolyHash, "err", err)
+				bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
 				continue
 			}
-			tx.Attempts++
-			if errors.Is(err, msg.ERR_SEQUENCE_NUMBER_INVALID) {
-				tsp := time.Now().Unix() + 60
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-			} else if errors.Is(err, msg.ERR_LOW_BALANCE) {
-				tsp := time.Now().Unix() + 60*10
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-			} else if errors.Is(err, msg.ERR_COIN_STORE_NOT_PUBLISHED) {
-				tsp := time.Now().Unix() + 60*10
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-			} else if errors.Is(err, msg.ERR_TREASURY_NOT_EXIST) {
-				tsp := time.Now().Unix() + 60*10
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-			} else {
-				tsp := time.Now().Unix() + 60*3
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-			}
+			tx.Fail(err)
+			tsp := s.retry.Next(tx, err)
+			bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
 		} else {
 			log.Info("Submitted poly tx", "poly_hash", tx.PolyHash, "chain", s.name, "dst_hash", tx.DstHash)
 
-			// Retry to verify a successful submit
-			tsp := time.Now().Unix() + 60*3
-			if tx.DstHash != "" {
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-			}
+			s.confirms.Watch(tx)
 		}
+		bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
 	}
 }
 
@@ -405,3 +407,29 @@ func (s *Submitter) Stop() error {
 	s.wg.Wait()
 	return nil
 }
+
+// Receipts of the dst chain for the confirmer
+type receipts struct {
+	sdk *aptos.SDK
+}
+
+func (r *receipts) Receipt(hash string) (*bus.Receipt, error) {
+	tx, err := r.sdk.Node().GetTransactionByHash(context.Background(), hash)
+	if err != nil {
+		if strings.Contains(err.Error(), "not_found") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if tx.Type == "pending_transaction" {
+		return nil, nil
+	}
+	// Ledger version as height, aptos txs are final once committed
+	version, _ := strconv.ParseUint(tx.Version, 10, 64)
+	gas, _ := strconv.ParseUint(tx.GasUsed, 10, 64)
+	return &bus.Receipt{Height: version, Success: tx.Success, GasUsed: gas}, nil
+}
+
+func (r *receipts) Height() (uint64, error) {
+	return r.sdk.Node().GetLatestHeight()
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package relayer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+// Validate the config and roles files, print the report and fail on errors
+func CheckConfig(ctx *cli.Context) (err error) {
+	report := new(config.Report)
+	checkConfig(ctx.String("config"), ctx.String("roles"), report)
+	report.Print(os.Stdout)
+	if n := report.Errors(); n > 0 {
+		return fmt.Errorf("Config check failed with %d errors", n)
+	}
+	return
+}
+
+func checkConfig(path, roles string, r *config.Report) {
+	config.STRICT = true
+	var conf *config.Config
+	err := safely(func() (err error) {
+		conf, err = config.New(path)
+		return
+	})
+	if err != nil {
+		r.Error("config", "%v", err)
+		return
+	}
+	err = conf.ReadRoles(roles)
+	if err != nil {
+		r.Error("roles", "%v", err)
+		return
+	}
+	if conf.Poly == nil {
+		r.Error("Poly", "poly config missing")
+		return
+	}
+	err = safely(conf.Init)
+	if err != nil {
+		r.Error("config", "Failed to initialize %v", err)
+		return
+	}
+	conf.Check(r)
+
+	for _, t := range conf.Tasks() {
+		path := config.TaskPath(t)
+		switch t.Chain {
+		case base.OK, base.MATIC, base.HEIMDALL:
+			r.Warn(path, "role of chain %s is not supported and skipped", base.GetChainName(t.Chain))
+			continue
+		}
+		switch t.Role {
+		case config.ROLE_POLY_COMMIT:
+			if GetSubmitter(t.Chain) == nil {
+				r.Error(path, "no submitter for chain %s", base.GetChainName(t.Chain))
+			}
+		default:
+			if GetListener(t.Chain) == nil {
+				r.Error(path, "no listener for chain %s", base.GetChainName(t.Chain))
+			}
+		}
+	}
+
+	if conf.Bus != nil && conf.Bus.Backend != config.BUS_MEMORY {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = bus.New(conf.Bus).Ping(ctx).Err()
+		if err != nil {
+			r.Error("Bus.Config", "redis unreachable %v", err)
+		}
+	}
+}
+
+// Run f with panics recovered as the error
+func safely(f func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	return f()
+}
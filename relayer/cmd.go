@@ -31,7 +31,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/urfave/cli/v2"
 
 	"github.com/ethereum/go-ethereum/accounts/keystore"
@@ -62,6 +61,7 @@ const (
 	UPDATE_ACCOUNT    = "updateaccount"
 	ENCRYPT_FILE      = "encryptfile"
 	DECRYPT_FILE      = "decryptfile"
+	MIGRATE_FILE      = "migratefile"
 	CHECK_WALLET      = "wallet"
 	ADD_SIDECHAIN     = "addsidechain"
 	SYNC_GENESIS      = "syncgenesis"
@@ -76,6 +76,27 @@ const (
 	VALIDATE          = "validate"
 	VALIDATE_BLOCK    = "validateblock"
 	SET_VALIDATOR_HEIGHT = "setvalidatorblock"
+	DLQ               = "dlq"
+	DLQ_LIST          = "dlq list"
+	DLQ_SHOW          = "dlq show"
+	DLQ_REQUEUE       = "dlq requeue"
+	DLQ_DROP          = "dlq drop"
+	QUEUE             = "queue"
+	QUEUE_LIST        = "queue list"
+	QUEUE_PEEK        = "queue peek"
+	QUEUE_MOVE        = "queue move"
+	QUEUE_PURGE       = "queue purge"
+	QUEUE_REQUEUE     = "queue requeue"
+	QUEUE_EXPORT      = "queue export"
+	QUEUE_IMPORT      = "queue import"
+	SHARD_STATUS      = "shards"
+	ROLE              = "role"
+	ROLE_LIST         = "role list"
+	ROLE_PAUSE        = "role pause"
+	ROLE_RESUME       = "role resume"
+	ROLE_PROCS        = "role procs"
+	ROLE_FILTER       = "role filter"
+	CHECK_CONFIG      = "checkconfig"
 )
 
 var _Handlers = map[string]func(*cli.Context) error{}
@@ -94,6 +115,7 @@ func init() {
 	_Handlers[UPDATE_ACCOUNT] = UpdateAccount
 	_Handlers[ENCRYPT_FILE] = EncryptFile
 	_Handlers[DECRYPT_FILE] = DecryptFile
+	_Handlers[MIGRATE_FILE] = MigrateFile
 	_Handlers[ADD_SIDECHAIN] = AddSideChain
 	_Handlers[SYNC_GENESIS] = SyncGenesis
 	_Handlers[CREATE_GENESIS] = CreateGenesis
@@ -107,6 +129,24 @@ func init() {
 	_Handlers[VALIDATE] = Validate
 	_Handlers[VALIDATE_BLOCK] = ValidateBlock
 	_Handlers[SET_VALIDATOR_HEIGHT] = SetTxValidatorHeight
+	_Handlers[DLQ_LIST] = DeadLetterList
+	_Handlers[DLQ_SHOW] = DeadLetterShow
+	_Handlers[DLQ_REQUEUE] = DeadLetterRequeue
+	_Handlers[DLQ_DROP] = DeadLetterDrop
+	_Handlers[QUEUE_LIST] = QueueList
+	_Handlers[QUEUE_PEEK] = QueuePeek
+	_Handlers[QUEUE_MOVE] = QueueMove
+	_Handlers[QUEUE_PURGE] = QueuePurge
+	_Handlers[QUEUE_REQUEUE] = QueueRequeue
+	_Handlers[QUEUE_EXPORT] = QueueExport
+	_Handlers[QUEUE_IMPORT] = QueueImport
+	_Handlers[SHARD_STATUS] = ShardStatus
+	_Handlers[ROLE_LIST] = RoleList
+	_Handlers[ROLE_PAUSE] = RolePause
+	_Handlers[ROLE_RESUME] = RoleResume
+	_Handlers[ROLE_PROCS] = RoleProcs
+	_Handlers[ROLE_FILTER] = RoleFilter
+	_Handlers[CHECK_CONFIG] = CheckConfig
 }
 
 func CheckWallet(ctx *cli.Context) (err error) {
@@ -136,10 +176,12 @@ func RelayTx(ctx *cli.Context) (err error) {
 	limit := ctx.Uint64("limit")
 	price := ctx.String("price")
 	pricex := ctx.String("pricex")
+	tip := ctx.String("tip")
+	feeCap := ctx.String("feecap")
 	endpoint := ctx.String("url")
 	if endpoint != "" {
 		form := url.Values{}
-		for _, s := range []string{"height", "chain", "hash", "sender", "limit", "price", "pricex"} {
+		for _, s := range []string{"height", "chain", "hash", "sender", "limit", "price", "pricex", "tip", "feecap"} {
 			form.Set(s, ctx.String(s))
 		}
 		if free { form.Set("free", "true") }
@@ -157,15 +199,17 @@ func RelayTx(ctx *cli.Context) (err error) {
 		log.Info("Submitted request", "result", string(respBody))
 		return nil
 	}
-	_, err = relayTx(chain, height, hash, sender, free, price, pricex, limit, auto)
+	_, err = relayTx(chain, height, hash, sender, free, price, pricex, tip, feeCap, limit, auto)
 	return
 }
 
-func relayTx(chain, height uint64, hash, sender string, free bool, price, pricex string, limit uint64, auto bool) (targetTxs []*msg.Tx, err error) {
+func relayTx(chain, height uint64, hash, sender string, free bool, price, pricex, tip, feeCap string, limit uint64, auto bool) (targetTxs []*msg.Tx, err error) {
 	params := &msg.Tx{
 		SkipCheckFee: free,
 		DstGasPrice:  price,
 		DstGasPriceX: pricex,
+		DstGasTipCap: tip,
+		DstGasFeeCap: feeCap,
 		DstGasLimit:  limit,
 	}
 	if len(sender) > 0 {
@@ -277,56 +321,90 @@ func relayTx(chain, height uint64, hash, sender string, free bool, price, pricex
 }
 
 type StatusHandler struct {
-	redis *redis.Client
-	poly  *poly.SDK
-	store *bus.RedisChainStore
+	conf *config.BusConfig
+	poly *poly.SDK
 }
 
-func NewStatusHandler(opt *redis.Options) *StatusHandler {
-	client := bus.New(opt)
+func NewStatusHandler(conf *config.BusConfig) *StatusHandler {
 	sdk, err := poly.WithOptions(base.POLY, config.CONFIG.Poly.Nodes, time.Minute, 1)
 	if err != nil {
 		log.Error("Failed to initialize poly sdk")
 		panic(err)
 	}
 
-	return &StatusHandler{redis: client, poly: sdk, store: bus.NewRedisChainStore(
-		bus.ChainHeightKey{}, client, 0,
-	)}
+	return &StatusHandler{conf: conf, poly: sdk}
 }
 
 func (h *StatusHandler) Skip(hash string) (err error) {
-	return bus.NewRedisSkipCheck(h.redis).Skip(context.Background(), &msg.Tx{PolyHash: hash})
+	return bus.NewSkipCheck(h.conf).Skip(context.Background(), &msg.Tx{PolyHash: hash})
 }
 
 func (h *StatusHandler) CheckSkip(hash string) (skip bool, err error) {
-	return bus.NewRedisSkipCheck(h.redis).CheckSkip(context.Background(), &msg.Tx{PolyHash: hash})
+	return bus.NewSkipCheck(h.conf).CheckSkip(context.Background(), &msg.Tx{PolyHash: hash})
 }
 
 func (h *StatusHandler) Height(chain uint64, key bus.ChainHeightType) (uint64, error) {
-	h.store.Key = bus.ChainHeightKey{ChainId: chain, Type: key}
-	return h.store.GetHeight(context.Background())
+	return bus.NewChainStore(bus.ChainHeightKey{ChainId: chain, Type: key}, h.conf, 0).GetHeight(context.Background())
 }
 
 func (h *StatusHandler) SetHeight(chain uint64, key bus.ChainHeightType, height uint64) (err error) {
-	h.store.Key = bus.ChainHeightKey{ChainId: chain, Type: key}
-	return h.store.UpdateHeight(context.Background(), height)
+	return bus.NewChainStore(bus.ChainHeightKey{ChainId: chain, Type: key}, h.conf, 0).UpdateHeight(context.Background(), height)
 }
 
 func (h *StatusHandler) Len(chain uint64, ty msg.TxType) (uint64, error) {
-	return bus.NewRedisTxBus(h.redis, chain, ty).Len(context.Background())
+	return bus.NewTxBus(h.conf, chain, ty).Len(context.Background())
 }
 
 func (h *StatusHandler) LenDelayed() (uint64, error) {
-	return bus.NewRedisDelayedTxBus(h.redis).Len(context.Background())
+	return bus.NewDelayedTxBus(h.conf).Len(context.Background())
+}
+
+// Delayed queue sizes keyed by dst chain, zero for the legacy global queue
+func (h *StatusHandler) LenDelayedChains() (sizes map[uint64]uint64, err error) {
+	queue := bus.NewDelayedTxBus(h.conf)
+	chains, err := queue.Chains(context.Background())
+	if err != nil {
+		return
+	}
+	sizes = map[uint64]uint64{}
+	for _, chain := range append(chains, 0) {
+		sizes[chain], err = queue.LenOf(context.Background(), chain)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
 }
 
 func (h *StatusHandler) LenSorted(chain uint64, ty msg.TxType) (uint64, error) {
-	return bus.NewRedisSortedTxBus(h.redis, chain, ty).Len(context.Background())
+	return bus.NewSortedTxBus(h.conf, chain, ty).Len(context.Background())
+}
+
+// Pending txs per consumer of stream based buses, nil if not supported
+func (h *StatusHandler) Pending(chain uint64, ty msg.TxType) (map[string]int, error) {
+	var mq interface{}
+	if ty == msg.SRC {
+		mq = bus.NewSortedTxBus(h.conf, chain, ty)
+	} else {
+		mq = bus.NewTxBus(h.conf, chain, ty)
+	}
+	inspector, ok := mq.(bus.PendingInspector)
+	if !ok {
+		return nil, nil
+	}
+	list, err := inspector.Pending(context.Background(), 1000)
+	if err != nil {
+		return nil, err
+	}
+	consumers := map[string]int{}
+	for _, p := range list {
+		consumers[p.Consumer]++
+	}
+	return consumers, nil
 }
 
 func Status(ctx *cli.Context) (err error) {
-	h := NewStatusHandler(config.CONFIG.Bus.Redis)
+	h := NewStatusHandler(config.CONFIG.Bus)
 	targetChain := ctx.Uint64("chain")
 	for _, chain := range base.CHAINS {
 		if targetChain != 0 && targetChain != chain {
@@ -366,39 +444,56 @@ func Status(ctx *cli.Context) (err error) {
 		qPoly, _ := h.Len(chain, msg.POLY)
 		fmt.Printf("  src tx queue size : %v\n", qSrc)
 		fmt.Printf("  poly tx queue size: %v\n", qPoly)
+		pSrc, _ := h.Pending(chain, msg.SRC)
+		for consumer, count := range pSrc {
+			fmt.Printf("  src tx pending on %s: %v\n", consumer, count)
+		}
+		pPoly, _ := h.Pending(chain, msg.POLY)
+		for consumer, count := range pPoly {
+			fmt.Printf("  poly tx pending on %s: %v\n", consumer, count)
+		}
 	}
 	qDelayed, _ := h.LenDelayed()
 	fmt.Printf("Status shared:\n")
 	fmt.Printf("  delayed tx queue size: %v\n", qDelayed)
+	sizes, _ := h.LenDelayedChains()
+	for _, chain := range base.CHAINS {
+		if size, ok := sizes[chain]; ok && (targetChain == 0 || targetChain == chain) {
+			fmt.Printf("  delayed tx queue size of %s: %v\n", base.GetChainName(chain), size)
+		}
+	}
+	if sizes[0] > 0 {
+		fmt.Printf("  legacy delayed tx queue size: %v\n", sizes[0])
+	}
 	return nil
 }
 
 func SetHeaderSyncHeight(ctx *cli.Context) (err error) {
 	height := uint64(ctx.Int("height"))
 	chain := uint64(ctx.Int("chain"))
-	return NewStatusHandler(config.CONFIG.Bus.Redis).SetHeight(chain, bus.KEY_HEIGHT_HEADER_RESET, height)
+	return NewStatusHandler(config.CONFIG.Bus).SetHeight(chain, bus.KEY_HEIGHT_HEADER_RESET, height)
 }
 
 func SetTxSyncHeight(ctx *cli.Context) (err error) {
 	height := uint64(ctx.Int("height"))
 	chain := uint64(ctx.Int("chain"))
-	return NewStatusHandler(config.CONFIG.Bus.Redis).SetHeight(chain, bus.KEY_HEIGHT_TX, height)
+	return NewStatusHandler(config.CONFIG.Bus).SetHeight(chain, bus.KEY_HEIGHT_TX, height)
 }
 
 func SetTxValidatorHeight(ctx *cli.Context) (err error) {
 	height := uint64(ctx.Int("height"))
 	chain := uint64(ctx.Int("chain"))
-	return NewStatusHandler(config.CONFIG.Bus.Redis).SetHeight(chain, bus.KEY_HEIGHT_VALIDATOR, height)
+	return NewStatusHandler(config.CONFIG.Bus).SetHeight(chain, bus.KEY_HEIGHT_VALIDATOR, height)
 }
 
 func Skip(ctx *cli.Context) (err error) {
 	hash := ctx.String("hash")
-	return NewStatusHandler(config.CONFIG.Bus.Redis).Skip(hash)
+	return NewStatusHandler(config.CONFIG.Bus).Skip(hash)
 }
 
 func CheckSkip(ctx *cli.Context) (err error) {
 	hash := ctx.String("hash")
-	skip, err := NewStatusHandler(config.CONFIG.Bus.Redis).CheckSkip(hash)
+	skip, err := NewStatusHandler(config.CONFIG.Bus).CheckSkip(hash)
 	if skip {
 		log.Info("Hash was marked to skip", "hash", hash)
 	}
@@ -61,8 +61,10 @@ func (c *Controller) SubmitTx(w http.ResponseWriter, r *http.Request) {
 	sender := r.FormValue("sender")
 	price := r.FormValue("price")
 	pricex := r.FormValue("pricex")
+	tip := r.FormValue("tip")
+	feeCap := r.FormValue("feecap")
 	free := r.FormValue("free") == "true"
-	txs, err := relayTx(uint64(chain), uint64(height), hash, sender, free, price, pricex, uint64(limit), false)
+	txs, err := relayTx(uint64(chain), uint64(height), hash, sender, free, price, pricex, tip, feeCap, uint64(limit), false)
 	log.Info("Submit executed", "err", err)
 	resp := map[string]interface{}{}
 	resp["err"] = err
@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package relayer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+// Move a dead letter back to the tx bus of the dst chain with retry state reset
+func RequeueDeadLetter(ctx context.Context, conf *config.BusConfig, hash string) (letter *bus.DeadLetter, err error) {
+	dlq := bus.NewDeadLetterBus(conf)
+	letter, err = dlq.Get(ctx, hash)
+	if err != nil {
+		return
+	}
+	if letter == nil {
+		return nil, fmt.Errorf("Dead letter not found for %s", hash)
+	}
+	tx := letter.Tx
+	tx.Attempts = 0
+	tx.FirstFailure = 0
+	err = bus.NewTxBus(conf, tx.DstChainId, tx.Type()).PushToChain(ctx, tx)
+	if err != nil {
+		return
+	}
+	_, err = dlq.Remove(ctx, letter.Id())
+	return
+}
+
+// Remove a dead letter permanently
+func DropDeadLetter(ctx context.Context, conf *config.BusConfig, hash string) (letter *bus.DeadLetter, err error) {
+	dlq := bus.NewDeadLetterBus(conf)
+	letter, err = dlq.Get(ctx, hash)
+	if err != nil {
+		return
+	}
+	if letter == nil {
+		return nil, fmt.Errorf("Dead letter not found for %s", hash)
+	}
+	_, err = dlq.Remove(ctx, letter.Id())
+	return
+}
+
+func DeadLetterList(ctx *cli.Context) (err error) {
+	list, err := bus.NewDeadLetterBus(config.CONFIG.Bus).List(context.Background(), ctx.Uint64("chain"))
+	if err != nil {
+		return
+	}
+	fmt.Printf("Dead letters: %v\n", len(list))
+	for _, d := range list {
+		fmt.Printf("  %s chain %s attempts %v since %s: %s\n",
+			d.Id(), base.GetChainName(d.Tx.DstChainId), d.Tx.Attempts,
+			time.Unix(d.Created, 0).Format(time.RFC3339), d.Reason,
+		)
+	}
+	return
+}
+
+func DeadLetterShow(ctx *cli.Context) (err error) {
+	hash := ctx.String("hash")
+	letter, err := bus.NewDeadLetterBus(config.CONFIG.Bus).Get(context.Background(), hash)
+	if err != nil {
+		return
+	}
+	if letter == nil {
+		return fmt.Errorf("Dead letter not found for %s", hash)
+	}
+	log.Json(log.INFO, letter)
+	return
+}
+
+func DeadLetterRequeue(ctx *cli.Context) (err error) {
+	letter, err := RequeueDeadLetter(context.Background(), config.CONFIG.Bus, ctx.String("hash"))
+	if err == nil {
+		log.Info("Requeued dead letter", "hash", letter.Id(), "chain", letter.Tx.DstChainId)
+	}
+	return
+}
+
+func DeadLetterDrop(ctx *cli.Context) (err error) {
+	letter, err := DropDeadLetter(context.Background(), config.CONFIG.Bus, ctx.String("hash"))
+	if err == nil {
+		log.Info("Dropped dead letter", "hash", letter.Id(), "chain", letter.Tx.DstChainId)
+	}
+	return
+}
+
+// /api/v1/dlq?chain=&hash=, list dead letters or show one with hash specified
+func DeadLetters(w http.ResponseWriter, r *http.Request) {
+	dlq := bus.NewDeadLetterBus(config.CONFIG.Bus)
+	hash := r.FormValue("hash")
+	if hash != "" {
+		letter, err := dlq.Get(context.Background(), hash)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else if letter == nil {
+			http.Error(w, "dead letter not found", http.StatusNotFound)
+		} else {
+			Json(w, letter)
+		}
+		return
+	}
+	chain, _ := strconv.Atoi(r.FormValue("chain"))
+	list, err := dlq.List(context.Background(), uint64(chain))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		Json(w, list)
+	}
+}
+
+// POST /api/v1/dlq/requeue?hash=, admin token required
+func RequeueDeadLetterTx(w http.ResponseWriter, r *http.Request) {
+	letter, err := RequeueDeadLetter(context.Background(), config.CONFIG.Bus, r.FormValue("hash"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		log.Info("Requeued dead letter", "hash", letter.Id(), "chain", letter.Tx.DstChainId)
+		Json(w, letter)
+	}
+}
+
+// POST /api/v1/dlq/drop?hash=, admin token required
+func DropDeadLetterTx(w http.ResponseWriter, r *http.Request) {
+	letter, err := DropDeadLetter(context.Background(), config.CONFIG.Bus, r.FormValue("hash"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		log.Info("Dropped dead letter", "hash", letter.Id(), "chain", letter.Tx.DstChainId)
+		Json(w, letter)
+	}
+}
@@ -18,29 +18,99 @@
 package relayer
 
 import (
+	"fmt"
 	"io/ioutil"
+	"os"
+	"unicode/utf8"
+
+	"github.com/polynetwork/bridge-common/log"
 	"github.com/urfave/cli/v2"
+
+	"github.com/polynetwork/poly-relayer/config"
 	"github.com/polynetwork/poly-relayer/msg"
 )
 
+// Write the file readable by owner only, mode of existing file is restricted too
+func writeSecret(file string, data []byte) error {
+	err := ioutil.WriteFile(file, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(file, 0600)
+}
+
 func EncryptFile(ctx *cli.Context) (err error) {
 	file := ctx.String("file")
 	data, err := ioutil.ReadFile(file)
-	if err != nil { return }
-	pass, err := msg.ReadPassword("passphrase")
-	if err != nil { return }
-	cipherData := msg.Encrypt(data, []byte(pass))
-	err = ioutil.WriteFile(file + ".encrypted", cipherData, 0644)
+	if err != nil {
+		return
+	}
+	pass, err := config.Passphrase()
+	if err != nil {
+		return
+	}
+	cipherData, err := msg.Encrypt(data, pass)
+	if err != nil {
+		return
+	}
+	err = ioutil.WriteFile(file+".encrypted", cipherData, 0644)
 	return
 }
 
 func DecryptFile(ctx *cli.Context) (err error) {
 	file := ctx.String("file")
 	cipherData, err := ioutil.ReadFile(file)
-	if err != nil { return }
-	pass, err := msg.ReadPassword("passphrase")
-	if err != nil { return }
-	data := msg.Decrypt(cipherData, []byte(pass))
-	err = ioutil.WriteFile(file + ".decrypted", data, 0644)
+	if err != nil {
+		return
+	}
+	pass, err := config.Passphrase()
+	if err != nil {
+		return
+	}
+	data, err := msg.Decrypt(cipherData, pass)
+	if err != nil {
+		return
+	}
+	err = writeSecret(file+".decrypted", data)
 	return
-}
\ No newline at end of file
+}
+
+// Re-encrypt legacy encrypted files in place with the versioned format, originals are kept with .legacy suffix
+func MigrateFile(ctx *cli.Context) (err error) {
+	pass, err := config.Passphrase()
+	if err != nil {
+		return
+	}
+	for _, file := range ctx.StringSlice("file") {
+		cipherData, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if msg.IsEncrypted(cipherData) {
+			log.Info("File is encrypted in the latest format already", "file", file)
+			continue
+		}
+		data, err := msg.DecryptLegacy(cipherData, pass)
+		if err != nil {
+			return fmt.Errorf("Failed to decrypt %s %v", file, err)
+		}
+		// Legacy format has no integrity check, config files are text at least
+		if !utf8.Valid(data) {
+			return fmt.Errorf("Decrypted %s is not text, wrong passphrase?", file)
+		}
+		newData, err := msg.Encrypt(data, pass)
+		if err != nil {
+			return err
+		}
+		err = writeSecret(file+".legacy", cipherData)
+		if err != nil {
+			return err
+		}
+		err = writeSecret(file, newData)
+		if err != nil {
+			return err
+		}
+		log.Info("Migrated encrypted file", "file", file, "backup", file+".legacy")
+	}
+	return
+}
@@ -29,14 +29,19 @@ import (
 
 type Submitter struct {
 	context.Context
-	wg     *sync.WaitGroup
-	config *config.SubmitterConfig
-	sdk    *eth.SDK
-	name   string
-	ccd    common.Address
-	ccm    common.Address
-	abi    abi.ABI
-	wallet wallet.IWallet
+	wg       *sync.WaitGroup
+	config   *config.SubmitterConfig
+	sdk      *eth.SDK
+	name     string
+	ccd      common.Address
+	ccm      common.Address
+	abi      abi.ABI
+	wallet   wallet.IWallet
+	signer   *wallet.Wallet // Account providers and nonces to compose txs
+	retry    bus.RetryPolicy
+	txs      *Tracker                         // Pending dst txs
+	confirms *bus.Confirmer                   // Mined dst txs till final
+	nonces   map[common.Address]*NonceManager // Local nonces of the accounts
 	// eccd   *eccd_abi.EthCrossChainData
 }
 
@@ -56,6 +61,7 @@ func (s *Submitter) Init(config *config.SubmitterConfig) (err error) {
 		if err != nil {
 			return err
 		}
+		s.signer = w
 		if s.config.ChainId == base.ETH {
 			s.wallet = w.Upgrade()
 		} else {
@@ -63,6 +69,7 @@ func (s *Submitter) Init(config *config.SubmitterConfig) (err error) {
 		}
 	}
 	s.name = base.GetChainName(config.ChainId)
+	s.retry = bus.NewRetryPolicy(config.Retry)
 	s.ccd = common.HexToAddress(config.CCDContract)
 	s.ccm = common.HexToAddress(config.CCMContract)
 	s.abi, err = abi.JSON(strings.NewReader(eccm_abi.EthCrossChainManagerABI))
@@ -73,31 +80,11 @@ func (s *Submitter) Submit(msg msg.Message) error {
 	return nil
 }
 
-func (s *Submitter) submit(tx *msg.Tx) error {
+func (s *Submitter) submit(tx *msg.Tx) (err error) {
 	if len(tx.DstData) == 0 {
 		return nil
 	}
-	var (
-		gasPrice  *big.Int
-		gasPriceX *big.Float
-		ok        bool
-	)
-	if tx.DstGasPrice != "" {
-		gasPrice, ok = new(big.Int).SetString(tx.DstGasPrice, 10)
-		if !ok {
-			return fmt.Errorf("%s submit invalid gas price %s", tx.DstGasPrice)
-		}
-	}
-	if tx.DstGasPriceX != "" {
-		gasPriceX, ok = new(big.Float).SetString(tx.DstGasPriceX)
-		if !ok {
-			return fmt.Errorf("%s submit invalid gas priceX %s", tx.DstGasPriceX)
-		}
-	}
-	var (
-		err     error
-		account accounts.Account
-	)
+	var account accounts.Account
 	if tx.DstSender != nil {
 		acc := tx.DstSender.(*accounts.Account)
 		account = *acc
@@ -105,13 +92,20 @@ func (s *Submitter) submit(tx *msg.Tx) error {
 		account, _, _ = s.wallet.Select()
 	}
 
-	if tx.CheckFeeOff || tx.CheckFeeStatus != bridge.PAID_LIMIT {
-		tx.DstHash, err = s.wallet.SendWithAccount(account, s.ccm, big.NewInt(0), tx.DstGasLimit, gasPrice, gasPriceX, tx.DstData)
-	} else {
-		maxLimit, _ := big.NewFloat(tx.PaidGas).Int(nil)
-		tx.DstHash, err = s.wallet.SendWithMaxLimit(s.sdk.ChainID, account, s.ccm, big.NewInt(0), maxLimit, gasPrice, gasPriceX, tx.DstData)
+	err = s.simulate(account, tx)
+	if err != nil {
+		return
 	}
-	return err
+
+	var maxLimit *big.Int
+	if !tx.CheckFeeOff && tx.CheckFeeStatus == bridge.PAID_LIMIT {
+		maxLimit, _ = big.NewFloat(tx.PaidGas).Int(nil)
+		if maxLimit.Sign() <= 0 {
+			return fmt.Errorf("max limit is zero or missing")
+		}
+	}
+	tx.DstHash, err = s.send(account, tx, maxLimit)
+	return
 }
 
 func (s *Submitter) Send(addr common.Address, amount *big.Int, gasLimit uint64, gasPrice *big.Int, gasPriceX *big.Float, data []byte) (hash string, err error) {
@@ -181,11 +175,11 @@ func (s *Submitter) processPolyTx(tx *msg.Tx) (err error) {
 
 func (s *Submitter) ProcessTx(m *msg.Tx, compose msg.PolyComposer) (err error) {
 	if m.Type() != msg.POLY {
-		return fmt.Errorf("%s desired message is not poly tx %v", m.Type())
+		return fmt.Errorf("%s desired message is not poly tx %v", s.name, m.Type())
 	}
 
 	if m.DstChainId != s.config.ChainId {
-		return fmt.Errorf("%s message dst chain does not match %v", m.DstChainId)
+		return fmt.Errorf("%s message dst chain does not match %v", s.name, m.DstChainId)
 	}
 	m.DstPolyEpochStartHeight, err = s.GetPolyEpochStartHeight()
 	if err != nil {
@@ -214,7 +208,7 @@ func (s *Submitter) SubmitTx(tx *msg.Tx) (err error) {
 		}
 	}
 	err = s.submit(tx)
-	if err != nil {
+	if err != nil && msg.ErrorClass(err) == msg.CLASS_DEFAULT {
 		info := err.Error()
 		if strings.Contains(info, "business contract failed") {
 			err = fmt.Errorf("%w tx exec error %v", msg.ERR_TX_EXEC_FAILURE, err)
@@ -240,6 +234,7 @@ func (s *Submitter) Process(m msg.Message, compose msg.PolyComposer) (err error)
 func (s *Submitter) run(account accounts.Account, mq bus.TxBus, delay bus.DelayedTxBus, compose msg.PolyComposer) error {
 	s.wg.Add(1)
 	defer s.wg.Done()
+	defer bus.Recover(s.Context)
 	for {
 		select {
 		case <-s.Done():
@@ -257,6 +252,12 @@ func (s *Submitter) run(account accounts.Account, mq bus.TxBus, delay bus.Delaye
 			time.Sleep(time.Second)
 			continue
 		}
+		if !tx.Resubmit && (s.txs.Pending(tx.PolyHash) || s.confirms.Pending(tx.PolyHash)) {
+			log.Info("Skipped poly tx with dst tx pending", "chain", s.name, "poly_hash", tx.PolyHash)
+			bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
+			continue
+		}
+		tx.Resubmit = false // Honored once, retries skip while pending
 		log.Info("Processing poly tx", "poly_hash", tx.PolyHash, "account", account.Address)
 		tx.DstSender = &account
 		err = s.ProcessTx(tx, compose)
@@ -268,48 +269,31 @@ func (s *Submitter) run(account accounts.Account, mq bus.TxBus, delay bus.Delaye
 			log.Json(log.ERROR, tx)
 			if errors.Is(err, msg.ERR_INVALID_TX) || errors.Is(err, msg.ERR_TX_BYPASS) {
 				log.Error("Skipped poly tx for error", "poly_hash", tx.PolyHash, "err", err)
+				bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
 				continue
 			}
-			tx.Attempts++
-			// TODO: retry with increased gas price?
-			if errors.Is(err, msg.ERR_TX_EXEC_FAILURE) || errors.Is(err, msg.ERR_TX_EXEC_ALWAYS_FAIL) {
-				tsp := time.Now().Unix() + 60*3
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-			} else if errors.Is(err, msg.ERR_FEE_CHECK_FAILURE) {
-				tsp := time.Now().Unix() + 10
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-			} else if errors.Is(err, msg.ERR_PAID_FEE_TOO_LOW) {
-				tsp := time.Now().Unix() + 60*10
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-			} else {
-				tsp := time.Now().Unix() + 1
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-				if errors.Is(err, msg.ERR_LOW_BALANCE) {
-					log.Info("Low wallet balance detected", "chain", s.name, "account", account.Address)
-					s.WaitForBalance(account.Address)
-				}
+			if errors.Is(err, msg.ERR_TX_EXECUTED) {
+				log.Info("Skipped poly tx already executed", "chain", s.name, "poly_hash", tx.PolyHash)
+				bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
+				continue
 			}
+			tx.Fail(err)
+			// TODO: retry with increased gas price?
+			tsp := s.retry.Next(tx, err)
+			bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
 		} else {
 			log.Info("Submitted poly tx", "poly_hash", tx.PolyHash, "chain", s.name, "dst_hash", tx.DstHash)
 
-			// Retry to verify a successful submit
-			tsp := int64(0)
-			switch s.config.ChainId {
-			case base.MATIC, base.PLT:
-				tsp = time.Now().Unix() + 60*3
-			case base.ARBITRUM, base.OPTIMISM:
-				tsp = time.Now().Unix() + 60*25
-			case base.BSC, base.HECO, base.OK, base.KCC, base.BYTOM, base.HSC, base.MILKO:
-				tsp = time.Now().Unix() + 60*4
-			case base.ETH:
-				tsp = time.Now().Unix() + 60*6
-			default:
-				tsp = time.Now().Unix() + 60*3
-			}
-			if tsp > 0 && tx.DstHash != "" {
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
+			// Track till mined, the tracker and the confirmer feed failed ones back to the delayed queue
+			if tx.DstHash != "" {
+				s.txs.Track(account, tx)
 			}
 		}
+		bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
+		if errors.Is(err, msg.ERR_LOW_BALANCE) {
+			log.Info("Low wallet balance detected", "chain", s.name, "account", account.Address)
+			s.WaitForBalance(account.Address)
+		}
 	}
 }
 
@@ -329,16 +313,27 @@ func (s *Submitter) WaitForBalance(address common.Address) {
 	}
 }
 
-func (s *Submitter) Start(ctx context.Context, wg *sync.WaitGroup, bus bus.TxBus, delay bus.DelayedTxBus, compose msg.PolyComposer) error {
+func (s *Submitter) Start(ctx context.Context, wg *sync.WaitGroup, mq bus.TxBus, delay bus.DelayedTxBus, compose msg.PolyComposer) error {
 	s.Context = ctx
 	s.wg = wg
+	s.txs = NewTracker(s, delay)
+	s.confirms = bus.NewConfirmer(s.config.ChainId, &receipts{s.sdk}, delay, s.retry, bus.Confirmations(s.config.ChainId, s.config.Confirms))
 	accounts := s.wallet.Accounts()
+	if s.config.Bus != nil {
+		store := bus.NewNonceStore(s.config.Bus)
+		s.nonces = map[common.Address]*NonceManager{}
+		for _, a := range accounts {
+			s.nonces[a.Address] = NewNonceManager(s.config.ChainId, a.Address, s.sdk, store)
+		}
+	}
+	go s.txs.Start(ctx, wg)
+	go s.confirms.Start(ctx, wg)
 	if len(accounts) == 0 {
 		log.Warn("No account available for submitter workers", "chain", s.name)
 	}
 	for i, a := range accounts {
 		log.Info("Starting submitter worker", "index", i, "total", len(accounts), "account", a.Address, "chain", s.name)
-		go s.run(a, bus, delay, compose)
+		go s.run(a, mq, delay, compose)
 	}
 	return nil
 }
@@ -0,0 +1,154 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Result of eth_feeHistory
+type feeHistory struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas"` // Includes the next block
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+	Reward       [][]*hexutil.Big `json:"reward"`
+}
+
+// Dynamic fees of a tx
+type dynamicFee struct {
+	BaseFee *big.Int // Next block base fee
+	Tip     *big.Int
+	FeeCap  *big.Int
+}
+
+// Effective gas price if included in the next block
+func (f *dynamicFee) Price() *big.Int {
+	price := new(big.Int).Add(f.BaseFee, f.Tip)
+	if price.Cmp(f.FeeCap) > 0 {
+		return new(big.Int).Set(f.FeeCap)
+	}
+	return price
+}
+
+func minBig(a, b *big.Int) *big.Int {
+	if b != nil && a.Cmp(b) > 0 {
+		return new(big.Int).Set(b)
+	}
+	return a
+}
+
+func mulBig(a *big.Int, x float64) *big.Int {
+	v, _ := new(big.Float).Mul(new(big.Float).SetInt(a), big.NewFloat(x)).Int(nil)
+	return v
+}
+
+// Derive the fees from the fee history: median reward of the percentile as tip, base fee of the next block with multiplier plus the tip as fee cap
+func feeFromHistory(h *feeHistory, c *config.DynamicFeeConfig) (fee *dynamicFee, err error) {
+	if len(h.BaseFee) == 0 {
+		return nil, fmt.Errorf("Empty fee history")
+	}
+	rewards := []*big.Int{}
+	for _, r := range h.Reward {
+		if len(r) > 0 && r[0] != nil {
+			rewards = append(rewards, r[0].ToInt())
+		}
+	}
+	tip := new(big.Int)
+	if len(rewards) > 0 {
+		sort.Slice(rewards, func(i, j int) bool { return rewards[i].Cmp(rewards[j]) < 0 })
+		tip.Set(rewards[len(rewards)/2])
+	}
+	x := c.BaseFeeX
+	if x <= 0 {
+		x = 2
+	}
+	fee = &dynamicFee{BaseFee: h.BaseFee[len(h.BaseFee)-1].ToInt(), Tip: tip}
+	fee.FeeCap = new(big.Int).Add(mulBig(fee.BaseFee, x), tip)
+	return
+}
+
+// Apply the tx patch params and the config caps
+func (f *dynamicFee) apply(tx *msg.Tx, c *config.DynamicFeeConfig) (err error) {
+	if tx.DstGasPriceX != "" {
+		x, ok := new(big.Float).SetString(tx.DstGasPriceX)
+		if !ok {
+			return fmt.Errorf("Invalid gas priceX %s", tx.DstGasPriceX)
+		}
+		v, _ := x.Float64()
+		f.Tip, f.FeeCap = mulBig(f.Tip, v), mulBig(f.FeeCap, v)
+	}
+	if c.MinTip != nil && c.MaxFee != nil && c.MinTip.Cmp(c.MaxFee) > 0 {
+		return fmt.Errorf("Min tip %s exceeds max fee %s", c.MinTip, c.MaxFee)
+	}
+	if c.MinTip != nil && f.Tip.Cmp(c.MinTip) < 0 {
+		f.Tip = new(big.Int).Set(c.MinTip)
+	}
+	// Tip is capped by max fee too, so the fee cap raised to the tip stays within max fee
+	f.Tip = minBig(minBig(f.Tip, c.MaxTip), c.MaxFee)
+	f.FeeCap = minBig(f.FeeCap, c.MaxFee)
+	// Explicit patch params bypass the caps
+	if tx.DstGasTipCap != "" {
+		tip, ok := new(big.Int).SetString(tx.DstGasTipCap, 10)
+		if !ok {
+			return fmt.Errorf("Invalid gas tip cap %s", tx.DstGasTipCap)
+		}
+		f.Tip = tip
+	}
+	if tx.DstGasFeeCap != "" {
+		feeCap, ok := new(big.Int).SetString(tx.DstGasFeeCap, 10)
+		if !ok {
+			return fmt.Errorf("Invalid gas fee cap %s", tx.DstGasFeeCap)
+		}
+		f.FeeCap = feeCap
+	}
+	if f.FeeCap.Cmp(f.Tip) < 0 {
+		f.FeeCap = new(big.Int).Set(f.Tip)
+	}
+	return
+}
+
+// Fees of the next block for the tx
+func (s *Submitter) DynamicFee(tx *msg.Tx) (fee *dynamicFee, err error) {
+	c := s.config.DynamicFee
+	blocks, percentile := c.Blocks, c.TipPercentile
+	if blocks <= 0 {
+		blocks = 10
+	}
+	if percentile <= 0 {
+		percentile = 50
+	}
+	h := new(feeHistory)
+	err = s.sdk.Node().Rpc.CallContext(context.Background(), h, "eth_feeHistory", hexutil.Uint(blocks), "latest", []float64{percentile})
+	if err != nil {
+		return nil, fmt.Errorf("Get fee history error %v", err)
+	}
+	fee, err = feeFromHistory(h, c)
+	if err != nil {
+		return
+	}
+	err = fee.apply(tx, c)
+	return
+}
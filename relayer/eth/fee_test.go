@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func hexBig(v int64) *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(v))
+}
+
+func TestDynamicFee(t *testing.T) {
+	h := &feeHistory{
+		BaseFee: []*hexutil.Big{hexBig(90), hexBig(95), hexBig(100)},
+		Reward:  [][]*hexutil.Big{{hexBig(3)}, {hexBig(1)}, {hexBig(2)}},
+	}
+	c := &config.DynamicFeeConfig{MaxFee: big.NewInt(150)}
+	fee, err := feeFromHistory(h, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee.BaseFee.Int64() != 100 || fee.Tip.Int64() != 2 || fee.FeeCap.Int64() != 202 {
+		t.Fatalf("Unexpected fee %v %v %v", fee.BaseFee, fee.Tip, fee.FeeCap)
+	}
+	err = fee.apply(&msg.Tx{DstGasPriceX: "1.5"}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee.Tip.Int64() != 3 || fee.FeeCap.Int64() != 150 || fee.Price().Int64() != 103 {
+		t.Fatalf("Unexpected capped fee %v %v", fee.Tip, fee.FeeCap)
+	}
+	err = fee.apply(&msg.Tx{DstGasTipCap: "200"}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee.Tip.Int64() != 200 || fee.FeeCap.Int64() != 200 {
+		t.Fatalf("Expect patched tip to raise the fee cap, got %v %v", fee.Tip, fee.FeeCap)
+	}
+
+	fee = &dynamicFee{BaseFee: big.NewInt(100), Tip: big.NewInt(2), FeeCap: big.NewInt(202)}
+	c = &config.DynamicFeeConfig{MinTip: big.NewInt(180), MaxFee: big.NewInt(150)}
+	if fee.apply(new(msg.Tx), c) == nil {
+		t.Fatal("Expect min tip above max fee rejected")
+	}
+	c = &config.DynamicFeeConfig{MinTip: big.NewInt(120), MaxTip: big.NewInt(300), MaxFee: big.NewInt(150)}
+	err = fee.apply(&msg.Tx{DstGasPriceX: "0.5"}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee.Tip.Int64() != 120 || fee.FeeCap.Int64() != 120 {
+		t.Fatalf("Expect fee cap raised to min tip, got %v %v", fee.Tip, fee.FeeCap)
+	}
+	fee = &dynamicFee{BaseFee: big.NewInt(100), Tip: big.NewInt(200), FeeCap: big.NewInt(400)}
+	err = fee.apply(new(msg.Tx), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee.Tip.Int64() != 150 || fee.FeeCap.Int64() != 150 {
+		t.Fatalf("Expect tip and fee cap within max fee, got %v %v", fee.Tip, fee.FeeCap)
+	}
+}
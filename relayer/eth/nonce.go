@@ -0,0 +1,213 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/polynetwork/bridge-common/chains/eth"
+	"github.com/polynetwork/bridge-common/log"
+
+	"github.com/polynetwork/poly-relayer/bus"
+)
+
+const (
+	NONCE_GAP_TIMEOUT = 2 * time.Minute  // Node pending nonce behind the local one for the duration is a gap
+	NONCE_GAP_FILLS   = 16               // Max nonces filled per check
+	NONCE_LOCK_TTL    = time.Minute      // Account lock lease, covers a send
+	NONCE_LOCK_WAIT   = 30 * time.Second // Max wait for the account lock held by other instances
+)
+
+// Pending nonces on chain
+type nonceSource interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// Pending nonces from the selected node of the sdk
+type sdkNonces struct {
+	sdk *eth.SDK
+}
+
+func (s sdkNonces) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return s.sdk.Node().PendingNonceAt(ctx, account)
+}
+
+// Local nonce counter of an account, reconciled with the pending nonce on chain and persisted in the store.
+// Nonces are acquired under the account lock of the store, as instances can share the account.
+type NonceManager struct {
+	sync.Mutex
+	chain   uint64
+	address common.Address
+	node    nonceSource
+	store   bus.NonceStore
+	next    uint64
+	loaded  bool
+	gap     time.Time // First seen the pending nonce behind
+}
+
+func NewNonceManager(chain uint64, address common.Address, sdk *eth.SDK, store bus.NonceStore) *NonceManager {
+	return &NonceManager{chain: chain, address: address, node: sdkNonces{sdk}, store: store}
+}
+
+func (m *NonceManager) pending() (uint64, error) {
+	nonce, err := m.node.PendingNonceAt(context.Background(), m.address)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to fetch pending nonce of %s %v", m.address, err)
+	}
+	return nonce, nil
+}
+
+func (m *NonceManager) save() {
+	err := m.store.Set(context.Background(), m.chain, m.address.String(), m.next)
+	if err != nil {
+		log.Error("Failed to save account nonce", "chain", m.chain, "account", m.address, "nonce", m.next, "err", err)
+	}
+}
+
+// Take the account lock in the store, waiting at most the duration
+func (m *NonceManager) lock(wait time.Duration) (ok bool, err error) {
+	deadline := time.Now().Add(wait)
+	for {
+		ok, err = m.store.Lock(context.Background(), m.chain, m.address.String(), NONCE_LOCK_TTL)
+		if err != nil || ok || !time.Now().Before(deadline) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (m *NonceManager) unlock() {
+	err := m.store.Unlock(context.Background(), m.chain, m.address.String())
+	if err != nil {
+		log.Error("Failed to unlock account nonce", "chain", m.chain, "account", m.address, "err", err)
+	}
+}
+
+// Load the saved nonce under the account lock, the pending nonce on chain takes precedence if ahead
+func (m *NonceManager) load() (err error) {
+	saved, err := m.store.Get(context.Background(), m.chain, m.address.String())
+	if err != nil {
+		return
+	}
+	pending, err := m.pending()
+	if err != nil {
+		return
+	}
+	if !m.loaded {
+		log.Info("Loaded account nonce", "chain", m.chain, "account", m.address, "saved", saved, "pending", pending)
+	}
+	m.next, m.loaded = saved, true
+	if pending > saved {
+		m.next = pending
+	}
+	return
+}
+
+// Next nonce to use, the account is locked till Update
+func (m *NonceManager) Acquire() (uint64, error) {
+	m.Lock()
+	ok, err := m.lock(NONCE_LOCK_WAIT)
+	if err == nil && !ok {
+		err = fmt.Errorf("Account %s nonce is locked by another instance", m.address)
+	}
+	if err == nil {
+		err = m.load()
+		if err != nil {
+			m.unlock()
+		}
+	}
+	if err != nil {
+		m.Unlock()
+		return 0, err
+	}
+	return m.next, nil
+}
+
+// Update after a send with the acquired nonce and unlock the account, the nonce is used if success
+func (m *NonceManager) Update(success bool) {
+	defer m.Unlock()
+	defer m.unlock()
+	if success {
+		m.next++
+		m.save()
+	}
+}
+
+// Reset the nonce to the pending nonce on chain, like after nonce too low errors. Called between Acquire and Update.
+func (m *NonceManager) Resync() {
+	pending, err := m.pending()
+	if err != nil {
+		log.Error("Failed to resync account nonce", "chain", m.chain, "account", m.address, "err", err)
+		return
+	}
+	log.Warn("Resynced account nonce", "chain", m.chain, "account", m.address, "local", m.next, "pending", pending)
+	m.next, m.gap = pending, time.Time{}
+	m.save()
+}
+
+// Reconcile with the pending nonce on chain, returns the gap nonces to fill when the pending nonce stays behind.
+func (m *NonceManager) Check() (gaps []uint64, err error) {
+	pending, err := m.pending()
+	if err != nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	if !m.loaded {
+		return
+	}
+	// Skip the check while the account is in use by other instances
+	ok, err := m.lock(0)
+	if err != nil || !ok {
+		return
+	}
+	defer m.unlock()
+	saved, err := m.store.Get(context.Background(), m.chain, m.address.String())
+	if err != nil {
+		return
+	}
+	if saved > m.next {
+		m.next = saved
+	}
+	if pending >= m.next {
+		if pending > m.next {
+			log.Warn("Account nonce behind the chain", "chain", m.chain, "account", m.address, "local", m.next, "pending", pending)
+			m.next = pending
+			m.save()
+		}
+		m.gap = time.Time{}
+		return
+	}
+	// Lagging nodes can be behind for a while
+	if m.gap.IsZero() {
+		m.gap = time.Now()
+	}
+	if time.Since(m.gap) < NONCE_GAP_TIMEOUT {
+		return
+	}
+	m.gap = time.Time{}
+	for n := pending; n < m.next && len(gaps) < NONCE_GAP_FILLS; n++ {
+		gaps = append(gaps, n)
+	}
+	log.Warn("Detected account nonce gaps", "chain", m.chain, "account", m.address, "local", m.next, "pending", pending, "gaps", gaps)
+	return
+}
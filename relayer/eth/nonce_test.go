@@ -0,0 +1,157 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package eth
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/polynetwork/poly-relayer/bus"
+)
+
+type fakeNode struct {
+	pending uint64
+}
+
+func (n *fakeNode) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return n.pending, nil
+}
+
+var testAccount = common.HexToAddress("0x01")
+
+func testNonces(pending, saved uint64) (*NonceManager, *fakeNode, bus.NonceStore) {
+	node := &fakeNode{pending: pending}
+	store := bus.NewMemoryNonceStore(bus.NewMemoryDB())
+	store.Set(context.Background(), 2, testAccount.String(), saved)
+	return &NonceManager{chain: 2, address: testAccount, node: node, store: store}, node, store
+}
+
+func TestNonceManagerAcquire(t *testing.T) {
+	cases := []struct {
+		saved    uint64
+		pending  uint64
+		success  bool
+		acquired uint64
+		next     uint64 // Saved after update
+	}{
+		{0, 0, true, 0, 1},
+		{5, 3, true, 5, 6},
+		{3, 7, true, 7, 8},
+		{5, 3, false, 5, 5},
+		{3, 7, false, 7, 3},
+	}
+	for i, c := range cases {
+		m, _, store := testNonces(c.pending, c.saved)
+		nonce, err := m.Acquire()
+		if err != nil || nonce != c.acquired {
+			t.Fatalf("Case %v expect acquired %v, got %v %v", i, c.acquired, nonce, err)
+		}
+		m.Update(c.success)
+		saved, _ := store.Get(context.Background(), 2, testAccount.String())
+		if saved != c.next {
+			t.Fatalf("Case %v expect saved %v, got %v", i, c.next, saved)
+		}
+		// Unlocked by the update
+		expect := c.next
+		if c.pending > expect {
+			expect = c.pending
+		}
+		nonce, err = m.Acquire()
+		if err != nil || nonce != expect {
+			t.Fatalf("Case %v expect reacquired %v, got %v %v", i, expect, nonce, err)
+		}
+		m.Update(false)
+	}
+}
+
+func TestNonceManagerResync(t *testing.T) {
+	m, node, store := testNonces(4, 10)
+	nonce, err := m.Acquire()
+	if err != nil || nonce != 10 {
+		t.Fatalf("Expect acquired saved nonce 10, got %v %v", nonce, err)
+	}
+	// Nonce too low, the chain moved on with txs sent elsewhere
+	node.pending = 12
+	m.Resync()
+	m.Update(false)
+	saved, _ := store.Get(context.Background(), 2, testAccount.String())
+	if saved != 12 {
+		t.Fatalf("Expect resynced nonce saved, got %v", saved)
+	}
+	nonce, err = m.Acquire()
+	if err != nil || nonce != 12 {
+		t.Fatalf("Expect acquired resynced nonce 12, got %v %v", nonce, err)
+	}
+	m.Update(true)
+}
+
+func TestNonceManagerCheck(t *testing.T) {
+	span := func(from, to uint64) (gaps []uint64) {
+		for n := from; n < to; n++ {
+			gaps = append(gaps, n)
+		}
+		return
+	}
+	cases := []struct {
+		name    string
+		saved   uint64
+		next    uint64
+		pending uint64
+		behind  time.Duration // Since the pending nonce first seen behind, zero for never
+		locked  bool          // By another instance
+		gaps    []uint64
+		expect  uint64 // Next nonce after the check
+	}{
+		{"in sync", 5, 5, 5, time.Minute, false, nil, 5},
+		{"chain ahead", 5, 5, 8, time.Minute, false, nil, 8},
+		{"first behind", 10, 10, 8, 0, false, nil, 10},
+		{"behind in timeout", 10, 10, 8, NONCE_GAP_TIMEOUT / 2, false, nil, 10},
+		{"behind past timeout", 10, 10, 8, NONCE_GAP_TIMEOUT, false, []uint64{8, 9}, 10},
+		{"saved by other instances", 12, 10, 8, NONCE_GAP_TIMEOUT, false, span(8, 12), 12},
+		{"capped fills", 100, 100, 0, NONCE_GAP_TIMEOUT, false, span(0, NONCE_GAP_FILLS), 100},
+		{"locked", 10, 10, 8, NONCE_GAP_TIMEOUT, true, nil, 10},
+	}
+	for _, c := range cases {
+		m, _, store := testNonces(c.pending, c.saved)
+		m.next, m.loaded = c.next, true
+		if c.behind > 0 {
+			m.gap = time.Now().Add(-c.behind)
+		}
+		if c.locked {
+			store.Lock(context.Background(), 2, testAccount.String(), time.Minute)
+		}
+		gaps, err := m.Check()
+		if err != nil {
+			t.Fatalf("Case %s check failed %v", c.name, err)
+		}
+		if !reflect.DeepEqual(gaps, c.gaps) || m.next != c.expect {
+			t.Fatalf("Case %s expect gaps %v next %v, got %v %v", c.name, c.gaps, c.expect, gaps, m.next)
+		}
+	}
+
+	// Not checked before the first acquire
+	m, _, _ := testNonces(0, 10)
+	m.gap = time.Now().Add(-NONCE_GAP_TIMEOUT)
+	if gaps, _ := m.Check(); len(gaps) != 0 {
+		t.Fatalf("Expect no gaps before loaded, got %v", gaps)
+	}
+}
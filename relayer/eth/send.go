@@ -0,0 +1,250 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/bridge-common/wallet"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Gas price of legacy txs, the patched price takes precedence
+func (s *Submitter) gasPrice(tx *msg.Tx) (price *big.Int, err error) {
+	if tx.DstGasPrice != "" {
+		price, ok := new(big.Int).SetString(tx.DstGasPrice, 10)
+		if !ok {
+			return nil, fmt.Errorf("%s submit invalid gas price %s", s.name, tx.DstGasPrice)
+		}
+		return price, nil
+	}
+	price, err = s.signer.GasPrice()
+	if err != nil {
+		return nil, fmt.Errorf("Get gas price error %v", err)
+	}
+	if tx.DstGasPriceX != "" {
+		x, ok := new(big.Float).SetString(tx.DstGasPriceX)
+		if !ok {
+			return nil, fmt.Errorf("%s submit invalid gas priceX %s", s.name, tx.DstGasPriceX)
+		}
+		price, _ = new(big.Float).Mul(new(big.Float).SetInt(price), x).Int(nil)
+	}
+	return
+}
+
+// Fees of the london wallet: the patched price or suggested tip as tip, 3 times the suggested gas price as fee cap
+func (s *Submitter) londonFee(tx *msg.Tx) (fee *dynamicFee, err error) {
+	fee = &dynamicFee{BaseFee: new(big.Int)}
+	if tx.DstGasPrice != "" {
+		fee.Tip, err = s.gasPrice(tx)
+	} else {
+		fee.Tip, err = s.signer.GasTip()
+		if err == nil && tx.DstGasPriceX != "" {
+			x, ok := new(big.Float).SetString(tx.DstGasPriceX)
+			if !ok {
+				return nil, fmt.Errorf("%s submit invalid gas priceX %s", s.name, tx.DstGasPriceX)
+			}
+			fee.Tip, _ = new(big.Float).Mul(new(big.Float).SetInt(fee.Tip), x).Int(nil)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Get gas tip error %v", err)
+	}
+	price, err := s.signer.GasPrice()
+	if err != nil {
+		return nil, fmt.Errorf("Get gas price error %v", err)
+	}
+	fee.FeeCap = new(big.Int).Mul(price, big.NewInt(3))
+	return
+}
+
+// Fees of the tx, nil dynamic fee for legacy txs
+func (s *Submitter) fees(tx *msg.Tx, limited bool) (fee *dynamicFee, price *big.Int, err error) {
+	switch {
+	case s.config.DynamicFee != nil:
+		fee, err = s.DynamicFee(tx)
+	case s.config.ChainId == base.ETH && !limited:
+		fee, err = s.londonFee(tx)
+	default:
+		price, err = s.gasPrice(tx)
+	}
+	return
+}
+
+// Nonce provider of the account, the local nonce manager if started
+func (s *Submitter) nonce(account accounts.Account) wallet.NonceProvider {
+	if m, ok := s.nonces[account.Address]; ok {
+		return m
+	}
+	_, nonces := s.signer.GetAccount(account)
+	return nonces
+}
+
+func (s *Submitter) sign(account accounts.Account, data types.TxData) (*types.Transaction, error) {
+	provider, _ := s.signer.GetAccount(account)
+	if provider == nil {
+		return nil, fmt.Errorf("Missing provider of account %s", account.Address)
+	}
+	signed, err := provider.SignTx(account, types.NewTx(data), big.NewInt(int64(s.config.ChainId)))
+	if err != nil {
+		return nil, fmt.Errorf("Sign tx error %v", err)
+	}
+	return signed, nil
+}
+
+// Compose and send the dst tx with the account nonce, gas cost limited by max limit if specified
+func (s *Submitter) send(account accounts.Account, tx *msg.Tx, maxLimit *big.Int) (hash string, err error) {
+	fee, price, err := s.fees(tx, maxLimit != nil)
+	if err != nil {
+		return
+	}
+	nonces := s.nonce(account)
+	nonce, err := nonces.Acquire()
+	if err != nil {
+		return
+	}
+	gasLimit := tx.DstGasLimit
+	if gasLimit == 0 || maxLimit != nil {
+		call := ethereum.CallMsg{From: account.Address, To: &s.ccm, Value: big.NewInt(0), Data: tx.DstData}
+		if fee != nil {
+			call.GasFeeCap, call.GasTipCap = fee.FeeCap, fee.Tip
+		}
+		gasLimit, err = s.sdk.Node().EstimateGas(context.Background(), call)
+		if err != nil {
+			nonces.Update(false)
+			if strings.Contains(err.Error(), "has been executed") {
+				log.Info("Transaction already executed")
+				return "", nil
+			}
+			return "", fmt.Errorf("Estimate gas limit error %v, account %s", err, account.Address)
+		}
+	}
+	x := float32(1.3)
+	if maxLimit != nil {
+		if fee != nil {
+			price = fee.Price()
+		}
+		if maxLimit.Cmp(new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), price)) < 0 {
+			nonces.Update(false)
+			return "", fmt.Errorf("Send tx estimated gas (limit %v, price %v) higher than max limit %v", gasLimit, price, maxLimit)
+		}
+		if s.config.ChainId == base.OPTIMISM || s.config.ChainId == base.BSC {
+			x = 2
+		}
+	}
+	gasLimit = uint64(x * float32(gasLimit))
+	limit := wallet.GetChainGasLimit(s.config.ChainId, gasLimit)
+	if limit < gasLimit {
+		nonces.Update(false)
+		return "", fmt.Errorf("Send tx estimated gas limit(%v) higher than max %v", gasLimit, limit)
+	}
+
+	var data types.TxData
+	if fee != nil {
+		data = &types.DynamicFeeTx{
+			ChainID: big.NewInt(int64(s.config.ChainId)), Nonce: nonce, GasTipCap: fee.Tip, GasFeeCap: fee.FeeCap,
+			Gas: limit, To: &s.ccm, Value: big.NewInt(0), Data: tx.DstData,
+		}
+	} else {
+		data = &types.LegacyTx{Nonce: nonce, GasPrice: price, Gas: limit, To: &s.ccm, Value: big.NewInt(0), Data: tx.DstData}
+	}
+	signed, err := s.sign(account, data)
+	if err != nil {
+		nonces.Update(false)
+		return
+	}
+	log.Info("Compose dst chain tx", "chain", s.name, "hash", signed.Hash(), "account", account.Address, "nonce", nonce,
+		"limit", limit, "gasPrice", signed.GasPrice(), "tip", signed.GasTipCap())
+	err = s.sdk.Node().SendTransaction(context.Background(), signed)
+	if err != nil {
+		info := err.Error()
+		switch {
+		case strings.Contains(info, "already known"):
+			nonces.Update(true)
+			return signed.Hash().String(), nil
+		case strings.Contains(info, "nonce too low") || strings.Contains(info, "replacement transaction underpriced"):
+			// Nonce taken already, the local nonce is behind
+			if m, ok := nonces.(*NonceManager); ok {
+				m.Resync()
+			}
+			nonces.Update(false)
+		default:
+			nonces.Update(false)
+		}
+		return
+	}
+	nonces.Update(true)
+	return signed.Hash().String(), nil
+}
+
+// Fill the nonce gap with the tracked tx of the nonce, or a self transfer.
+// Only txs tracked by this process are known, so gaps of nonces taken by other instances sharing the account
+// are filled with self transfers, which replace their txs only if priced higher, and those are processed again.
+func (s *Submitter) fill(account accounts.Account, nonce uint64) (err error) {
+	signed := s.txs.Raw(account.Address, nonce)
+	if signed == nil {
+		fee, price, err := s.fees(new(msg.Tx), false)
+		if err != nil {
+			return err
+		}
+		var data types.TxData
+		if fee != nil {
+			data = &types.DynamicFeeTx{
+				ChainID: big.NewInt(int64(s.config.ChainId)), Nonce: nonce, GasTipCap: fee.Tip, GasFeeCap: fee.FeeCap,
+				Gas: 21000, To: &account.Address, Value: big.NewInt(0),
+			}
+		} else {
+			data = &types.LegacyTx{Nonce: nonce, GasPrice: price, Gas: 21000, To: &account.Address, Value: big.NewInt(0)}
+		}
+		signed, err = s.sign(account, data)
+		if err != nil {
+			return err
+		}
+	}
+	err = s.sdk.Node().SendTransaction(context.Background(), signed)
+	log.Info("Filled account nonce gap", "chain", s.name, "account", account.Address, "nonce", nonce, "hash", signed.Hash(),
+		"self_transfer", *signed.To() == account.Address, "err", err)
+	return
+}
+
+// Reconcile the local nonces with the chain and fill the gaps
+func (s *Submitter) checkNonces() {
+	for _, account := range s.wallet.Accounts() {
+		m, ok := s.nonces[account.Address]
+		if !ok {
+			continue
+		}
+		gaps, err := m.Check()
+		if err != nil {
+			log.Warn("Failed to check account nonce", "chain", s.name, "account", account.Address, "err", err)
+			continue
+		}
+		for _, nonce := range gaps {
+			s.fill(account, nonce)
+		}
+	}
+}
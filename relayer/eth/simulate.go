@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Revert reasons of the cross chain manager, matched in lower case
+var revertErrors = []struct {
+	reason string
+	err    error
+}{
+	{"has been executed", msg.ERR_TX_EXECUTED},
+	{"signature failed", msg.ERR_TX_INVALID_HEADER},
+	{"merkleprove", msg.ERR_TX_INVALID_HEADER},
+	{"not aiming at this network", msg.ERR_INVALID_TX},
+	{"business contract failed", msg.ERR_TX_EXEC_FAILURE},
+	{"execute crosschain tx failed", msg.ERR_TX_EXEC_FAILURE},
+}
+
+// Revert reason of the call error, false if the call was not reverted
+func revertReason(err error) (string, bool) {
+	if e, ok := err.(rpc.DataError); ok {
+		if data, ok := e.ErrorData().(string); ok {
+			reason, err := abi.UnpackRevert(common.FromHex(data))
+			if err == nil {
+				return reason, true
+			}
+		}
+	}
+	info := err.Error()
+	if strings.Contains(info, "revert") || strings.Contains(info, "always failing") {
+		return strings.TrimPrefix(info, "execution reverted: "), true
+	}
+	return "", false
+}
+
+// Error of the revert reason, unknown reasons are taken as always failing
+func revertError(reason string) error {
+	info := strings.ToLower(reason)
+	for _, r := range revertErrors {
+		if strings.Contains(info, r.reason) {
+			return fmt.Errorf("%w tx simulation reverted: %s", r.err, reason)
+		}
+	}
+	return fmt.Errorf("%w tx simulation reverted: %s", msg.ERR_TX_EXEC_ALWAYS_FAIL, reason)
+}
+
+// Run the dst tx with eth_call to catch reverts before paying gas
+func (s *Submitter) simulate(account accounts.Account, tx *msg.Tx) error {
+	_, err := s.sdk.Node().CallContract(context.Background(), ethereum.CallMsg{From: account.Address, To: &s.ccm, Data: tx.DstData}, nil)
+	if err == nil {
+		return nil
+	}
+	reason, ok := revertReason(err)
+	if !ok {
+		return fmt.Errorf("%s simulate dst tx error %v", s.name, err)
+	}
+	return revertError(reason)
+}
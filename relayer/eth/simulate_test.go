@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package eth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+type dataError struct {
+	data string
+}
+
+func (e dataError) Error() string          { return "execution reverted" }
+func (e dataError) ErrorData() interface{} { return e.data }
+
+func TestRevertError(t *testing.T) {
+	// Error(string) of "EthCrossChain call business contract failed"
+	data := "0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000002b" +
+		"45746843726f7373436861696e2063616c6c20627573696e65737320636f6e74" +
+		"72616374206661696c6564000000000000000000000000000000000000000000"
+	cases := []struct {
+		err    error
+		reason string
+		expect error
+	}{
+		{dataError{data}, "EthCrossChain call business contract failed", msg.ERR_TX_EXEC_FAILURE},
+		{errors.New("execution reverted: the transaction has been executed!"), "the transaction has been executed!", msg.ERR_TX_EXECUTED},
+		{errors.New("execution reverted: Verify poly chain header signature failed!"), "Verify poly chain header signature failed!", msg.ERR_TX_INVALID_HEADER},
+		{errors.New("execution reverted: This Tx is not aiming at this network!"), "This Tx is not aiming at this network!", msg.ERR_INVALID_TX},
+		{errors.New("gas required exceeds allowance or always failing transaction"), "gas required exceeds allowance or always failing transaction", msg.ERR_TX_EXEC_ALWAYS_FAIL},
+	}
+	for _, c := range cases {
+		reason, ok := revertReason(c.err)
+		if !ok || reason != c.reason {
+			t.Fatalf("Unexpected revert reason %q %v of %v", reason, ok, c.err)
+		}
+		if err := revertError(reason); !errors.Is(err, c.expect) {
+			t.Fatalf("Expect %v, got %v", c.expect, err)
+		}
+	}
+	if _, ok := revertReason(errors.New("connection refused")); ok {
+		t.Fatal("Expect no revert for network errors")
+	}
+}
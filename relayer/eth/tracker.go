@@ -0,0 +1,313 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/chains/eth"
+	"github.com/polynetwork/bridge-common/log"
+
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+const TRACK_INTERVAL = 15 * time.Second
+
+// Seconds pending before a dst tx is considered stuck
+func stuckDeadline(chainId uint64) int64 {
+	switch chainId {
+	case base.ETH:
+		return 60 * 3
+	case base.BSC, base.HECO, base.OK, base.KCC, base.BYTOM, base.HSC, base.MILKO:
+		return 60
+	}
+	return 60 * 2
+}
+
+// Bump the price by the ratio, at least by the minimum replacement ratio
+func bumpPrice(price *big.Int, ratio, min float64) *big.Int {
+	if ratio < min {
+		ratio = min
+	}
+	inc := mulBig(price, ratio)
+	return inc.Add(inc, price).Add(inc, big.NewInt(1))
+}
+
+// Dst tx sent and not mined yet
+type pendingTx struct {
+	tx      *msg.Tx
+	account accounts.Account
+	raw     *types.Transaction // Latest broadcast
+	hashes  []common.Hash      // All the broadcasts, any of them can be mined
+	ceiling *big.Int
+	sent    time.Time
+	bumps   int
+	missing bool // Nonce taken with no receipt found in last check
+	stuck   bool // Pending beyond the bump limits, held till the nonce is taken
+}
+
+// Tracker watches the sent dst txs till mined, stuck txs are sent again with the same nonce and bumped gas price.
+// Txs stuck beyond the limits are held till the nonce is taken, as sending them with another nonce can relay them twice.
+type Tracker struct {
+	sync.Mutex
+	s     *Submitter
+	txs   map[string]*pendingTx // Keyed by poly hash
+	delay bus.DelayedTxBus
+	conf  config.GasBumpConfig
+}
+
+func NewTracker(s *Submitter, delay bus.DelayedTxBus) *Tracker {
+	t := &Tracker{s: s, txs: map[string]*pendingTx{}, delay: delay}
+	if s.config.GasBump != nil {
+		t.conf = *s.config.GasBump
+	}
+	if t.conf.Deadline <= 0 {
+		t.conf.Deadline = stuckDeadline(s.config.ChainId)
+	}
+	if t.conf.MaxBumps == 0 {
+		t.conf.MaxBumps = 5
+	}
+	return t
+}
+
+// Track the sent dst tx of the account
+func (t *Tracker) Track(account accounts.Account, tx *msg.Tx) {
+	c := *tx
+	t.Lock()
+	defer t.Unlock()
+	t.txs[tx.PolyHash] = &pendingTx{
+		tx: &c, account: account, sent: time.Now(), hashes: []common.Hash{common.HexToHash(tx.DstHash)},
+	}
+}
+
+// Check if a dst tx of the poly tx is pending
+func (t *Tracker) Pending(hash string) bool {
+	t.Lock()
+	defer t.Unlock()
+	_, ok := t.txs[hash]
+	return ok
+}
+
+// Latest broadcast of the tracked tx with the nonce
+func (t *Tracker) Raw(address common.Address, nonce uint64) *types.Transaction {
+	if t == nil {
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+	for _, p := range t.txs {
+		if p.account.Address == address && p.raw != nil && p.raw.Nonce() == nonce {
+			return p.raw
+		}
+	}
+	return nil
+}
+
+func (t *Tracker) list() (txs []*pendingTx) {
+	t.Lock()
+	defer t.Unlock()
+	for _, p := range t.txs {
+		txs = append(txs, p)
+	}
+	return
+}
+
+func (t *Tracker) remove(p *pendingTx) {
+	t.Lock()
+	delete(t.txs, p.tx.PolyHash)
+	t.Unlock()
+}
+
+func (t *Tracker) finish(p *pendingTx, outcome string) {
+	t.remove(p)
+	bus.Outcome(p.tx, outcome)
+	// Process again, txs relayed already are skipped
+	p.tx.Fail(fmt.Errorf("Dst tx %s %s", p.tx.DstHash, outcome))
+	p.tx.DstHash = ""
+	bus.SafeCall(t.s.Context, p.tx, "push to delay queue", func() error {
+		return t.delay.Delay(context.Background(), p.tx, time.Now().Unix())
+	})
+}
+
+func (t *Tracker) Start(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+	defer bus.Recover(ctx)
+	ticker := time.NewTicker(TRACK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Dst tx tracker is exiting now", "chain", t.s.name, "pending", len(t.list()))
+			return
+		case <-ticker.C:
+			for _, p := range t.list() {
+				t.check(p)
+			}
+			t.s.checkNonces()
+		}
+	}
+}
+
+func (t *Tracker) check(p *pendingTx) {
+	node := t.s.sdk.Node()
+	for _, hash := range p.hashes {
+		receipt, err := node.TransactionReceipt(context.Background(), hash)
+		if err == ethereum.NotFound {
+			continue
+		} else if err != nil {
+			log.Warn("Failed to fetch dst tx receipt", "chain", t.s.name, "hash", hash, "err", err)
+			return
+		}
+		// Mined, the confirmer takes over
+		log.Info("Dst tx mined", "chain", t.s.name, "poly_hash", p.tx.PolyHash, "hash", hash, "height", receipt.BlockNumber)
+		p.tx.DstHash = hash.String()
+		t.s.confirms.Watch(p.tx)
+		t.remove(p)
+		return
+	}
+
+	if p.raw == nil {
+		// Fetch the sent tx to compose the replacement
+		raw, _, err := node.TransactionByHash(context.Background(), p.hashes[0])
+		if err != nil {
+			log.Warn("Failed to fetch sent dst tx", "chain", t.s.name, "hash", p.hashes[0], "err", err)
+			return
+		}
+		p.raw = raw
+		if t.conf.MaxPrice != nil {
+			p.ceiling = t.conf.MaxPrice
+		} else {
+			p.ceiling = new(big.Int).Mul(raw.GasFeeCap(), big.NewInt(3))
+		}
+	}
+
+	nonce, err := node.NonceAt(context.Background(), p.account.Address, nil)
+	if err != nil {
+		log.Warn("Failed to fetch account nonce", "chain", t.s.name, "account", p.account.Address, "err", err)
+		return
+	}
+	if nonce > p.raw.Nonce() {
+		// Receipts may lag behind the nonce on some nodes, confirm in the next check
+		if p.missing {
+			t.finish(p, bus.OUTCOME_REPLACED)
+		}
+		p.missing = true
+		return
+	}
+	p.missing = false
+
+	if p.stuck || time.Since(p.sent) < time.Duration(t.conf.Deadline)*time.Second {
+		return
+	}
+	t.bump(p)
+}
+
+// Hold the stuck tx, still tracked to skip resubmission till mined or replaced
+func (t *Tracker) hold(p *pendingTx) {
+	p.stuck = true
+	bus.Stuck(p.tx)
+}
+
+// Send the replacement with the same nonce and bumped price, txs beyond the limits are held as stuck
+func (t *Tracker) bump(p *pendingTx) {
+	if t.conf.MaxBumps < 0 || p.bumps >= t.conf.MaxBumps {
+		log.Warn("Dst tx stuck with max bumps", "chain", t.s.name, "poly_hash", p.tx.PolyHash, "hashes", p.hashes)
+		t.hold(p)
+		return
+	}
+	raw := p.raw
+	var replacement types.TxData
+	switch raw.Type() {
+	case types.DynamicFeeTxType:
+		feeCap := bumpPrice(raw.GasFeeCap(), t.conf.Ratio, 0.125)
+		tip := bumpPrice(raw.GasTipCap(), t.conf.Ratio, 0.125)
+		if feeCap.Cmp(p.ceiling) > 0 {
+			log.Warn("Dst tx stuck with max price", "chain", t.s.name, "poly_hash", p.tx.PolyHash, "fee_cap", raw.GasFeeCap())
+			t.hold(p)
+			return
+		}
+		replacement = &types.DynamicFeeTx{
+			ChainID: raw.ChainId(), Nonce: raw.Nonce(), GasTipCap: minBig(tip, feeCap), GasFeeCap: feeCap,
+			Gas: raw.Gas(), To: raw.To(), Value: raw.Value(), Data: raw.Data(),
+		}
+	default:
+		price := bumpPrice(raw.GasPrice(), t.conf.Ratio, 0.1)
+		if price.Cmp(p.ceiling) > 0 {
+			log.Warn("Dst tx stuck with max price", "chain", t.s.name, "poly_hash", p.tx.PolyHash, "gas_price", raw.GasPrice())
+			t.hold(p)
+			return
+		}
+		replacement = &types.LegacyTx{
+			Nonce: raw.Nonce(), GasPrice: price, Gas: raw.Gas(), To: raw.To(), Value: raw.Value(), Data: raw.Data(),
+		}
+	}
+	provider, _ := t.s.signer.GetAccount(p.account)
+	if provider == nil {
+		log.Error("Missing provider to bump dst tx", "chain", t.s.name, "account", p.account.Address)
+		return
+	}
+	signed, err := provider.SignTx(p.account, types.NewTx(replacement), big.NewInt(int64(t.s.config.ChainId)))
+	if err != nil {
+		log.Error("Failed to sign dst tx replacement", "chain", t.s.name, "err", err)
+		return
+	}
+	err = t.s.sdk.Node().SendTransaction(context.Background(), signed)
+	if err != nil {
+		log.Error("Failed to send dst tx replacement", "chain", t.s.name, "poly_hash", p.tx.PolyHash, "nonce", raw.Nonce(), "err", err)
+		return
+	}
+	p.raw, p.sent = signed, time.Now()
+	p.hashes = append(p.hashes, signed.Hash())
+	p.bumps++
+	log.Info("Bumped stuck dst tx", "chain", t.s.name, "poly_hash", p.tx.PolyHash, "nonce", raw.Nonce(), "hash", signed.Hash(),
+		"gas_price", signed.GasPrice(), "tip", signed.GasTipCap(), "bumps", p.bumps)
+}
+
+// Receipts of the dst chain for the confirmer
+type receipts struct {
+	sdk *eth.SDK
+}
+
+func (r *receipts) Receipt(hash string) (*bus.Receipt, error) {
+	receipt, err := r.sdk.Node().TransactionReceipt(context.Background(), common.HexToHash(hash))
+	if err == ethereum.NotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &bus.Receipt{
+		Height: receipt.BlockNumber.Uint64(), Block: receipt.BlockHash.String(),
+		Success: receipt.Status == types.ReceiptStatusSuccessful, GasUsed: receipt.GasUsed,
+	}, nil
+}
+
+func (r *receipts) Height() (uint64, error) {
+	return r.sdk.Node().GetLatestHeight()
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+func TestBumpPrice(t *testing.T) {
+	cases := []struct {
+		price    int64
+		ratio    float64
+		min      float64
+		expected int64
+	}{
+		{100, 0, 0.1, 111},
+		{100, 0.05, 0.125, 113},
+		{100, 0.5, 0.1, 151},
+		{1, 0, 0.1, 2},
+	}
+	for _, c := range cases {
+		v := bumpPrice(big.NewInt(c.price), c.ratio, c.min)
+		if v.Int64() != c.expected {
+			t.Fatalf("Bump %v by %v expect %v, got %v", c.price, c.ratio, c.expected, v)
+		}
+	}
+}
+
+func TestTrackerStuck(t *testing.T) {
+	ctx := context.Background()
+	s := &Submitter{Context: ctx, name: "test", config: &config.SubmitterConfig{ChainId: 2, GasBump: &config.GasBumpConfig{MaxBumps: 2}}}
+	delay := bus.NewMemoryDelayedTxBus(bus.NewMemoryDB())
+	tracker := NewTracker(s, delay)
+	track := func(hash string) *pendingTx {
+		tracker.Track(accounts.Account{}, &msg.Tx{PolyHash: hash, DstChainId: 2, DstHash: "0x01"})
+		p := tracker.txs[hash]
+		p.raw = types.NewTx(&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(100)})
+		p.ceiling = big.NewInt(1000)
+		return p
+	}
+
+	// Stuck txs stay tracked, so the submitter skips them while the nonce is pending
+	p := track("0xa")
+	p.bumps = 2
+	tracker.bump(p)
+	if !tracker.Pending("0xa") || !p.stuck {
+		t.Fatal("Expect tx with max bumps held as stuck")
+	}
+
+	p = track("0xb")
+	p.ceiling = big.NewInt(100)
+	tracker.bump(p)
+	if !tracker.Pending("0xb") || !p.stuck || p.bumps != 0 {
+		t.Fatal("Expect tx with max price held as stuck")
+	}
+	if n, _ := delay.Len(ctx); n != 0 {
+		t.Fatalf("Expect stuck txs not processed again, got delayed %v", n)
+	}
+
+	// Processed again once the nonce is taken by another tx
+	tracker.finish(p, bus.OUTCOME_REPLACED)
+	if tracker.Pending("0xb") {
+		t.Fatal("Expect replaced tx no longer tracked")
+	}
+	if n, _ := delay.Len(ctx); n != 1 {
+		t.Fatalf("Expect replaced tx processed again, got delayed %v", n)
+	}
+}
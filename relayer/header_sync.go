@@ -34,6 +34,7 @@ import (
 
 type HeaderSyncHandler struct {
 	context.Context
+	cancel    context.CancelFunc
 	wg        *sync.WaitGroup
 	listener  IChainListener
 	submitter *poly.Submitter
@@ -56,7 +57,7 @@ func NewHeaderSyncHandler(config *config.HeaderSyncConfig) *HeaderSyncHandler {
 }
 
 func (h *HeaderSyncHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err error) {
-	h.Context = ctx
+	h.Context, h.cancel = context.WithCancel(ctx)
 	h.wg = wg
 
 	err = h.submitter.Init(h.config.Poly)
@@ -73,19 +74,19 @@ func (h *HeaderSyncHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err e
 		return
 	}
 
-	h.state = bus.NewRedisChainStore(
-		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_HEADER}, bus.New(h.config.Bus.Redis),
+	h.state = bus.NewChainStore(
+		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_HEADER}, h.config.Bus,
 		h.config.Bus.HeightUpdateInterval,
 	)
-	h.input = bus.NewRedisChainStore(
-		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_HEADER_RESET}, bus.New(h.config.Bus.Redis),
+	h.input = bus.NewChainStore(
+		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_HEADER_RESET}, h.config.Bus,
 		h.config.Bus.HeightUpdateInterval,
 	)
-	h.latest = bus.NewRedisChainStore(
-		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_CHAIN}, bus.New(h.config.Bus.Redis), 0,
+	h.latest = bus.NewChainStore(
+		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_CHAIN}, h.config.Bus, 0,
 	)
-	h.sync = bus.NewRedisChainStore(
-		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_CHAIN_HEADER}, bus.New(h.config.Bus.Redis), 0,
+	h.sync = bus.NewChainStore(
+		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_CHAIN_HEADER}, h.config.Bus, 0,
 	)
 
 	return
@@ -157,6 +158,7 @@ func (h *HeaderSyncHandler) RollbackToCommonAncestor(height, target uint64) uint
 func (h *HeaderSyncHandler) watch() {
 	h.wg.Add(1)
 	defer h.wg.Done()
+	defer bus.Recover(h.Context)
 	ticker := time.NewTicker(3 * time.Second)
 	last := uint64(0)
 	for {
@@ -192,6 +194,7 @@ func (h *HeaderSyncHandler) watch() {
 func (h *HeaderSyncHandler) start(ch chan msg.Header) {
 	h.wg.Add(1)
 	defer h.wg.Done()
+	defer bus.Recover(h.Context)
 	confirms := uint64(h.listener.Defer())
 	var (
 		latest uint64
@@ -272,6 +275,10 @@ func (h *HeaderSyncHandler) Start() (err error) {
 }
 
 func (h *HeaderSyncHandler) Stop() (err error) {
+	if h.cancel != nil {
+		h.cancel()
+		h.wg.Wait()
+	}
 	return
 }
 
@@ -25,6 +25,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/urfave/cli/v2"
 
@@ -37,8 +38,8 @@ import (
 )
 
 var (
-	_PATCHER *bus.RedisTxBus
-	_SKIP    *bus.RedisSkipCheck
+	_PATCHER bus.TxBus
+	_SKIP    bus.SkipCheck
 )
 
 func Http(ctx *cli.Context) (err error) {
@@ -54,8 +55,8 @@ func Http(ctx *cli.Context) (err error) {
 	}
 
 	// Init patcher
-	_PATCHER = bus.NewRedisPatchTxBus(bus.New(config.CONFIG.Bus.Redis), 0)
-	_SKIP = bus.NewRedisSkipCheck(bus.New(config.CONFIG.Bus.Redis))
+	_PATCHER = bus.NewPatchTxBus(config.CONFIG.Bus, 0)
+	_SKIP = bus.NewSkipCheck(config.CONFIG.Bus)
 	err = SetupController()
 	if err != nil {
 		return
@@ -70,20 +71,27 @@ func Http(ctx *cli.Context) (err error) {
 		http.HandleFunc("/api/v1/skip", SkipTx)
 		http.HandleFunc("/api/v1/skipcheck", SkipCheckTx)
 		http.HandleFunc("/api/v1/composetx", controller.ComposeDstTx)
+		http.HandleFunc("/api/v1/dlq", DeadLetters)
+		http.HandleFunc("/api/v1/dlq/requeue", Admin(RequeueDeadLetterTx))
+		http.HandleFunc("/api/v1/dlq/drop", Admin(DropDeadLetterTx))
+		http.HandleFunc("/api/v1/roles", Roles)
+		http.HandleFunc("/api/v1/controls", RoleControls)
+		http.HandleFunc("/api/v1/admin/pause", PauseRoleHandler)
+		http.HandleFunc("/api/v1/admin/resume", ResumeRoleHandler)
+		http.HandleFunc("/api/v1/admin/procs", RoleProcsHandler)
+		http.HandleFunc("/api/v1/admin/filter", RoleFilterHandler)
 	}
 	http.ListenAndServe(fmt.Sprintf("%v:%v", host, port), nil)
 	return
 }
 
 func recordMetrics() {
-	h := NewStatusHandler(config.CONFIG.Bus.Redis)
+	h := NewStatusHandler(config.CONFIG.Bus)
 	timer := time.NewTicker(2 * time.Second)
 	for range timer.C {
 		start := time.Now()
 		for _, chain := range base.CHAINS {
-			name := base.GetChainName(chain)
-			name = strings.ReplaceAll(name, "(", "")
-			name = strings.ReplaceAll(name, ")", "")
+			name := chainMetricName(chain)
 			latest, _ := h.Height(chain, bus.KEY_HEIGHT_CHAIN)
 			header, _ := h.Height(chain, bus.KEY_HEIGHT_CHAIN_HEADER)
 			mark, _ := h.Height(chain, bus.KEY_HEIGHT_HEADER)
@@ -111,10 +119,53 @@ func recordMetrics() {
 		}
 		qDelayed, _ := h.LenDelayed()
 		metrics.Record(qDelayed, "queue_size.delayed")
+		sizes, _ := h.LenDelayedChains()
+		for chain, size := range sizes {
+			if chain != 0 {
+				metrics.Record(size, "queue_size.delayed.%s", chainMetricName(chain))
+			}
+		}
+		instances, _ := bus.NewRegistry(config.CONFIG.Bus).Instances(context.Background())
+		drops, outcomes, gas := map[string]uint64{}, map[string]uint64{}, map[string]uint64{}
+		for _, i := range instances {
+			for reason, count := range i.Drops {
+				drops[metricName(reason)] += count
+			}
+			for key, count := range i.Outcomes {
+				outcomes[key] += count
+			}
+			for chain, used := range i.GasUsed {
+				gas[chain] += used
+			}
+		}
+		for reason, count := range drops {
+			metrics.Record(count, "filter_drops.%s", reason)
+		}
+		for key, count := range outcomes {
+			metrics.Record(count, "dst_tx.%s", key)
+		}
+		for chain, used := range gas {
+			metrics.Record(used, "dst_gas_used.%s", chain)
+		}
 		log.Info("metrics tick", "elapse", time.Since(start))
 	}
 }
 
+// Metric key segment of the chain name, parens stripped
+func chainMetricName(chain uint64) string {
+	return strings.NewReplacer("(", "", ")", "").Replace(base.GetChainName(chain))
+}
+
+// Metric key segment of the text
+func metricName(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, text)
+}
+
 func SkipTx(w http.ResponseWriter, r *http.Request) {
 	hash := r.FormValue("hash")
 	err := _SKIP.Skip(context.Background(), &msg.Tx{PolyHash: hash})
@@ -144,8 +195,12 @@ func PatchTx(w http.ResponseWriter, r *http.Request) {
 	hash := r.FormValue("hash")
 	tx := &msg.Tx{
 		SkipCheckFee: r.FormValue("free") == "true",
+		Force:        r.FormValue("force") == "true",
+		Resubmit:     r.FormValue("resubmit") == "true",
 		DstGasPrice:  r.FormValue("price"),
 		DstGasPriceX: r.FormValue("pricex"),
+		DstGasTipCap: r.FormValue("tip"),
+		DstGasFeeCap: r.FormValue("feecap"),
 		DstGasLimit:  uint64(limit),
 	}
 	if chain == 0 {
@@ -175,8 +230,12 @@ func Patch(ctx *cli.Context) (err error) {
 	hash := ctx.String("hash")
 	tx := &msg.Tx{
 		SkipCheckFee: ctx.Bool("free"),
+		Force:        ctx.Bool("force"),
+		Resubmit:     ctx.Bool("resubmit"),
 		DstGasPrice:  ctx.String("price"),
 		DstGasPriceX: ctx.String("pricex"),
+		DstGasTipCap: ctx.String("tip"),
+		DstGasFeeCap: ctx.String("feecap"),
 		DstGasLimit:  uint64(ctx.Int("limit")),
 	}
 	if chain == 0 {
@@ -189,7 +248,7 @@ func Patch(ctx *cli.Context) (err error) {
 		tx.SrcHeight = height
 		tx.SrcChainId = chain
 	}
-	err = bus.NewRedisPatchTxBus(bus.New(config.CONFIG.Bus.Redis), 0).Patch(context.Background(), tx)
+	err = bus.NewPatchTxBus(config.CONFIG.Bus, 0).Patch(context.Background(), tx)
 	if err != nil {
 		log.Error("Patch tx failed", "err", err)
 		log.Json(log.ERROR, tx)
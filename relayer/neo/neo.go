@@ -22,6 +22,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,12 +32,12 @@ import (
 
 	nw "github.com/joeqian10/neo-gogogo/wallet"
 	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/chains/bridge"
 	"github.com/polynetwork/bridge-common/chains/neo"
 	"github.com/polynetwork/bridge-common/chains/poly"
 	"github.com/polynetwork/bridge-common/log"
 	"github.com/polynetwork/bridge-common/util"
 	"github.com/polynetwork/bridge-common/wallet"
-	"github.com/polynetwork/bridge-common/chains/bridge"
 	"github.com/polynetwork/poly-relayer/bus"
 	"github.com/polynetwork/poly-relayer/config"
 	"github.com/polynetwork/poly-relayer/msg"
@@ -50,18 +52,21 @@ const (
 
 type Submitter struct {
 	context.Context
-	wg     *sync.WaitGroup
-	config *config.SubmitterConfig
-	sdk    *neo.SDK
-	name   string
-	ccd    string
-	ccm    string
-	polyId uint64
-	wallet *wallet.NeoWallet
+	wg       *sync.WaitGroup
+	config   *config.SubmitterConfig
+	sdk      *neo.SDK
+	name     string
+	ccd      string
+	ccm      string
+	polyId   uint64
+	wallet   *wallet.NeoWallet
+	retry    bus.RetryPolicy
+	confirms *bus.Confirmer // Submitted dst txs till final
 }
 
 func (s *Submitter) Init(config *config.SubmitterConfig) (err error) {
 	s.config = config
+	s.retry = bus.NewRetryPolicy(config.Retry)
 	s.sdk, err = neo.WithOptions(config.ChainId, config.Nodes, time.Minute, 1)
 	if err != nil {
 		return
@@ -102,11 +107,11 @@ func (s *Submitter) Hook(ctx context.Context, wg *sync.WaitGroup, ch <-chan msg.
 
 func (s *Submitter) ProcessTx(m *msg.Tx, compose msg.PolyComposer) (err error) {
 	if m.Type() != msg.POLY {
-		return fmt.Errorf("%s desired message is not poly tx %v", m.Type())
+		return fmt.Errorf("%s desired message is not poly tx %v", s.name, m.Type())
 	}
 
 	if m.DstChainId != s.config.ChainId {
-		return fmt.Errorf("%s message dst chain does not match %v", m.DstChainId)
+		return fmt.Errorf("%s message dst chain does not match %v", s.name, m.DstChainId)
 	}
 	h, err := s.sdk.Node().GetPolyEpochHeight(s.ccm, s.polyId)
 	if err != nil {
@@ -204,6 +209,7 @@ func (s *Submitter) processPolyHeader(tx *msg.Tx) (err error) {
 func (s *Submitter) run(account *nw.Account, mq bus.TxBus, delay bus.DelayedTxBus, compose msg.PolyComposer) error {
 	s.wg.Add(1)
 	defer s.wg.Done()
+	defer bus.Recover(s.Context)
 	for {
 		select {
 		case <-s.Done():
@@ -220,6 +226,12 @@ func (s *Submitter) run(account *nw.Account, mq bus.TxBus, delay bus.DelayedTxBu
 			time.Sleep(time.Second)
 			continue
 		}
+		if !tx.Resubmit && s.confirms.Pending(tx.PolyHash) {
+			log.Info("Skipped poly tx with dst tx pending", "chain", s.name, "poly_hash", tx.PolyHash)
+			bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
+			continue
+		}
+		tx.Resubmit = false // Honored once, retries skip while pending
 		log.Info("Processing poly tx", "poly_hash", tx.PolyHash, "account", account.Address)
 		tx.DstSender = account
 		err = s.ProcessTx(tx, compose)
@@ -231,34 +243,37 @@ func (s *Submitter) run(account *nw.Account, mq bus.TxBus, delay bus.DelayedTxBu
 			log.Json(log.ERROR, tx)
 			if errors.Is(err, msg.ERR_INVALID_TX) || errors.Is(err, msg.ERR_TX_BYPASS) {
 				log.Error("Skipped poly tx for error", "poly_hash", tx.PolyHash, "err", err)
+				bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
 				continue
 			}
-			tx.Attempts++
-			if errors.Is(err, msg.ERR_TX_EXEC_FAILURE) {
-				tsp := time.Now().Unix() + 60*3
-				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-			} else if errors.Is(err, msg.ERR_FEE_CHECK_FAILURE) {
-				tsp := time.Now().Unix() + 10
+			tx.Fail(err)
+			if errors.Is(err, msg.ERR_TX_EXEC_FAILURE) || errors.Is(err, msg.ERR_FEE_CHECK_FAILURE) {
+				tsp := s.retry.Next(tx, err)
 				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
 			} else {
-				bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Push(context.Background(), tx) })
+				bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Nack(context.Background(), tx) })
+				continue
 			}
 		} else {
 			log.Info("Submitted poly tx", "poly_hash", tx.PolyHash, "chain", s.name, "dst_hash", tx.DstHash)
+			s.confirms.Watch(tx)
 		}
+		bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
 	}
 }
 
-func (s *Submitter) Start(ctx context.Context, wg *sync.WaitGroup, bus bus.TxBus, delay bus.DelayedTxBus, composer msg.PolyComposer) error {
+func (s *Submitter) Start(ctx context.Context, wg *sync.WaitGroup, mq bus.TxBus, delay bus.DelayedTxBus, composer msg.PolyComposer) error {
 	s.Context = ctx
 	s.wg = wg
+	s.confirms = bus.NewConfirmer(s.config.ChainId, &receipts{s.sdk}, delay, s.retry, bus.Confirmations(s.config.ChainId, s.config.Confirms))
+	go s.confirms.Start(ctx, wg)
 	accounts := s.wallet.Accounts
 	if len(accounts) == 0 {
 		log.Warn("No account available for submitter workers", "chain", s.name)
 	}
 	for i, a := range accounts {
 		log.Info("Starting submitter worker", "index", i, "total", len(accounts), "account", a.Address, "chain", s.name)
-		go s.run(a, bus, delay, composer)
+		go s.run(a, mq, delay, composer)
 	}
 	return nil
 }
@@ -275,3 +290,39 @@ func (s *Submitter) Process(m msg.Message, compose msg.PolyComposer) (err error)
 	}
 	return s.ProcessTx(tx, compose)
 }
+
+// Receipts of the dst chain for the confirmer
+type receipts struct {
+	sdk *neo.SDK
+}
+
+func (r *receipts) Receipt(hash string) (*bus.Receipt, error) {
+	node := r.sdk.Node()
+	res := node.GetTransactionHeight(hash)
+	if res.NetError != nil {
+		return nil, res.NetError
+	} else if res.HasError() {
+		// Unknown transaction
+		return nil, nil
+	}
+	appLog := node.GetApplicationLog(hash)
+	if appLog.HasError() {
+		return nil, fmt.Errorf("Failed to get application log %s", appLog.GetErrorInfo())
+	}
+	receipt := &bus.Receipt{Height: uint64(res.Result), Success: len(appLog.Result.Executions) > 0}
+	for _, e := range appLog.Result.Executions {
+		if strings.Contains(e.VMState, "FAULT") {
+			receipt.Success = false
+		}
+		// Gas consumed in GAS, counted in the smallest unit
+		if gas, ok := new(big.Float).SetString(e.GasConsumed); ok {
+			used, _ := gas.Mul(gas, big.NewFloat(1e8)).Uint64()
+			receipt.GasUsed += used
+		}
+	}
+	return receipt, nil
+}
+
+func (r *receipts) Height() (uint64, error) {
+	return r.sdk.Node().GetLatestHeight()
+}
@@ -48,10 +48,12 @@ type Submitter struct {
 	name    string
 	compose msg.PolyComposer
 	polyId  uint64
+	retry   bus.RetryPolicy
 }
 
 func (s *Submitter) Init(config *config.SubmitterConfig) (err error) {
 	s.config = config
+	s.retry = bus.NewRetryPolicy(config.Retry)
 	s.signer, err = wallet.NewOntSigner(config.Wallet)
 	s.name = base.GetChainName(config.ChainId)
 	s.sdk, err = ont.WithOptions(base.ONT, config.Nodes, time.Minute, 1)
@@ -78,10 +80,10 @@ func (s *Submitter) Hook(ctx context.Context, wg *sync.WaitGroup, ch <-chan msg.
 
 func (s *Submitter) ProcessTx(m *msg.Tx, compose msg.PolyComposer) (err error) {
 	if m.Type() != msg.POLY {
-		return fmt.Errorf("%s desired message is not poly tx %v", m.Type())
+		return fmt.Errorf("%s desired message is not poly tx %v", s.name, m.Type())
 	}
 	if m.DstChainId != s.config.ChainId {
-		return fmt.Errorf("%s message dst chain does not match %v", m.DstChainId)
+		return fmt.Errorf("%s message dst chain does not match %v", s.name, m.DstChainId)
 	}
 	err = compose(m)
 	if err != nil {
@@ -140,6 +142,7 @@ func (s *Submitter) Stop() error {
 func (s *Submitter) run(account *sdk.Account, mq bus.TxBus, delay bus.DelayedTxBus, compose msg.PolyComposer) error {
 	s.wg.Add(1)
 	defer s.wg.Done()
+	defer bus.Recover(s.Context)
 	for {
 		select {
 		case <-s.Done():
@@ -166,19 +169,21 @@ func (s *Submitter) run(account *sdk.Account, mq bus.TxBus, delay bus.DelayedTxB
 			log.Json(log.ERROR, tx)
 			if errors.Is(err, msg.ERR_INVALID_TX) || errors.Is(err, msg.ERR_TX_BYPASS) {
 				log.Error("Skipped poly tx for error", "poly_hash", tx.PolyHash, "err", err)
+				bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
 				continue
 			}
-			tx.Attempts++
+			tx.Fail(err)
 			if errors.Is(err, msg.ERR_TX_EXEC_FAILURE) {
-				tsp := time.Now().Unix() + 60*3
+				tsp := s.retry.Next(tx, err)
 				bus.SafeCall(s.Context, tx, "push to delay queue", func() error { return delay.Delay(context.Background(), tx, tsp) })
-				continue
 			} else {
-				bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Push(context.Background(), tx) })
+				bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Nack(context.Background(), tx) })
+				continue
 			}
 		} else {
 			log.Info("Submitted poly tx", "poly_hash", tx.PolyHash, "chain", s.name, "dst_hash", tx.DstHash)
 		}
+		bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
 	}
 }
 
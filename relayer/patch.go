@@ -106,6 +106,12 @@ func Relay(tx *msg.Tx) {
 		"-hash", hash, "-chain", strconv.Itoa(int(chain)),
 		"-price", tx.DstGasPrice, "-pricex", tx.DstGasPriceX, "-limit", strconv.Itoa(int(tx.DstGasLimit)),
 	}
+	if tx.DstGasTipCap != "" {
+		args = append(args, "-tip", tx.DstGasTipCap)
+	}
+	if tx.DstGasFeeCap != "" {
+		args = append(args, "-feecap", tx.DstGasFeeCap)
+	}
 	if tx.SkipCheckFee {
 		args = append(args, "-free")
 	}
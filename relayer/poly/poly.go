@@ -308,6 +308,7 @@ func (s *Submitter) ReadyBlock() (height uint64) {
 func (s *Submitter) consume(mq bus.SortedTxBus) error {
 	s.wg.Add(1)
 	defer s.wg.Done()
+	defer bus.Recover(s.Context)
 	ticker := time.NewTicker(300 * time.Millisecond)
 	defer ticker.Stop()
 
@@ -345,6 +346,7 @@ func (s *Submitter) consume(mq bus.SortedTxBus) error {
 			err = s.submit(tx)
 			if err == nil {
 				log.Info("Submitted src tx to poly", "src_hash", tx.SrcHash, "poly_hash", tx.PolyHash)
+				bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
 				continue
 			}
 
@@ -356,15 +358,16 @@ func (s *Submitter) consume(mq bus.SortedTxBus) error {
 
 			if strings.Contains(err.Error(), "side chain") && strings.Contains(err.Error(), "not registered") {
 				log.Warn("Submit src tx to poly error", "chain", s.name, "err", err, "proof_height", tx.SrcProofHeight)
+				bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
 				continue
 			}
 
 			block = height + 10
-			tx.Attempts++
+			tx.Fail(err)
 			log.Error("Submit src tx to poly error", "chain", s.name, "err", err, "proof_height", tx.SrcProofHeight, "next_try", block)
-			bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Push(context.Background(), tx, block) })
+			bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Nack(context.Background(), tx, block) })
 		} else {
-			bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Push(context.Background(), tx, block) })
+			bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Nack(context.Background(), tx, block) })
 			time.Sleep(200 * time.Millisecond)
 		}
 	}
@@ -373,6 +376,7 @@ func (s *Submitter) consume(mq bus.SortedTxBus) error {
 func (s *Submitter) run(mq bus.TxBus) error {
 	s.wg.Add(1)
 	defer s.wg.Done()
+	defer bus.Recover(s.Context)
 	ticker := time.NewTicker(800 * time.Millisecond)
 	defer ticker.Stop()
 
@@ -414,7 +418,7 @@ func (s *Submitter) run(mq bus.TxBus) error {
 			err = s.submit(tx)
 			if err != nil {
 				log.Error("Submit src tx to poly error", "chain", s.name, "err", err, "proof_height", tx.SrcProofHeight)
-				tx.Attempts++
+				tx.Fail(err)
 				if errors.Is(err, msg.ERR_Tx_VERIFYMERKLEPROOF) {
 					log.Warn("src tx submit to poly verifyMerkleProof failed, clear src proof", "chain", s.name, "src hash", tx.SrcHash, "err", err)
 					tx.SrcProofHex = ""
@@ -435,7 +439,9 @@ func (s *Submitter) run(mq bus.TxBus) error {
 		}
 
 		if retry {
-			bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Push(context.Background(), tx) })
+			bus.SafeCall(s.Context, tx, "push back to tx bus", func() error { return mq.Nack(context.Background(), tx) })
+		} else {
+			bus.SafeCall(s.Context, tx, "ack tx", func() error { return mq.Ack(context.Background(), tx) })
 		}
 	}
 }
@@ -591,6 +597,7 @@ COMMIT:
 }
 
 func (s *Submitter) startSync(ch <-chan msg.Header, reset chan<- uint64) {
+	defer bus.Recover(s.Context)
 	if s.sync.Batch == 1 {
 		s.syncHeaderLoop(ch, reset)
 	} else {
@@ -0,0 +1,360 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package relayer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/bridge-common/util"
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+	"github.com/polynetwork/poly-relayer/msg"
+)
+
+// Queue names of the queue commands
+const (
+	QUEUE_BUS     = "bus"
+	QUEUE_SORTED  = "sorted"
+	QUEUE_DELAYED = "delayed"
+	QUEUE_PATCH   = "patch"
+)
+
+// Queue to inspect and operate on
+type TxQueue struct {
+	Name    string
+	Chain   uint64
+	entries func(context.Context, int64) ([]*bus.Entry, error)
+	remove  func(context.Context, ...*bus.Entry) (uint64, error)
+	push    func(context.Context, *bus.Entry) error
+}
+
+func OpenTxQueue(conf *config.BusConfig, name string, chain uint64, ty msg.TxType) (q *TxQueue, err error) {
+	q = &TxQueue{Name: name, Chain: chain}
+	switch name {
+	case QUEUE_BUS, "":
+		q.Name = QUEUE_BUS
+		b := bus.NewTxBus(conf, chain, ty)
+		q.entries, q.remove = b.Entries, b.Remove
+		q.push = func(ctx context.Context, e *bus.Entry) error {
+			if chain == 0 {
+				return b.PushToChain(ctx, e.Tx)
+			}
+			return b.Push(ctx, e.Tx)
+		}
+	case QUEUE_SORTED:
+		b := bus.NewSortedTxBus(conf, chain, ty)
+		q.entries, q.remove = b.Entries, b.Remove
+		q.push = func(ctx context.Context, e *bus.Entry) error {
+			return b.Push(ctx, e.Tx, uint64(e.Score))
+		}
+	case QUEUE_DELAYED:
+		b := bus.NewDelayedTxBus(conf)
+		q.entries, q.remove = b.Entries, b.Remove
+		q.push = func(ctx context.Context, e *bus.Entry) error {
+			return b.Delay(ctx, e.Tx, e.Score)
+		}
+	case QUEUE_PATCH:
+		b := bus.NewPatchTxBus(conf, chain)
+		q.entries, q.remove = b.Entries, b.Remove
+		q.push = func(ctx context.Context, e *bus.Entry) error {
+			return b.Push(ctx, e.Tx)
+		}
+	default:
+		return nil, fmt.Errorf("Unknown queue %s", name)
+	}
+	return
+}
+
+// Entries matching the filter, delayed queues are filtered by dst chain
+func (q *TxQueue) Entries(ctx context.Context, filter *EntryFilter) (list []*bus.Entry, err error) {
+	entries, err := q.entries(ctx, 0)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if q.Name == QUEUE_DELAYED && q.Chain != 0 && e.Tx.DstChainId != q.Chain {
+			continue
+		}
+		if filter.Match(e) {
+			list = append(list, e)
+			if filter.Limit > 0 && len(list) >= filter.Limit {
+				break
+			}
+		}
+	}
+	return
+}
+
+func (q *TxQueue) Remove(ctx context.Context, entries ...*bus.Entry) (uint64, error) {
+	return q.remove(ctx, entries...)
+}
+
+func (q *TxQueue) Push(ctx context.Context, e *bus.Entry) error {
+	return q.push(ctx, e)
+}
+
+// Move the entry into the target queue, skipped if consumed in the meantime
+func (q *TxQueue) Move(ctx context.Context, e *bus.Entry, to *TxQueue) (bool, error) {
+	n, err := q.Remove(ctx, e)
+	if err != nil || n == 0 {
+		return false, err
+	}
+	return true, to.Push(ctx, e)
+}
+
+type EntryFilter struct {
+	Hash     string
+	MinAge   int64
+	Attempts int
+	Limit    int
+}
+
+func NewEntryFilter(ctx *cli.Context) *EntryFilter {
+	return &EntryFilter{
+		Hash:     ctx.String("hash"),
+		MinAge:   ctx.Int64("age"),
+		Attempts: ctx.Int("attempts"),
+		Limit:    ctx.Int("limit"),
+	}
+}
+
+func (f *EntryFilter) Empty() bool {
+	return f.Hash == "" && f.MinAge == 0 && f.Attempts == 0
+}
+
+func (f *EntryFilter) Match(e *bus.Entry) bool {
+	if f.Hash != "" {
+		hash := util.LowerHex(f.Hash)
+		if hash != util.LowerHex(e.Tx.PolyHash) && hash != util.LowerHex(e.Tx.SrcHash) {
+			return false
+		}
+	}
+	if f.MinAge > 0 && e.Age() < f.MinAge {
+		return false
+	}
+	return e.Tx.Attempts >= f.Attempts
+}
+
+func queueTxType(ctx *cli.Context) msg.TxType {
+	switch ctx.String("type") {
+	case "src":
+		return msg.SRC
+	case "poly":
+		return msg.POLY
+	}
+	if ctx.String("queue") == QUEUE_SORTED {
+		return msg.SRC
+	}
+	return msg.POLY
+}
+
+func openQueue(ctx *cli.Context) (*TxQueue, error) {
+	return OpenTxQueue(config.CONFIG.Bus, ctx.String("queue"), ctx.Uint64("chain"), queueTxType(ctx))
+}
+
+func queuedEntries(ctx *cli.Context) (q *TxQueue, entries []*bus.Entry, err error) {
+	q, err = openQueue(ctx)
+	if err != nil {
+		return
+	}
+	entries, err = q.Entries(context.Background(), NewEntryFilter(ctx))
+	return
+}
+
+func entryHash(e *bus.Entry) string {
+	if e.Tx.PolyHash != "" {
+		return e.Tx.PolyHash
+	}
+	return e.Tx.SrcHash
+}
+
+func QueueList(ctx *cli.Context) (err error) {
+	q, entries, err := queuedEntries(ctx)
+	if err != nil {
+		return
+	}
+	fmt.Printf("Queue %s %s entries: %v\n", q.Name, base.GetChainName(q.Chain), len(entries))
+	for _, e := range entries {
+		fmt.Printf("  %s src %s dst %s attempts %v age %s score %v\n",
+			entryHash(e), base.GetChainName(e.Tx.SrcChainId), base.GetChainName(e.Tx.DstChainId),
+			e.Tx.Attempts, time.Duration(e.Age())*time.Second, e.Score,
+		)
+	}
+	return
+}
+
+func QueuePeek(ctx *cli.Context) (err error) {
+	_, entries, err := queuedEntries(ctx)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		log.Json(log.INFO, e)
+	}
+	return
+}
+
+func QueueMove(ctx *cli.Context) (err error) {
+	q, entries, err := queuedEntries(ctx)
+	if err != nil {
+		return
+	}
+	chain := ctx.Uint64("chain")
+	if ctx.IsSet("tochain") {
+		chain = ctx.Uint64("tochain")
+	}
+	to, err := OpenTxQueue(config.CONFIG.Bus, ctx.String("to"), chain, queueTxType(ctx))
+	if err != nil {
+		return
+	}
+	count := 0
+	for _, e := range entries {
+		if to.Name == QUEUE_DELAYED {
+			e.Score = time.Now().Unix() + ctx.Int64("delay")
+		}
+		moved, err := q.Move(context.Background(), e, to)
+		if err != nil {
+			return err
+		}
+		if moved {
+			count++
+		}
+	}
+	log.Info("Moved queued txs", "from", q.Name, "to", to.Name, "chain", chain, "count", count)
+	return
+}
+
+func QueuePurge(ctx *cli.Context) (err error) {
+	if NewEntryFilter(ctx).Empty() && !ctx.Bool("all") {
+		return fmt.Errorf("Specify a filter or all to purge the queue")
+	}
+	q, entries, err := queuedEntries(ctx)
+	if err != nil {
+		return
+	}
+	count, err := q.Remove(context.Background(), entries...)
+	if err == nil {
+		log.Info("Purged queued txs", "queue", q.Name, "chain", q.Chain, "count", count)
+	}
+	return
+}
+
+// Push the entries back to the tx buses of their target chains with retries reset
+func QueueRequeue(ctx *cli.Context) (err error) {
+	q, entries, err := queuedEntries(ctx)
+	if err != nil {
+		return
+	}
+	count := 0
+	for _, e := range entries {
+		tx := e.Tx
+		name, chain := QUEUE_BUS, tx.DstChainId
+		if tx.Type() == msg.SRC {
+			name, chain = QUEUE_SORTED, tx.SrcChainId
+		}
+		to, err := OpenTxQueue(config.CONFIG.Bus, name, chain, tx.Type())
+		if err != nil {
+			return err
+		}
+		tx.Attempts = 0
+		tx.FirstFailure = 0
+		tx.History = nil
+		moved, err := q.Move(context.Background(), &bus.Entry{Key: e.Key, Id: e.Id, Tx: tx}, to)
+		if err != nil {
+			return err
+		}
+		if moved {
+			count++
+		}
+	}
+	log.Info("Requeued txs", "queue", q.Name, "count", count)
+	return
+}
+
+func QueueExport(ctx *cli.Context) (err error) {
+	q, entries, err := queuedEntries(ctx)
+	if err != nil {
+		return
+	}
+	var w io.Writer = os.Stdout
+	if path := ctx.String("file"); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	encoder := json.NewEncoder(w)
+	for _, e := range entries {
+		err = encoder.Encode(e)
+		if err != nil {
+			return fmt.Errorf("Failed to export entry %v", err)
+		}
+	}
+	log.Info("Exported queued txs", "queue", q.Name, "chain", q.Chain, "count", len(entries))
+	return
+}
+
+func QueueImport(ctx *cli.Context) (err error) {
+	q, err := openQueue(ctx)
+	if err != nil {
+		return
+	}
+	var r io.Reader = os.Stdin
+	if path := ctx.String("file"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	count := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		e := new(bus.Entry)
+		err = json.Unmarshal(line, e)
+		if err != nil || e.Tx == nil {
+			return fmt.Errorf("Failed to decode entry at line %v, err %v", count+1, err)
+		}
+		err = q.Push(context.Background(), e)
+		if err != nil {
+			return
+		}
+		count++
+	}
+	err = scanner.Err()
+	log.Info("Imported queued txs", "queue", q.Name, "chain", q.Chain, "count", count)
+	return
+}
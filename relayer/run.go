@@ -19,76 +19,276 @@ package relayer
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"reflect"
 	"sync"
 	"time"
 
 	"github.com/polynetwork/bridge-common/base"
 	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/bus"
 	"github.com/polynetwork/poly-relayer/config"
 )
 
+const (
+	ROLE_STATUS_INTERVAL = 10 * time.Second // Interval to publish role states of the instance
+	CONTROL_INTERVAL     = 5 * time.Second  // Interval to apply runtime role controls
+)
+
 type Server struct {
-	ctx    context.Context
-	wg     *sync.WaitGroup
-	config *config.Config
-	roles  []Handler
+	sync.Mutex
+	ctx      context.Context
+	wg       *sync.WaitGroup
+	config   *config.Config
+	roles    *Supervisor
+	store    bus.ControlStore
+	controls map[string]*bus.Control // Runtime role controls applied
+	snapshot map[string]string       // Task configs of the running config
+	shard    *Shard
 }
 
-func Start(ctx context.Context, wg *sync.WaitGroup, config *config.Config) error {
-	server := &Server{ctx, wg, config, nil}
-	return server.Start()
+func Start(ctx context.Context, wg *sync.WaitGroup, config *config.Config) (server *Server, err error) {
+	server = &Server{
+		ctx:      ctx,
+		wg:       wg,
+		config:   config,
+		roles:    NewSupervisor(ctx),
+		store:    bus.NewControlStore(config.Bus),
+		snapshot: config.Snapshot(),
+	}
+	err = server.Start()
+	return
 }
 
 func (s *Server) Start() (err error) {
-	// Create poly tx sync handler
-	if s.config.Active(base.POLY) && s.config.Poly != nil {
-		s.parseHandlers(base.POLY, s.config.Poly.PolyTxSync)
+	err = s.apply(s.ctx)
+	if err != nil {
+		return
 	}
+	go s.watch()
 
-	// Create handlers
-	for id, chain := range s.config.Chains {
-		if s.config.Active(id) {
-			s.parseHandlers(id, chain.HeaderSync, chain.SrcTxSync, chain.SrcTxCommit, chain.PolyTxCommit)
-		}
+	if s.config.Shard != nil && s.config.Shard.Enabled {
+		s.shard = NewShard(s)
+		return s.shard.Start()
 	}
 
-	// Initialize
-	for i, handler := range s.roles {
-		log.Info("Initializing role", "index", i, "total", len(s.roles), "type", reflect.TypeOf(handler), "chain", handler.Chain())
-		err = handler.Init(s.ctx, s.wg)
+	tasks := s.config.Tasks()
+	for i, t := range tasks {
+		create := s.factory(t)
+		if create == nil {
+			continue
+		}
+		log.Info("Initializing role", "index", i, "total", len(tasks), "role", t.Role, "chain", t.Chain)
+		err = s.startRole(t.String(), t.Chain, create)
 		if err != nil {
+			s.roles.StopAll()
 			return
 		}
 	}
+	go s.publish()
+	return
+}
 
-	// Start the roles
-	for i, handler := range s.roles {
-		log.Info("Starting role", "index", i, "total", len(s.roles), "type", reflect.TypeOf(handler), "chain", handler.Chain())
-		err = handler.Start()
+// Update the instance with the role states and counters to publish
+func (s *Server) refresh(self *bus.Instance) {
+	self.Roles = s.roles.Status()
+	self.Drops = bus.Drops()
+	self.Outcomes = bus.Outcomes()
+	self.GasUsed = bus.GasUsages()
+}
+
+// Publish role states till exit, then stop the roles in order
+func (s *Server) publish() {
+	s.wg.Add(1)
+	defer s.wg.Done()
+	registry := bus.NewRegistry(s.config.Bus)
+	self := &bus.Instance{Id: instanceId(s.config.Shard), Started: time.Now().Unix()}
+	ticker := time.NewTicker(ROLE_STATUS_INTERVAL)
+	defer ticker.Stop()
+	for {
+		s.refresh(self)
+		err := registry.Heartbeat(s.ctx, self)
 		if err != nil {
+			log.Error("Failed to publish role states", "err", err)
+		}
+		select {
+		case <-s.ctx.Done():
+			s.roles.StopAll()
+			registry.Leave(context.Background(), self.Id)
 			return
+		case <-ticker.C:
 		}
 	}
-	return
 }
 
-func (s *Server) parseHandlers(chain uint64, confs ...interface{}) {
-	for _, conf := range confs {
-		handler := s.parseHandler(chain, conf)
-		if handler != nil {
-			s.roles = append(s.roles, handler)
+// Apply the reloaded config, only the roles with changed config are started, stopped or restarted
+func (s *Server) Reload(conf *config.Config) {
+	snapshot := conf.Snapshot()
+	s.Lock()
+	last := s.config
+	changes := config.Diff(s.snapshot, snapshot)
+	s.config, s.snapshot = conf, snapshot
+	s.Unlock()
+
+	for _, field := range last.Changed(conf) {
+		switch field {
+		case "ValidMethods":
+			log.Info("Reloaded config setting", "field", field)
+		default:
+			log.Warn("Config setting change takes effect after restart", "field", field)
+		}
+	}
+	if len(changes) == 0 {
+		log.Info("No role changes in the reloaded config")
+		return
+	}
+	for _, c := range changes {
+		log.Info("Applying role change", "action", c.Action, "role", c.Role, "chain", c.Chain)
+	}
+	if s.shard != nil {
+		s.shard.reload(changes)
+		return
+	}
+	for _, c := range changes {
+		err := s.change(c)
+		if err != nil {
+			log.Error("Failed to apply role change", "action", c.Action, "role", c.Role, "chain", c.Chain, "err", err)
 		}
 	}
 }
 
-func (s *Server) parseHandler(chain uint64, conf interface{}) (handler Handler) {
-	if reflect.ValueOf(conf).IsZero() || !reflect.ValueOf(conf).Elem().FieldByName("Enabled").Interface().(bool) {
+// Active config, replaced on reloads
+func (s *Server) active() *config.Config {
+	s.Lock()
+	defer s.Unlock()
+	return s.config
+}
+
+// Start, stop or restart the role with the active config
+func (s *Server) change(c config.Change) error {
+	name := c.Task.String()
+	create := s.factory(c.Task)
+	switch {
+	case c.Action == config.CHANGE_STOP || create == nil:
+		s.roles.Stop(name)
+	case c.Action == config.CHANGE_RELOAD && s.roles.Reload(name, create):
+	default:
+		return s.startRole(name, c.Chain, create)
+	}
+	return nil
+}
+
+// Start the role, held paused per the runtime control
+func (s *Server) startRole(name string, chain uint64, create func() Handler) error {
+	return s.roles.Start(name, chain, create, s.control(name).Paused)
+}
+
+// Runtime control of the role
+func (s *Server) control(name string) *bus.Control {
+	s.Lock()
+	defer s.Unlock()
+	if c := s.controls[name]; c != nil {
+		return c
+	}
+	return new(bus.Control)
+}
+
+// Apply runtime role controls till exit
+func (s *Server) watch() {
+	s.wg.Add(1)
+	defer s.wg.Done()
+	ticker := time.NewTicker(CONTROL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := s.apply(s.ctx)
+		if err != nil {
+			log.Error("Failed to apply role controls", "err", err)
+		}
+	}
+}
+
+// Load the role controls, pause or restart the roles with changed controls
+func (s *Server) apply(ctx context.Context) (err error) {
+	controls, err := s.store.List(ctx)
+	if err != nil {
 		return
 	}
+	s.Lock()
+	last := s.controls
+	s.controls = controls
+	s.Unlock()
+	for _, name := range s.roles.Roles() {
+		c := controls[name]
+		if c.Same(last[name]) {
+			continue
+		}
+		if c != nil && c.Paused {
+			log.Warn("Pausing role", "role", name, "reason", c.Reason)
+			s.roles.Pause(name)
+		} else {
+			log.Info("Restarting role with updated control", "role", name)
+			s.roles.Restart(name)
+		}
+	}
+	return
+}
+
+// Copy of the role config with the control overrides
+func override(conf interface{}, c *bus.Control) interface{} {
+	if c.Procs == 0 && c.Filter == nil {
+		return conf
+	}
+	v := reflect.New(reflect.TypeOf(conf).Elem())
+	v.Elem().Set(reflect.ValueOf(conf).Elem())
+	if f := v.Elem().FieldByName("Procs"); f.IsValid() && c.Procs > 0 {
+		f.SetInt(int64(c.Procs))
+	}
+	if f := v.Elem().FieldByName("Filter"); f.IsValid() && c.Filter != nil {
+		f.Set(reflect.ValueOf(c.Filter))
+	}
+	return v.Interface()
+}
+
+// Instance id in the registry, hostname and pid if unspecified
+func instanceId(conf *config.ShardConfig) string {
+	if conf != nil && conf.Instance != "" {
+		return conf.Instance
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Handler factory of the task, nil if the task has no handler
+func (s *Server) factory(t config.Task) func() Handler {
+	conf := s.active().TaskConfig(t)
+	if !s.supported(t.Chain, conf) {
+		return nil
+	}
+	return func() Handler {
+		return s.parseHandler(t.Chain, override(conf, s.control(t.String())))
+	}
+}
+
+func (s *Server) supported(chain uint64, conf interface{}) bool {
+	if conf == nil || reflect.ValueOf(conf).IsZero() || !reflect.ValueOf(conf).Elem().FieldByName("Enabled").Interface().(bool) {
+		return false
+	}
 	switch chain {
 	case base.OK, base.MATIC, base.HEIMDALL:
-		return nil
+		return false
+	}
+	return true
+}
+
+func (s *Server) parseHandler(chain uint64, conf interface{}) (handler Handler) {
+	if !s.supported(chain, conf) {
+		return
 	}
 
 	switch c := conf.(type) {
@@ -99,7 +299,7 @@ func (s *Server) parseHandler(chain uint64, conf interface{}) (handler Handler)
 	case *config.SrcTxCommitConfig:
 		handler = NewSrcTxCommitHandler(c)
 	case *config.PolyTxSyncConfig:
-		handler = NewPolyTxSyncHandler(c)
+		handler = NewSingleton(NewPolyTxSyncHandler(c), c.Bus, "poly_sync")
 	case *config.PolyTxCommitConfig:
 		handler = NewPolyTxCommitHandler(c)
 	default:
@@ -0,0 +1,319 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package relayer
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/polynetwork/bridge-common/base"
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+// Runs the tasks assigned to the instance, the elected coordinator assigns tasks of live instances
+type Shard struct {
+	server   *Server
+	conf     *config.ShardConfig
+	registry bus.Registry
+	leader   *bus.Leader
+	self     *bus.Instance
+	reloads  chan []config.Change
+}
+
+func NewShard(server *Server) *Shard {
+	conf := server.config.Shard
+	self := &bus.Instance{Id: instanceId(conf), Started: time.Now().Unix()}
+	for _, t := range server.config.Tasks() {
+		self.Tasks = append(self.Tasks, t.String())
+	}
+	return &Shard{
+		server:   server,
+		conf:     conf,
+		registry: bus.NewRegistry(server.config.Bus),
+		leader:   bus.NewLeader(server.config.Bus, "shard_coordinator"),
+		self:     self,
+		reloads:  make(chan []config.Change, 1),
+	}
+}
+
+func (s *Shard) Start() error {
+	log.Info("Joining relayer shard", "instance", s.self.Id, "tasks", s.self.Tasks)
+	err := s.registry.Heartbeat(s.server.ctx, s.self)
+	if err != nil {
+		return err
+	}
+	go s.run()
+	go s.coordinate()
+	return nil
+}
+
+func (s *Shard) interval() time.Duration {
+	return time.Duration(s.conf.Heartbeat) * time.Second
+}
+
+func (s *Shard) ttl() time.Duration {
+	return time.Duration(s.conf.TTL) * time.Second
+}
+
+// Heartbeat and start or stop tasks per assignments
+func (s *Shard) run() {
+	s.server.wg.Add(1)
+	defer s.server.wg.Done()
+	for {
+		select {
+		case <-s.server.ctx.Done():
+			s.leave()
+			return
+		case changes := <-s.reloads:
+			s.apply(changes)
+		case <-time.After(s.interval()):
+		}
+		err := s.sync(s.server.ctx)
+		if err != nil {
+			log.Error("Shard assignment sync failure", "instance", s.self.Id, "err", err)
+		}
+	}
+}
+
+func (s *Shard) sync(ctx context.Context) (err error) {
+	s.self.Running = s.running()
+	s.server.refresh(s.self)
+	err = s.registry.Heartbeat(ctx, s.self)
+	if err != nil {
+		return
+	}
+	assignments, err := s.registry.Assignments(ctx)
+	if err != nil {
+		return
+	}
+	instances, err := s.registry.Instances(ctx)
+	if err != nil {
+		return
+	}
+
+	started := map[string]bool{}
+	for _, name := range s.server.roles.Roles() {
+		if assignments[name] != s.self.Id {
+			log.Info("Stopping unassigned task", "task", name, "owner", assignments[name])
+			s.server.roles.Stop(name)
+		} else {
+			started[name] = true
+		}
+	}
+
+	// Tasks moved from other instances start after the previous owners stop them
+	running := map[string]string{}
+	for _, i := range instances {
+		if i.Id != s.self.Id && i.Alive(s.ttl()) {
+			for _, name := range i.Running {
+				running[name] = i.Id
+			}
+		}
+	}
+	for name, id := range assignments {
+		if id != s.self.Id || started[name] {
+			continue
+		}
+		if owner, ok := running[name]; ok {
+			log.Info("Waiting for task handover", "task", name, "owner", owner)
+			continue
+		}
+		err := s.startTask(name)
+		if err != nil {
+			log.Error("Failed to start assigned task", "task", name, "err", err)
+		}
+	}
+	return nil
+}
+
+// Apply config changes in the run loop
+func (s *Shard) reload(changes []config.Change) {
+	select {
+	case s.reloads <- changes:
+	case <-s.server.ctx.Done():
+	}
+}
+
+// Update the tasks capable of, stop or restart the running roles changed, the coordinator assigns new tasks
+func (s *Shard) apply(changes []config.Change) {
+	s.self.Tasks = nil
+	for _, t := range s.server.active().Tasks() {
+		s.self.Tasks = append(s.self.Tasks, t.String())
+	}
+	running := map[string]bool{}
+	for _, name := range s.server.roles.Roles() {
+		running[name] = true
+	}
+	for _, c := range changes {
+		if c.Action == config.CHANGE_START || !running[c.Task.String()] {
+			continue
+		}
+		err := s.server.change(c)
+		if err != nil {
+			log.Error("Failed to apply task change", "task", c.Task, "action", c.Action, "err", err)
+		}
+	}
+}
+
+func (s *Shard) startTask(name string) (err error) {
+	t, err := config.ParseTask(name)
+	if err != nil {
+		return
+	}
+	create := s.server.factory(t)
+	if create == nil {
+		return fmt.Errorf("No enabled handler for the task")
+	}
+	log.Info("Starting assigned task", "task", name)
+	return s.server.startRole(name, t.Chain, create)
+}
+
+func (s *Shard) running() (names []string) {
+	names = s.server.roles.Roles()
+	sort.Strings(names)
+	return
+}
+
+// Stop all the tasks and leave the shard for other instances to take over
+func (s *Shard) leave() {
+	s.server.roles.StopAll()
+	err := s.registry.Leave(context.Background(), s.self.Id)
+	if err != nil {
+		log.Error("Failed to leave shard", "instance", s.self.Id, "err", err)
+	}
+}
+
+// Assign tasks of live instances while elected as the coordinator
+func (s *Shard) coordinate() {
+	s.server.wg.Add(1)
+	defer s.server.wg.Done()
+	for {
+		term, err := s.leader.Campaign(s.server.ctx)
+		if err != nil {
+			return
+		}
+		s.assign(term)
+		s.leader.Resign(context.Background())
+	}
+}
+
+func (s *Shard) assign(ctx context.Context) {
+	for {
+		err := s.balance(ctx)
+		if err != nil {
+			log.Error("Shard coordinator assignment failure", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(s.conf.Rebalance) * time.Second):
+		}
+	}
+}
+
+func (s *Shard) balance(ctx context.Context) (err error) {
+	instances, err := s.registry.Instances(ctx)
+	if err != nil {
+		return
+	}
+	live := []*bus.Instance{}
+	for _, i := range instances {
+		if i.Alive(s.ttl()) {
+			live = append(live, i)
+		} else {
+			log.Warn("Removing dead relayer instance", "instance", i.Id, "heartbeat", i.Heartbeat)
+			s.registry.Leave(ctx, i.Id)
+		}
+	}
+	current, err := s.registry.Assignments(ctx)
+	if err != nil {
+		return
+	}
+	assignments := bus.Balance(live, current)
+	if reflect.DeepEqual(assignments, current) {
+		return
+	}
+	err = s.leader.Fence(ctx)
+	if err != nil {
+		return
+	}
+	for name, id := range assignments {
+		if current[name] != id {
+			log.Info("Assigning task", "task", name, "from", current[name], "to", id)
+		}
+	}
+	return s.registry.Assign(ctx, assignments)
+}
+
+func ShardStatus(ctx *cli.Context) (err error) {
+	registry := bus.NewRegistry(config.CONFIG.Bus)
+	instances, err := registry.Instances(context.Background())
+	if err != nil {
+		return
+	}
+	assignments, err := registry.Assignments(context.Background())
+	if err != nil {
+		return
+	}
+	conf := config.CONFIG.Shard
+	if conf == nil {
+		conf = new(config.ShardConfig)
+		conf.Init()
+	}
+	ttl := time.Duration(conf.TTL) * time.Second
+	leader, token, _ := bus.NewElection(config.CONFIG.Bus, "shard_coordinator").Leader(context.Background())
+	fmt.Printf("Shard coordinator: %s token %v\n", leader, token)
+	fmt.Printf("Instances:\n")
+	running := map[string][]string{}
+	for _, i := range instances {
+		status := "alive"
+		if !i.Alive(ttl) {
+			status = "gone"
+		}
+		fmt.Printf("  %s %s heartbeat %s ago, tasks %v\n", i.Id, status, time.Since(time.Unix(i.Heartbeat, 0)).Truncate(time.Second), len(i.Tasks))
+		for _, r := range i.Roles {
+			fmt.Printf("    %s %s since %s restarts %v %s\n", r.Name, r.State, time.Unix(r.Since, 0).Format(time.RFC3339), r.Restarts, r.Error)
+		}
+		for _, name := range i.Running {
+			running[name] = append(running[name], i.Id)
+		}
+	}
+	names := []string{}
+	for name := range assignments {
+		names = append(names, name)
+	}
+	for name := range running {
+		if _, ok := assignments[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	fmt.Printf("Assignments:\n")
+	for _, name := range names {
+		t, _ := config.ParseTask(name)
+		fmt.Printf("  %s %s owner %s running on %v\n", base.GetChainName(t.Chain), t.Role, assignments[name], running[name])
+	}
+	return
+}
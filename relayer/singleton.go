@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package relayer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+// Handlers which fence their writes with the leader term
+type Fenced interface {
+	SetLeader(*bus.Leader)
+}
+
+// Handler expected to run on one replica only, standby replicas take over once the leader is gone
+type Singleton struct {
+	Handler
+	leader *bus.Leader
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
+	done   chan struct{}
+}
+
+func NewSingleton(handler Handler, conf *config.BusConfig, name string) *Singleton {
+	s := &Singleton{Handler: handler, leader: bus.NewLeader(conf, name)}
+	if h, ok := handler.(Fenced); ok {
+		h.SetLeader(s.leader)
+	}
+	return s
+}
+
+// Handler is initialized per term after elected
+func (s *Singleton) Init(ctx context.Context, wg *sync.WaitGroup) error {
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.wg = wg
+	return nil
+}
+
+func (s *Singleton) Start() error {
+	s.done = make(chan struct{})
+	go s.run()
+	return nil
+}
+
+// Stop the term and wait till the inner handler stopped
+func (s *Singleton) Stop() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.done != nil {
+		<-s.done
+	}
+	return nil
+}
+
+func (s *Singleton) run() {
+	s.wg.Add(1)
+	defer s.wg.Done()
+	defer close(s.done)
+	defer bus.Recover(s.ctx)
+	for {
+		term, err := s.leader.Campaign(s.ctx)
+		if err != nil {
+			log.Info("Singleton handler is exiting now", "chain", s.Chain())
+			return
+		}
+		wg := new(sync.WaitGroup)
+		err = s.Handler.Init(term, wg)
+		if err == nil {
+			err = s.Handler.Start()
+		}
+		if err != nil {
+			log.Error("Failed to start singleton handler", "chain", s.Chain(), "err", err)
+		} else {
+			<-term.Done()
+		}
+		wg.Wait()
+		s.Handler.Stop()
+		err = s.leader.Resign(context.Background())
+		if err != nil {
+			log.Error("Failed to resign leadership", "chain", s.Chain(), "err", err)
+		}
+		select {
+		case <-s.ctx.Done():
+			log.Info("Singleton handler is exiting now", "chain", s.Chain())
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
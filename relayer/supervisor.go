@@ -0,0 +1,331 @@
+/*
+ * Copyright (C) 2021 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package relayer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/bridge-common/log"
+	"github.com/polynetwork/poly-relayer/bus"
+	"github.com/polynetwork/poly-relayer/config"
+)
+
+const (
+	RESTART_DELAY     = time.Second      // Delay before the first restart, doubled per failure
+	RESTART_MAX_DELAY = 5 * time.Minute  // Max restart delay
+	RESTART_RESET     = 10 * time.Minute // Roles running longer than this restart without backoff
+)
+
+// Supervised handler, recreated on every restart
+type role struct {
+	sync.Mutex
+	ctx     context.Context
+	status  bus.RoleStatus
+	create  func() Handler
+	handler Handler
+	failed  chan error
+	control chan string // Target state requested, paused or running
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func (r *role) set(state string, err error) {
+	r.Lock()
+	defer r.Unlock()
+	r.status.State = state
+	r.status.Since = time.Now().Unix()
+	if err != nil {
+		r.status.Error = err.Error()
+	}
+}
+
+func (r *role) Status() bus.RoleStatus {
+	r.Lock()
+	defer r.Unlock()
+	return r.status
+}
+
+// Create, init and start the handler, panics are recovered as failures
+func (r *role) launch() (err error) {
+	r.set(bus.ROLE_STARTING, nil)
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("Panic %v", p)
+		}
+	}()
+	r.Lock()
+	create := r.create
+	r.Unlock()
+	r.handler = create()
+	ctx := bus.WithFailure(r.ctx, r.fail)
+	err = r.handler.Init(ctx, new(sync.WaitGroup))
+	if err == nil {
+		err = r.handler.Start()
+	}
+	if err == nil {
+		r.set(bus.ROLE_RUNNING, nil)
+	}
+	return
+}
+
+// Report failure of the running handler, extra failures are dropped till restart
+func (r *role) fail(err error) {
+	select {
+	case r.failed <- err:
+	default:
+	}
+}
+
+// Stop the handler, panics are recovered
+func (r *role) halt() {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Error("Role panic on stop", "role", r.status.Name, "err", p)
+		}
+	}()
+	if r.handler != nil {
+		r.handler.Stop()
+		r.handler = nil
+	}
+	// Drop failures of the stopped handler
+	select {
+	case <-r.failed:
+	default:
+	}
+}
+
+// Restart delay of the role failed after running for the duration, and the delay for the next failure
+func backoff(delay, running time.Duration) (wait, next time.Duration) {
+	if running > RESTART_RESET {
+		delay = RESTART_DELAY
+	}
+	next = delay * 2
+	if next > RESTART_MAX_DELAY {
+		next = RESTART_MAX_DELAY
+	}
+	return delay, next
+}
+
+// Restart the failed handler with backoff, pause or restart it on request till stopped
+func (r *role) supervise() {
+	defer close(r.done)
+	delay := RESTART_DELAY
+	started := time.Now()
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-r.stop:
+			r.halt()
+			r.set(bus.ROLE_STOPPED, nil)
+			return
+		case err := <-r.failed:
+			var wait time.Duration
+			wait, delay = backoff(delay, time.Since(started))
+			log.Error("Role failure, restarting", "role", r.status.Name, "err", err, "delay", wait)
+			r.halt()
+			r.set(bus.ROLE_BACKOFF, err)
+			retry = time.After(wait)
+		case <-retry:
+			retry = nil
+			r.Lock()
+			r.status.Restarts++
+			r.Unlock()
+			started = time.Now()
+			err := r.launch()
+			if err != nil {
+				r.fail(err)
+			}
+		case state := <-r.control:
+			retry = nil
+			r.halt()
+			if state == bus.ROLE_PAUSED {
+				log.Info("Role paused", "role", r.status.Name)
+				r.set(bus.ROLE_PAUSED, nil)
+				continue
+			}
+			log.Info("Role restarting on request", "role", r.status.Name)
+			delay = RESTART_DELAY
+			started = time.Now()
+			err := r.launch()
+			if err != nil {
+				r.fail(err)
+			}
+		}
+	}
+}
+
+// Runs roles under recover and restarts failed ones with backoff
+type Supervisor struct {
+	sync.Mutex
+	ctx   context.Context // Parent context of the handlers
+	roles []*role
+}
+
+func NewSupervisor(ctx context.Context) *Supervisor {
+	return &Supervisor{ctx: ctx}
+}
+
+// Start the role and supervise it, returns the error of the first start.
+// Paused role is supervised without start till resumed.
+func (s *Supervisor) Start(name string, chain uint64, create func() Handler, paused bool) (err error) {
+	r := &role{
+		ctx:     s.ctx,
+		status:  bus.RoleStatus{Name: name, Chain: chain},
+		create:  create,
+		failed:  make(chan error, 1),
+		control: make(chan string),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if paused {
+		log.Warn("Role is paused", "role", name, "chain", chain)
+		r.set(bus.ROLE_PAUSED, nil)
+	} else {
+		log.Info("Starting role", "role", name, "chain", chain)
+		err = r.launch()
+		if err != nil {
+			r.halt()
+			r.set(bus.ROLE_STOPPED, err)
+			return
+		}
+		log.Info("Started role", "role", name, "type", reflect.TypeOf(r.handler))
+	}
+	s.Lock()
+	s.roles = append(s.roles, r)
+	s.Unlock()
+	go r.supervise()
+	return
+}
+
+func (s *Supervisor) find(name string) *role {
+	s.Lock()
+	defer s.Unlock()
+	for _, r := range s.roles {
+		if r.status.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Request the role to enter the state, paused or running, false if role not found
+func (s *Supervisor) request(name, state string) bool {
+	r := s.find(name)
+	if r == nil {
+		return false
+	}
+	select {
+	case r.control <- state:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// Stop the handler of the role and keep it paused till resumed
+func (s *Supervisor) Pause(name string) bool {
+	return s.request(name, bus.ROLE_PAUSED)
+}
+
+// Restart the role with a new handler, resumes the paused role
+func (s *Supervisor) Restart(name string) bool {
+	return s.request(name, bus.ROLE_RUNNING)
+}
+
+// Replace the handler factory of the role and restart it, paused role stays paused till resumed
+func (s *Supervisor) Reload(name string, create func() Handler) bool {
+	r := s.find(name)
+	if r == nil {
+		return false
+	}
+	r.Lock()
+	r.create = create
+	paused := r.status.State == bus.ROLE_PAUSED
+	r.Unlock()
+	if paused {
+		return true
+	}
+	return s.request(name, bus.ROLE_RUNNING)
+}
+
+// Stop the role and wait till its handler exits
+func (s *Supervisor) Stop(name string) {
+	s.Lock()
+	var target *role
+	for i, r := range s.roles {
+		if r.status.Name == name {
+			target = r
+			s.roles = append(s.roles[:i], s.roles[i+1:]...)
+			break
+		}
+	}
+	s.Unlock()
+	if target != nil {
+		log.Info("Stopping role", "role", name)
+		close(target.stop)
+		<-target.done
+	}
+}
+
+// Stop all the roles one by one in the reverse order of start
+func (s *Supervisor) StopAll() {
+	for {
+		s.Lock()
+		if len(s.roles) == 0 {
+			s.Unlock()
+			return
+		}
+		name := s.roles[len(s.roles)-1].status.Name
+		s.Unlock()
+		s.Stop(name)
+	}
+}
+
+func (s *Supervisor) Status() (list []bus.RoleStatus) {
+	s.Lock()
+	defer s.Unlock()
+	for _, r := range s.roles {
+		list = append(list, r.Status())
+	}
+	return
+}
+
+// Names of the supervised roles
+func (s *Supervisor) Roles() (names []string) {
+	s.Lock()
+	defer s.Unlock()
+	for _, r := range s.roles {
+		names = append(names, r.status.Name)
+	}
+	return
+}
+
+// /api/v1/roles role states published by the relayer instances
+func Roles(w http.ResponseWriter, r *http.Request) {
+	instances, err := bus.NewRegistry(config.CONFIG.Bus).Instances(context.Background())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		Json(w, instances)
+	}
+}
@@ -0,0 +1,178 @@
+package relayer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/polynetwork/poly-relayer/bus"
+)
+
+// Handler recording its lifecycle, panics on a goroutine once started if set
+type fakeHandler struct {
+	name   string
+	ctx    context.Context
+	panics bool
+	events *events
+}
+
+type events struct {
+	sync.Mutex
+	list []string
+}
+
+func (e *events) add(event string) {
+	e.Lock()
+	defer e.Unlock()
+	e.list = append(e.list, event)
+}
+
+func (e *events) count(event string) (n int) {
+	e.Lock()
+	defer e.Unlock()
+	for _, v := range e.list {
+		if v == event {
+			n++
+		}
+	}
+	return
+}
+
+func (e *events) stops() (list []string) {
+	e.Lock()
+	defer e.Unlock()
+	for _, v := range e.list {
+		if len(v) > 5 && v[:5] == "stop:" {
+			list = append(list, v[5:])
+		}
+	}
+	return
+}
+
+func (h *fakeHandler) Init(ctx context.Context, wg *sync.WaitGroup) error {
+	h.ctx = ctx
+	return nil
+}
+
+func (h *fakeHandler) Chain() uint64 { return 0 }
+
+func (h *fakeHandler) Start() error {
+	h.events.add("start:" + h.name)
+	if h.panics {
+		go func() {
+			defer bus.Recover(h.ctx)
+			panic("boom")
+		}()
+	}
+	return nil
+}
+
+func (h *fakeHandler) Stop() error {
+	h.events.add("stop:" + h.name)
+	return nil
+}
+
+func waitFor(t *testing.T, timeout time.Duration, f func() bool) {
+	deadline := time.Now().Add(timeout)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func roleState(s *Supervisor, name string) string {
+	for _, status := range s.Status() {
+		if status.Name == name {
+			return status.State
+		}
+	}
+	return ""
+}
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		delay, running, wait, next time.Duration
+	}{
+		{RESTART_DELAY, 0, time.Second, 2 * time.Second},
+		{2 * time.Second, time.Minute, 2 * time.Second, 4 * time.Second},
+		{4 * time.Minute, time.Minute, 4 * time.Minute, RESTART_MAX_DELAY},
+		{RESTART_MAX_DELAY, time.Minute, RESTART_MAX_DELAY, RESTART_MAX_DELAY},
+		{RESTART_MAX_DELAY, RESTART_RESET + time.Second, RESTART_DELAY, 2 * RESTART_DELAY},
+	}
+	for _, c := range cases {
+		wait, next := backoff(c.delay, c.running)
+		if wait != c.wait || next != c.next {
+			t.Fatalf("Backoff %v after running %v, expect %v %v, got %v %v", c.delay, c.running, c.wait, c.next, wait, next)
+		}
+	}
+}
+
+func TestSupervisor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewSupervisor(ctx)
+	e := new(events)
+	crash := true
+	create := func(name string) func() Handler {
+		return func() Handler {
+			h := &fakeHandler{name: name, events: e}
+			if name == "crash" {
+				h.panics, crash = crash, false
+			}
+			return h
+		}
+	}
+	for _, name := range []string{"a", "crash", "b"} {
+		err := s.Start(name, 0, create(name), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Panic on a goroutine is reported, the handler is stopped and restarted after backoff
+	waitFor(t, time.Second, func() bool { return roleState(s, "crash") == bus.ROLE_BACKOFF })
+	if e.count("stop:crash") != 1 {
+		t.Fatal("Expect failed handler stopped")
+	}
+	waitFor(t, 3*time.Second, func() bool { return roleState(s, "crash") == bus.ROLE_RUNNING })
+	if e.count("start:crash") != 2 {
+		t.Fatalf("Expect failed role restarted once, got starts %v", e.count("start:crash"))
+	}
+	for _, status := range s.Status() {
+		if status.Name == "crash" && (status.Restarts != 1 || status.Error != "Panic boom") {
+			t.Fatalf("Unexpected role status %+v", status)
+		}
+	}
+
+	// Paused role is stopped till restarted
+	if !s.Pause("a") {
+		t.Fatal("Expect role paused")
+	}
+	waitFor(t, time.Second, func() bool { return roleState(s, "a") == bus.ROLE_PAUSED })
+	if e.count("stop:a") != 1 {
+		t.Fatal("Expect paused role stopped")
+	}
+	if !s.Restart("a") {
+		t.Fatal("Expect paused role restarted")
+	}
+	waitFor(t, time.Second, func() bool { return e.count("start:a") == 2 && roleState(s, "a") == bus.ROLE_RUNNING })
+	if s.Pause("missing") {
+		t.Fatal("Expect unknown role not paused")
+	}
+
+	// Single stop, then the rest in the reverse order of start
+	s.Stop("crash")
+	s.StopAll()
+	stops := e.stops()
+	expected := []string{"crash", "a", "crash", "b", "a"}
+	if fmt.Sprint(stops) != fmt.Sprint(expected) {
+		t.Fatalf("Expect stops %v, got %v", expected, stops)
+	}
+	if len(s.Roles()) != 0 {
+		t.Fatalf("Expect no role left, got %v", s.Roles())
+	}
+}
@@ -34,7 +34,8 @@ import (
 
 type PolyTxCommitHandler struct {
 	context.Context
-	wg *sync.WaitGroup
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
 
 	bus       bus.TxBus
 	queue     bus.DelayedTxBus // Delayed tx bus
@@ -54,7 +55,7 @@ func NewPolyTxCommitHandler(config *config.PolyTxCommitConfig) *PolyTxCommitHand
 }
 
 func (h *PolyTxCommitHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err error) {
-	h.Context = ctx
+	h.Context, h.cancel = context.WithCancel(ctx)
 	h.wg = wg
 
 	if h.config.CheckFee {
@@ -78,8 +79,8 @@ func (h *PolyTxCommitHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err
 		return
 	}
 
-	h.bus = bus.NewRedisTxBus(bus.New(h.config.Bus.Redis), h.config.ChainId, msg.POLY)
-	h.queue = bus.NewRedisDelayedTxBus(bus.New(h.config.Bus.Redis))
+	h.bus = bus.NewTxBus(h.config.Bus, h.config.ChainId, msg.POLY)
+	h.queue = bus.NewDelayedTxBus(h.config.Bus)
 	return
 }
 
@@ -89,8 +90,8 @@ func (h *PolyTxCommitHandler) Compose(tx *msg.Tx) (err error) {
 		return
 	}
 	if h.config.Filter != nil {
-		if !h.config.Filter.Check(tx) {
-			log.Warn("Poly tx commit skipped for not target", "from", tx.SrcProxy, "to", tx.DstProxy)
+		if ok, reason := h.config.Filter.Evaluate(tx, true); !ok {
+			bus.Drop(tx, reason)
 			return msg.ERR_TX_BYPASS
 		} else {
 			log.Info("Poly tx commit proxy filter passed", "from", tx.SrcProxy, "to", tx.DstProxy)
@@ -106,21 +107,33 @@ func (h *PolyTxCommitHandler) Start() (err error) {
 	}
 	{
 		bus := &CommitFilter{
-			name:     base.GetChainName(h.config.ChainId),
-			TxBus:    mq,
-			checkFee: h.config.CheckFee,
-			delay:    h.queue,
-			ch:       make(chan *msg.Tx, 100),
-			bridge:   h.bridge,
+			name:       base.GetChainName(h.config.ChainId),
+			TxBus:      mq,
+			checkFee:   h.config.CheckFee,
+			delay:      h.queue,
+			ch:         make(chan *msg.Tx, 100),
+			high:       make(chan *msg.Tx, 100),
+			overpaid:   h.config.OverpaidRatio,
+			bridge:     h.bridge,
+			dlq:        bus.NewDeadLetterBus(h.config.Bus),
+			deadLetter: h.config.DeadLetter,
+			retry:      bus.NewRetryPolicy(h.config.Retry),
 		}
 		go bus.Pipe(h.Context, h.wg)
 		mq = bus
 	}
+	if reaper, ok := h.bus.(bus.Reaper); ok {
+		go bus.StartReaper(h.Context, h.wg, reaper, h.bus.Topic())
+	}
 	err = h.submitter.Start(h.Context, h.wg, mq, h.queue, h.Compose)
 	return
 }
 
 func (h *PolyTxCommitHandler) Stop() (err error) {
+	if h.cancel != nil {
+		h.cancel()
+		h.wg.Wait()
+	}
 	return
 }
 
@@ -131,22 +144,54 @@ func (h *PolyTxCommitHandler) Chain() uint64 {
 type CommitFilter struct {
 	name string
 	bus.TxBus
-	checkFee bool
-	delay  bus.DelayedTxBus
-	ch     chan *msg.Tx
-	bridge *bridge.SDK
+	checkFee   bool
+	delay      bus.DelayedTxBus
+	ch         chan *msg.Tx
+	high       chan *msg.Tx // Fee checked txs of high priority
+	bridge     *bridge.SDK
+	dlq        bus.DeadLetterBus
+	deadLetter *config.DeadLetterConfig
+	retry      bus.RetryPolicy
+	overpaid   float64 // Paid over min fee ratio for high priority
 }
 
 func (b *CommitFilter) Pop(ctx context.Context) (tx *msg.Tx, err error) {
 	select {
 	case <-ctx.Done():
 		err = fmt.Errorf("Exit signal received")
-	case tx = <-b.ch:
-		log.Info("Check fee passed tx", "poly_hash", tx.PolyHash)
+	case tx = <-b.high:
+	default:
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("Exit signal received")
+		case tx = <-b.high:
+		case tx = <-b.ch:
+		}
+	}
+	if tx != nil {
+		log.Info("Check fee passed tx", "poly_hash", tx.PolyHash, "priority", tx.Priority)
 	}
 	return
 }
 
+// Send the tx to submitters, high priority txs are served first
+func (b *CommitFilter) send(tx *msg.Tx) {
+	if tx.Priority > msg.PRIORITY_NORMAL {
+		b.high <- tx
+	} else {
+		b.ch <- tx
+	}
+}
+
+// Whether the tx paid well over the min fee
+func (b *CommitFilter) overpaidTx(check *bridge.CheckFeeRequest) bool {
+	ratio := b.overpaid
+	if ratio == 0 {
+		ratio = 2
+	}
+	return check.Pass() && check.Min > 0 && check.Paid >= check.Min*ratio
+}
+
 func (b *CommitFilter) flush(ctx context.Context, txs []*msg.Tx) (err error) {
 	// Check fee here:
 	// Pass -> send to submitter
@@ -178,24 +223,29 @@ func (b *CommitFilter) flush(ctx context.Context, txs []*msg.Tx) (err error) {
 		}
 
 		if check.Pass() {
-			b.ch <- tx
-			log.Info("CheckFee pass", "poly_hash", tx.PolyHash, "min", feeMin, "paid", feePaid)
+			if b.overpaidTx(check) {
+				tx.Priority = msg.PRIORITY_HIGH
+			}
+			b.send(tx)
+			log.Info("CheckFee pass", "poly_hash", tx.PolyHash, "min", feeMin, "paid", feePaid, "priority", tx.Priority)
 		} else if check.PaidLimit() {
-			b.ch <- tx
+			b.send(tx)
 			log.Info("CheckFee EstimatePay", "poly_hash", tx.PolyHash, "paidGas", tx.PaidGas, "min", feeMin, "paid", feePaid)
 		} else if check.Skip() {
 			log.Warn("Skipping poly for marked as not target in fee check", "poly_hash", tx.PolyHash)
+			bus.SafeCall(ctx, tx, "ack tx", func() error { return b.TxBus.Ack(context.Background(), tx) })
 		} else if check.Missing() {
-			tx.Attempts++
-			log.Info("CheckFee tx missing in bridge, delay for 2 seconds", "poly_hash", tx.PolyHash)
-			tsp := time.Now().Unix() + 5
+			tx.Fail(msg.ERR_FEE_MISSING)
+			tsp := b.retry.Next(tx, msg.ERR_FEE_MISSING)
+			log.Info("CheckFee tx missing in bridge, delay", "poly_hash", tx.PolyHash, "until", tsp)
 			bus.SafeCall(ctx, tx, "push to delay queue", func() error { return b.delay.Delay(context.Background(), tx, tsp) })
-
+			bus.SafeCall(ctx, tx, "ack tx", func() error { return b.TxBus.Ack(context.Background(), tx) })
 		} else {
-			tx.Attempts++
-			log.Info("CheckFee tx not paid, delay for 10 minutes", "poly_hash", tx.PolyHash, "min", feeMin, "paid", feePaid)
-			tsp := time.Now().Unix() + 600
+			tx.Fail(msg.ERR_FEE_NOT_PAID)
+			tsp := b.retry.Next(tx, msg.ERR_FEE_NOT_PAID)
+			log.Info("CheckFee tx not paid, delay", "poly_hash", tx.PolyHash, "min", feeMin, "paid", feePaid, "until", tsp)
 			bus.SafeCall(ctx, tx, "push to delay queue", func() error { return b.delay.Delay(context.Background(), tx, tsp) })
+			bus.SafeCall(ctx, tx, "ack tx", func() error { return b.TxBus.Ack(context.Background(), tx) })
 		}
 	}
 	return
@@ -204,6 +254,7 @@ func (b *CommitFilter) flush(ctx context.Context, txs []*msg.Tx) (err error) {
 func (b *CommitFilter) Pipe(ctx context.Context, wg *sync.WaitGroup) {
 	wg.Add(1)
 	defer wg.Done()
+	defer bus.Recover(ctx)
 	txs := []*msg.Tx{}
 	flush := false
 LOOP:
@@ -220,10 +271,14 @@ LOOP:
 			if tx != nil {
 				if tx.PolyHash == "" {
 					log.Error("Invalid poly tx, poly hash missing", "body", tx.Encode())
+					bus.SafeCall(ctx, tx, "ack tx", func() error { return b.TxBus.Ack(context.Background(), tx) })
 					continue
 				}
-				if tx.Attempts > 1000 && base.ENV == "testnet" {
-					log.Error("Dropping failed tx for too many retries in testnet", "chain", b.name, "poly_hash", tx.PolyHash)
+				if reason, exhausted := b.deadLetter.Exhausted(tx); exhausted {
+					log.Error("Moving tx into dead letter bus", "chain", b.name, "poly_hash", tx.PolyHash, "reason", reason)
+					letter := &bus.DeadLetter{Tx: tx, Reason: reason, Error: tx.LastError(), Created: time.Now().Unix()}
+					bus.SafeCall(ctx, tx, "push to dead letter bus", func() error { return b.dlq.Put(context.Background(), letter) })
+					bus.SafeCall(ctx, tx, "ack tx", func() error { return b.TxBus.Ack(context.Background(), tx) })
 					continue
 				}
 				log.Info("Check fee pending", "chain", b.name, "poly_hash", tx.PolyHash, "process_pending", len(b.ch))
@@ -231,12 +286,12 @@ LOOP:
 				// Skip tx check fee
 				if !b.checkFee {
 					tx.CheckFeeOff = true
-					b.ch <- tx
+					b.send(tx)
 				} else if tx.SkipFee() {
 					log.Info("CheckFee skipped for tx", "poly_hash", tx.PolyHash)
-					b.ch <- tx
+					b.send(tx)
 				} else if tx.CheckFeeStatus == bridge.PAID {
-					b.ch <- tx
+					b.send(tx)
 				} else {
 					txs = append(txs, tx)
 					flush = len(txs) > 10
@@ -256,20 +311,25 @@ LOOP:
 		}
 	}
 	// Drain the buf
-	log.Info("Pushing back check fee queue to poly tx bus", "chain", b.name, "size", len(b.ch))
+	log.Info("Pushing back check fee queue to poly tx bus", "chain", b.name, "size", len(b.ch)+len(b.high))
 	close(b.ch)
+	close(b.high)
+	for tx := range b.high {
+		bus.SafeCall(ctx, tx, "push back to tx bus", func() error { return b.TxBus.Nack(context.Background(), tx) })
+	}
 	for tx := range b.ch {
-		bus.SafeCall(ctx, tx, "push back to tx bus", func() error { return b.TxBus.Push(context.Background(), tx) })
+		bus.SafeCall(ctx, tx, "push back to tx bus", func() error { return b.TxBus.Nack(context.Background(), tx) })
 	}
 	for _, tx := range txs {
-		bus.SafeCall(ctx, tx, "push back to tx bus", func() error { return b.TxBus.Push(context.Background(), tx) })
+		bus.SafeCall(ctx, tx, "push back to tx bus", func() error { return b.TxBus.Nack(context.Background(), tx) })
 	}
 	log.Info("Check fee queu exiting now...", "chain", b.name)
 }
 
 type SrcTxCommitHandler struct {
 	context.Context
-	wg *sync.WaitGroup
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
 
 	bus       bus.SortedTxBus
 	submitter *poly.Submitter
@@ -286,10 +346,16 @@ func NewSrcTxCommitHandler(config *config.SrcTxCommitConfig) *SrcTxCommitHandler
 }
 
 func (h *SrcTxCommitHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err error) {
-	h.Context = ctx
+	h.Context, h.cancel = context.WithCancel(ctx)
 	h.wg = wg
 
 	h.config.Poly.ChainId = h.config.ChainId
+	if h.config.Procs > 0 {
+		// Override procs of the poly submitter
+		poly := *h.config.Poly
+		poly.Procs = h.config.Procs
+		h.config.Poly = &poly
+	}
 	err = h.submitter.Init(h.config.Poly)
 	if err != nil {
 		return
@@ -299,7 +365,7 @@ func (h *SrcTxCommitHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err
 		return fmt.Errorf("Unabled to create listener for chain %s", base.GetChainName(h.config.ChainId))
 	}
 
-	h.bus = bus.NewRedisSortedTxBus(bus.New(h.config.Bus.Redis), h.config.ChainId, msg.SRC)
+	h.bus = bus.NewSortedTxBus(h.config.Bus, h.config.ChainId, msg.SRC)
 	err = h.listener.Init(h.config.ListenerConfig, h.submitter.Poly())
 	return
 }
@@ -309,11 +375,18 @@ func (h *SrcTxCommitHandler) Start() (err error) {
 	if h.config.Filter != nil {
 		mq = bus.WithFilter(h.bus, h.config.Filter)
 	}
+	if reaper, ok := h.bus.(bus.Reaper); ok {
+		go bus.StartReaper(h.Context, h.wg, reaper, h.bus.Topic())
+	}
 	err = h.submitter.Start(h.Context, h.wg, mq, h.listener)
 	return
 }
 
 func (h *SrcTxCommitHandler) Stop() (err error) {
+	if h.cancel != nil {
+		h.cancel()
+		h.wg.Wait()
+	}
 	return
 }
 
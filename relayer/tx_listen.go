@@ -34,7 +34,8 @@ import (
 
 type SrcTxSyncHandler struct {
 	context.Context
-	wg *sync.WaitGroup
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
 
 	listener IChainListener
 	bus      bus.SortedTxBus
@@ -52,7 +53,7 @@ func NewSrcTxSyncHandler(config *config.SrcTxSyncConfig) *SrcTxSyncHandler {
 }
 
 func (h *SrcTxSyncHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err error) {
-	h.Context = ctx
+	h.Context, h.cancel = context.WithCancel(ctx)
 	h.wg = wg
 
 	if h.listener == nil {
@@ -65,13 +66,13 @@ func (h *SrcTxSyncHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err er
 		return
 	}
 
-	h.state = bus.NewRedisChainStore(
-		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_TX}, bus.New(h.config.Bus.Redis),
+	h.state = bus.NewChainStore(
+		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_TX}, h.config.Bus,
 		h.config.Bus.HeightUpdateInterval,
 	)
 
-	h.bus = bus.NewRedisSortedTxBus(bus.New(h.config.Bus.Redis), h.config.ChainId, msg.SRC)
-	h.patch = bus.NewRedisPatchTxBus(bus.New(h.config.Bus.Redis), h.config.ChainId)
+	h.bus = bus.WithDedup(bus.NewSortedTxBus(h.config.Bus, h.config.ChainId, msg.SRC), h.config.Bus)
+	h.patch = bus.NewPatchTxBus(h.config.Bus, h.config.ChainId)
 	return
 }
 
@@ -89,6 +90,7 @@ func (h *SrcTxSyncHandler) Start() (err error) {
 func (h *SrcTxSyncHandler) patchTxs() {
 	h.wg.Add(1)
 	defer h.wg.Done()
+	defer bus.Recover(h.Context)
 	for {
 		select {
 		case <-h.Done():
@@ -133,6 +135,7 @@ func (h *SrcTxSyncHandler) patchTxs() {
 			if tx.SrcHash == "" || util.LowerHex(tx.SrcHash) == util.LowerHex(t.SrcHash) {
 				count++
 				log.Info("Found patch target src tx", "hash", t.SrcHash, "chain", h.config.ChainId, "height", height)
+				t.Force = tx.Force
 				bus.SafeCall(h.Context, t, "push to tx bus", func() error {
 					return h.bus.Push(context.Background(), t, 0)
 				})
@@ -147,6 +150,7 @@ func (h *SrcTxSyncHandler) patchTxs() {
 func (h *SrcTxSyncHandler) start() (err error) {
 	h.wg.Add(1)
 	defer h.wg.Done()
+	defer bus.Recover(h.Context)
 	confirms := base.BlocksToSkip(h.config.ChainId)
 	var (
 		latest uint64
@@ -191,6 +195,10 @@ func (h *SrcTxSyncHandler) start() (err error) {
 }
 
 func (h *SrcTxSyncHandler) Stop() (err error) {
+	if h.cancel != nil {
+		h.cancel()
+		h.wg.Wait()
+	}
 	return
 }
 
@@ -200,7 +208,8 @@ func (h *SrcTxSyncHandler) Chain() uint64 {
 
 type PolyTxSyncHandler struct {
 	context.Context
-	wg *sync.WaitGroup
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
 
 	listener IChainListener
 	bus      bus.TxBus        // main poly tx queue
@@ -208,6 +217,7 @@ type PolyTxSyncHandler struct {
 	queue    bus.DelayedTxBus // delayed poly tx queue
 	state    bus.ChainStore
 	skip     bus.SkipCheck
+	leader   *bus.Leader
 	height   uint64
 	config   *config.PolyTxSyncConfig
 }
@@ -220,7 +230,7 @@ func NewPolyTxSyncHandler(config *config.PolyTxSyncConfig) *PolyTxSyncHandler {
 }
 
 func (h *PolyTxSyncHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err error) {
-	h.Context = ctx
+	h.Context, h.cancel = context.WithCancel(ctx)
 	h.wg = wg
 	if h.listener == nil {
 		return fmt.Errorf("Unabled to create listener for chain %s", base.GetChainName(h.config.ChainId))
@@ -230,25 +240,22 @@ func (h *PolyTxSyncHandler) Init(ctx context.Context, wg *sync.WaitGroup) (err e
 		return
 	}
 
-	h.state = bus.NewRedisChainStore(
-		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_TX}, bus.New(h.config.Bus.Redis),
+	h.state = bus.NewChainStore(
+		bus.ChainHeightKey{ChainId: h.config.ChainId, Type: bus.KEY_HEIGHT_TX}, h.config.Bus,
 		h.config.Bus.HeightUpdateInterval,
 	)
 
-	h.bus = bus.NewRedisTxBus(bus.New(h.config.Bus.Redis), h.config.ChainId, msg.POLY)
-	h.patch = bus.NewRedisPatchTxBus(bus.New(h.config.Bus.Redis), base.POLY)
-	h.queue = bus.NewRedisDelayedTxBus(bus.New(h.config.Bus.Redis))
-	h.skip = bus.NewRedisSkipCheck(bus.New(h.config.Bus.Redis))
-	ok, err := bus.NewStatusLock(bus.New(h.config.Bus.Redis), bus.POLY_SYNC).Start(ctx, h.wg)
-	if err != nil {
-		return err
-	}
-	if !ok {
-		err = fmt.Errorf("Only one poly tx listener is expected to run.")
-	}
+	h.bus = bus.WithTxDedup(bus.NewTxBus(h.config.Bus, h.config.ChainId, msg.POLY), h.config.Bus)
+	h.patch = bus.NewPatchTxBus(h.config.Bus, base.POLY)
+	h.queue = bus.NewDelayedTxBus(h.config.Bus)
+	h.skip = bus.NewSkipCheck(h.config.Bus)
 	return
 }
 
+func (h *PolyTxSyncHandler) SetLeader(leader *bus.Leader) {
+	h.leader = leader
+}
+
 func (h *PolyTxSyncHandler) Start() (err error) {
 	h.height, err = h.state.GetHeight(context.Background())
 	if err != nil {
@@ -264,6 +271,7 @@ func (h *PolyTxSyncHandler) Start() (err error) {
 func (h *PolyTxSyncHandler) start() (err error) {
 	h.wg.Add(1)
 	defer h.wg.Done()
+	defer bus.Recover(h.Context)
 	confirms := uint64(h.listener.Defer())
 	var (
 		latest uint64
@@ -287,6 +295,16 @@ func (h *PolyTxSyncHandler) start() (err error) {
 		log.Info("Scanning poly txs in block", "height", h.height, "chain", h.config.ChainId)
 		txs, err := h.listener.Scan(h.height)
 		if err == nil {
+			// Stale leaders should not push txs after a takeover
+			if h.leader != nil {
+				err = h.leader.Fence(h.Context)
+				if err != nil {
+					log.Error("Skipping poly txs push for leadership check failure", "height", h.height, "err", err)
+					h.height--
+					time.Sleep(time.Second)
+					continue
+				}
+			}
 			for _, tx := range txs {
 				log.Info("Found poly tx", "hash", tx.PolyHash)
 				bus.SafeCall(h.Context, tx, "push to target chain tx bus", func() error {
@@ -306,43 +324,56 @@ func (h *PolyTxSyncHandler) start() (err error) {
 func (h *PolyTxSyncHandler) checkDelayed() (err error) {
 	h.wg.Add(1)
 	defer h.wg.Done()
+	defer bus.Recover(h.Context)
 	for {
+		chains, err := h.queue.Chains(h.Context)
+		if err != nil {
+			log.Error("Delayed poly tx queue chains error", "err", err)
+		}
+		// Always drain the legacy global queue
+		for _, chain := range append(chains, 0) {
+			h.releaseDelayed(chain)
+		}
 		select {
 		case <-h.Done():
 			log.Info("Delayed poly tx sync handler is exiting...", "chain", h.config.ChainId, "height", h.height)
 			return nil
-		default:
+		case <-time.After(time.Second):
 		}
+	}
+}
 
-		tx, score, err := h.queue.Pop(h.Context)
+// Move all the due txs of the dst chain back to the tx bus
+func (h *PolyTxSyncHandler) releaseDelayed(chain uint64) {
+	for {
+		txs, err := h.queue.Release(h.Context, chain, time.Now().Unix(), bus.DELAYED_RELEASE_BATCH)
 		if err != nil {
-			log.Error("Deplayed poly tx queue pop error", "err", err)
-			continue
+			log.Error("Delayed poly tx queue release error", "chain", chain, "err", err)
+			return
 		}
-		if tx != nil && score > 0 {
+		for _, tx := range txs {
 			skip, _ := h.skip.CheckSkip(h.Context, tx)
 			if skip {
 				log.Warn("Skipping tx for marked to skip", "poly_hash", tx.PolyHash)
 				continue
 			}
-			if score <= time.Now().Unix() {
-				bus.SafeCall(h.Context, tx, "push to delay queue", func() error {
-					log.Info("Pushing back delayed tx", "chain", tx.DstChainId, "poly_hash", tx.PolyHash)
-					return h.bus.PushToChain(context.Background(), tx)
-				})
-				continue
-			} else {
-				bus.SafeCall(h.Context, tx, "push to delay queue", func() error {
-					log.Trace("Pushing back delayed tx for not active yet", "chain", tx.DstChainId, "poly_hash", tx.PolyHash)
-					return h.queue.Delay(context.Background(), tx, score)
-				})
+			// Delayed txs are retries of txs already deduped
+			err = bus.SafeCall(h.Context, tx, "push to tx bus", func() error {
+				log.Info("Pushing back delayed tx", "chain", tx.DstChainId, "poly_hash", tx.PolyHash)
+				return bus.PushRetry(context.Background(), h.bus, tx)
+			})
+			if err != nil {
+				// Exiting, put the released tx back to be released again after restart
+				err = bus.Retry(context.Background(), func() error {
+					return h.queue.Delay(context.Background(), tx, time.Now().Unix())
+				}, time.Second, 3)
+				if err != nil {
+					log.Error("Lost released delayed tx", "chain", tx.DstChainId, "poly_hash", tx.PolyHash, "err", err)
+				}
 			}
 		}
-		select {
-		case <-h.Done():
-			log.Info("Delayed poly tx sync handler is exiting...", "chain", h.config.ChainId, "height", h.height)
-			return nil
-		case <-time.After(time.Second):
+		if len(txs) < bus.DELAYED_RELEASE_BATCH {
+			return
 		}
 	}
 }
@@ -350,6 +381,7 @@ func (h *PolyTxSyncHandler) checkDelayed() (err error) {
 func (h *PolyTxSyncHandler) patchTxs() {
 	h.wg.Add(1)
 	defer h.wg.Done()
+	defer bus.Recover(h.Context)
 	for {
 		select {
 		case <-h.Done():
@@ -395,6 +427,8 @@ func (h *PolyTxSyncHandler) patchTxs() {
 				count++
 				log.Info("Found patch target poly tx", "hash", t.PolyHash, "chain", h.config.ChainId, "height", height)
 				t.CapturePatchParams(tx)
+				// Patched txs are served before the backlog
+				t.Priority = msg.PRIORITY_HIGH
 				bus.SafeCall(h.Context, t, "push to target chain tx bus", func() error {
 					return h.bus.PushToChain(context.Background(), t)
 				})
@@ -407,6 +441,10 @@ func (h *PolyTxSyncHandler) patchTxs() {
 }
 
 func (h *PolyTxSyncHandler) Stop() (err error) {
+	if h.cancel != nil {
+		h.cancel()
+		h.wg.Wait()
+	}
 	return
 }
 
@@ -52,7 +52,7 @@ func StartValidator(vs func(uint64) IValidator, listener IChainListener, outputs
 func (v *Validator) start() (err error) {
 	chainID := v.listener.ChainId()
 	log.Info("Starting validator for events", "chain", chainID)
-	status := NewStatusHandler(config.CONFIG.Bus.Redis)
+	status := NewStatusHandler(config.CONFIG.Bus)
 	height, _ := status.Height(chainID, bus.KEY_HEIGHT_VALIDATOR)
 	if height == 0 {
 		height, err = v.listener.LatestHeight()